@@ -0,0 +1,288 @@
+package AlbumManagers
+
+import (
+	"GOLA/Deserializers"
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/commons/models"
+	"GOLA/utils"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+// AlbumHandlers exposes an AlbumManager over HTTP. Every handler expects the JWT middleware
+// to have put the caller's client ID into the request context.
+type AlbumHandlers struct {
+	Albums               AlbumManager
+	ImageMetadataManager Metadata.ImageMetadataManager
+}
+
+// HandleAlbums lists the albums of an owner (GET) or creates an album for the caller (POST).
+func (h *AlbumHandlers) HandleAlbums(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Default to the caller's own albums; other owners only expose their public albums.
+		ownerID := r.URL.Query().Get("owner_id")
+		if ownerID == "" {
+			ownerID = clientID
+		}
+		albums, err := h.Albums.ListAlbums(ownerID, ownerID == clientID)
+		if err != nil {
+			log.Printf("Error listing albums: %v", err)
+			http.Error(w, "Failed to list albums", http.StatusInternalServerError)
+			return
+		}
+		for i := range albums {
+			h.hideCoverIfPrivate(&albums[i], clientID)
+		}
+		utils.WriteJSON(w, http.StatusOK, albums)
+	case http.MethodPost:
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		input, err := Deserializers.DeserializeAlbumInput(bodyBytes)
+		if err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		album, err := h.Albums.CreateAlbum(clientID, input)
+		if err != nil {
+			writeAlbumError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusCreated, album)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAlbum fetches (GET), updates (PUT) or deletes (DELETE) the album given by ?id=.
+func (h *AlbumHandlers) HandleAlbum(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	albumID := r.URL.Query().Get("id")
+	if albumID == "" {
+		http.Error(w, "Album ID is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		album, ok := h.authorize(w, albumID, clientID, false)
+		if !ok {
+			return
+		}
+		h.hideCoverIfPrivate(album, clientID)
+		utils.WriteJSON(w, http.StatusOK, album)
+	case http.MethodPut:
+		if _, ok := h.authorize(w, albumID, clientID, true); !ok {
+			return
+		}
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		input, err := Deserializers.DeserializeAlbumInput(bodyBytes)
+		if err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		album, err := h.Albums.UpdateAlbum(albumID, input)
+		if err != nil {
+			writeAlbumError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, album)
+	case http.MethodDelete:
+		if _, ok := h.authorize(w, albumID, clientID, true); !ok {
+			return
+		}
+		if err := h.Albums.DeleteAlbum(albumID); err != nil {
+			writeAlbumError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAlbumImages lists (GET), adds (POST), reorders (PUT) or removes (DELETE) album images.
+func (h *AlbumHandlers) HandleAlbumImages(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		albumID := r.URL.Query().Get("album_id")
+		if albumID == "" {
+			http.Error(w, "Album ID is required", http.StatusBadRequest)
+			return
+		}
+		if _, ok := h.authorize(w, albumID, clientID, false); !ok {
+			return
+		}
+		images, err := h.Albums.ListImages(albumID)
+		if err != nil {
+			writeAlbumError(w, err)
+			return
+		}
+		// Album visibility does not override image privacy: private images of other owners are skipped.
+		visible := make([]models.AlbumImage, 0, len(images))
+		for _, image := range images {
+			if h.imageVisibleTo(image.ImageID, clientID) {
+				visible = append(visible, image)
+			}
+		}
+		utils.WriteJSON(w, http.StatusOK, visible)
+	case http.MethodDelete:
+		albumID := r.URL.Query().Get("album_id")
+		imageID := r.URL.Query().Get("image_id")
+		if albumID == "" || imageID == "" {
+			http.Error(w, "Album ID and image ID are required", http.StatusBadRequest)
+			return
+		}
+		if _, ok := h.authorize(w, albumID, clientID, true); !ok {
+			return
+		}
+		if err := h.Albums.RemoveImage(albumID, imageID); err != nil {
+			writeAlbumError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost, http.MethodPut:
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		input, err := Deserializers.DeserializeAlbumImagesInput(bodyBytes)
+		if err != nil || input.AlbumID == "" {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		if _, ok := h.authorize(w, input.AlbumID, clientID, true); !ok {
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			if err := h.Albums.ReorderImages(input.AlbumID, input.ImageIDs); err != nil {
+				writeAlbumError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if input.ImageID == "" {
+			http.Error(w, "Image ID is required", http.StatusBadRequest)
+			return
+		}
+		// Only images the caller can see may be added to an album.
+		if !h.imageVisibleTo(input.ImageID, clientID) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		member, err := h.Albums.AddImage(input.AlbumID, input.ImageID)
+		if err != nil {
+			writeAlbumError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusCreated, member)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAlbumStats returns the aggregated engagement of the album given by ?id=.
+func (h *AlbumHandlers) HandleAlbumStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	albumID := r.URL.Query().Get("id")
+	if albumID == "" {
+		http.Error(w, "Album ID is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.authorize(w, albumID, clientID, false); !ok {
+		return
+	}
+	stats, err := h.Albums.GetAlbumStats(albumID)
+	if err != nil {
+		writeAlbumError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, stats)
+}
+
+// authorize loads an album and checks the caller may see it (and, when requireOwner is set,
+// modify it). Private albums of other owners are reported as missing rather than forbidden.
+func (h *AlbumHandlers) authorize(w http.ResponseWriter, albumID, clientID string, requireOwner bool) (*models.Album, bool) {
+	album, err := h.Albums.GetAlbum(albumID)
+	if err != nil {
+		writeAlbumError(w, err)
+		return nil, false
+	}
+	isOwner := album.OwnerID == clientID
+	if album.IsPrivate && !isOwner {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return nil, false
+	}
+	if requireOwner && !isOwner {
+		http.Error(w, "Only the album owner can modify it", http.StatusForbidden)
+		return nil, false
+	}
+	return album, true
+}
+
+// imageVisibleTo reports whether an image may be shown to the caller, using the same
+// ownership and privacy rules as the image endpoints.
+func (h *AlbumHandlers) imageVisibleTo(imageID, clientID string) bool {
+	if h.ImageMetadataManager == nil {
+		return false
+	}
+	_, err := Metadata.AuthorizeImageAccess(h.ImageMetadataManager, imageID, clientID, false)
+	return err == nil
+}
+
+// hideCoverIfPrivate clears the cover when the caller may not see the cover image.
+func (h *AlbumHandlers) hideCoverIfPrivate(album *models.Album, clientID string) {
+	if album.CoverImageID != "" && !h.imageVisibleTo(album.CoverImageID, clientID) {
+		album.CoverImageID = ""
+	}
+}
+
+// writeAlbumError maps album manager errors onto HTTP status codes.
+func writeAlbumError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAlbumNotFound):
+		http.Error(w, "Album not found", http.StatusNotFound)
+	case errors.Is(err, ErrImageNotInAlbum), errors.Is(err, ErrInvalidOrder), errors.Is(err, ErrAlbumNameRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Album operation failed: %v", err)
+		http.Error(w, "Album operation failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,52 @@
+package AlbumManagers
+
+import (
+	"GOLA/commons"
+	"GOLA/commons/models"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAlbumNotFound is returned when an album does not exist.
+	ErrAlbumNotFound = errors.New("album not found")
+	// ErrImageNotInAlbum is returned when an image is not a member of the album.
+	ErrImageNotInAlbum = errors.New("image is not in album")
+	// ErrAlbumNameRequired is returned when an album is created or renamed without a name.
+	ErrAlbumNameRequired = errors.New("album name is required")
+	// ErrInvalidOrder is returned when a reorder request is not a permutation of the album images.
+	ErrInvalidOrder = errors.New("image order must list every album image exactly once")
+)
+
+// AlbumManager defines the required methods for managing albums and their images.
+type AlbumManager interface {
+	Initialize() error
+
+	CreateAlbum(ownerID string, input *commons.AlbumInputModel) (*models.Album, error)
+	GetAlbum(albumID string) (*models.Album, error)
+	UpdateAlbum(albumID string, input *commons.AlbumInputModel) (*models.Album, error)
+	DeleteAlbum(albumID string) error
+	// ListAlbums returns the albums of an owner, optionally including private ones.
+	ListAlbums(ownerID string, includePrivate bool) ([]models.Album, error)
+
+	// AddImage appends an image to the end of an album. Adding an existing member is a no-op.
+	AddImage(albumID, imageID string) (*models.AlbumImage, error)
+	RemoveImage(albumID, imageID string) error
+	// ReorderImages sets the album order to the given list of image IDs.
+	ReorderImages(albumID string, imageIDs []string) error
+	// ListImages returns the images of an album in album order.
+	ListImages(albumID string) ([]models.AlbumImage, error)
+
+	// GetAlbumStats aggregates the user events of every image in the album.
+	GetAlbumStats(albumID string) (*commons.AlbumStats, error)
+}
+
+// GetAlbumManager returns an instance of the requested album manager.
+func GetAlbumManager(storageType string) (AlbumManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresAlbumManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported album storage type: %s", storageType)
+	}
+}
@@ -0,0 +1,431 @@
+package AlbumManagers
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	"GOLA/commons"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// Erasure deletes the albums of the subject and removes their deleted images from every album.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "album_images", Table: "album_images",
+			Query: `DELETE FROM album_images WHERE image_id = ANY($1)`, Args: Erasure.ByImages},
+		Erasure.Step{Name: "albums", Table: "albums",
+			Query: `DELETE FROM albums WHERE owner_id = $1`, Args: Erasure.BySubject},
+	)
+}
+
+// albumColumns selects an album together with its image count.
+const albumColumns = `
+	a.id, a.owner_id, a.name, a.description, COALESCE(a.cover_image_id, ''), a.is_private,
+	(SELECT COUNT(*) FROM album_images ai WHERE ai.album_id = a.id), a.created_at, a.updated_at`
+
+// PostgresAlbumManager manages albums using PostgreSQL.
+type PostgresAlbumManager struct {
+	DB *sql.DB
+}
+
+// Initialize connects to the database (if needed) and ensures the album tables exist.
+func (p *PostgresAlbumManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS albums (
+		id UUID PRIMARY KEY,
+		owner_id VARCHAR(100) NOT NULL,
+		name VARCHAR(200) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		cover_image_id TEXT,
+		is_private BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS albums_owner_idx ON albums (owner_id);
+	CREATE TABLE IF NOT EXISTS album_images (
+		album_id UUID NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+		image_id TEXT NOT NULL,
+		position INT NOT NULL,
+		added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (album_id, image_id)
+	);
+	CREATE INDEX IF NOT EXISTS album_images_position_idx ON album_images (album_id, position);
+	`
+	_, err := p.DB.Exec(query)
+	return err
+}
+
+// CreateAlbum inserts a new, empty album owned by ownerID.
+func (p *PostgresAlbumManager) CreateAlbum(ownerID string, input *commons.AlbumInputModel) (*models.Album, error) {
+	if input.Name == nil || strings.TrimSpace(*input.Name) == "" {
+		return nil, ErrAlbumNameRequired
+	}
+	// A new album has no images, so it cannot have a cover yet.
+	if input.CoverImageID != nil && *input.CoverImageID != "" {
+		return nil, ErrImageNotInAlbum
+	}
+
+	album := &models.Album{
+		ID:        uuid.New(),
+		OwnerID:   ownerID,
+		Name:      strings.TrimSpace(*input.Name),
+		CreatedAt: time.Now(),
+	}
+	album.UpdatedAt = album.CreatedAt
+	if input.Description != nil {
+		album.Description = *input.Description
+	}
+	if input.IsPrivate != nil {
+		album.IsPrivate = *input.IsPrivate
+	}
+
+	query := `
+		INSERT INTO albums (id, owner_id, name, description, is_private, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := p.DB.Exec(query, album.ID, album.OwnerID, album.Name, album.Description,
+		album.IsPrivate, album.CreatedAt, album.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("error creating album: %w", err)
+	}
+	return album, nil
+}
+
+// GetAlbum fetches a single album.
+func (p *PostgresAlbumManager) GetAlbum(albumID string) (*models.Album, error) {
+	id, err := uuid.Parse(albumID)
+	if err != nil {
+		return nil, ErrAlbumNotFound
+	}
+	row := p.DB.QueryRow(`SELECT `+albumColumns+` FROM albums a WHERE a.id = $1`, id)
+	album, err := scanAlbum(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAlbumNotFound
+	}
+	return album, err
+}
+
+// UpdateAlbum applies the non-nil fields of input to an album.
+func (p *PostgresAlbumManager) UpdateAlbum(albumID string, input *commons.AlbumInputModel) (*models.Album, error) {
+	album, err := p.GetAlbum(albumID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		if strings.TrimSpace(*input.Name) == "" {
+			return nil, ErrAlbumNameRequired
+		}
+		album.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		album.Description = *input.Description
+	}
+	if input.IsPrivate != nil {
+		album.IsPrivate = *input.IsPrivate
+	}
+	if input.CoverImageID != nil {
+		// An empty cover clears it; otherwise the cover must be one of the album images.
+		if *input.CoverImageID != "" {
+			var exists bool
+			if err := p.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM album_images WHERE album_id = $1 AND image_id = $2)`,
+				album.ID, *input.CoverImageID).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("error checking cover image: %w", err)
+			}
+			if !exists {
+				return nil, ErrImageNotInAlbum
+			}
+		}
+		album.CoverImageID = *input.CoverImageID
+	}
+	album.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE albums
+		SET name = $2, description = $3, cover_image_id = NULLIF($4, ''), is_private = $5, updated_at = $6
+		WHERE id = $1
+	`
+	if _, err := p.DB.Exec(query, album.ID, album.Name, album.Description, album.CoverImageID,
+		album.IsPrivate, album.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("error updating album: %w", err)
+	}
+	return album, nil
+}
+
+// DeleteAlbum removes an album and its image memberships. The images themselves are kept.
+func (p *PostgresAlbumManager) DeleteAlbum(albumID string) error {
+	id, err := uuid.Parse(albumID)
+	if err != nil {
+		return ErrAlbumNotFound
+	}
+	result, err := p.DB.Exec(`DELETE FROM albums WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting album: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrAlbumNotFound
+	}
+	return nil
+}
+
+// ListAlbums returns the albums of an owner, most recently updated first.
+func (p *PostgresAlbumManager) ListAlbums(ownerID string, includePrivate bool) ([]models.Album, error) {
+	query := `SELECT ` + albumColumns + ` FROM albums a
+		WHERE a.owner_id = $1 AND ($2 OR NOT a.is_private)
+		ORDER BY a.updated_at DESC`
+	rows, err := p.DB.Query(query, ownerID, includePrivate)
+	if err != nil {
+		return nil, fmt.Errorf("error querying albums: %w", err)
+	}
+	defer rows.Close()
+
+	albums := []models.Album{}
+	for rows.Next() {
+		album, err := scanAlbum(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning album row: %w", err)
+		}
+		albums = append(albums, *album)
+	}
+	return albums, rows.Err()
+}
+
+// AddImage appends an image to the end of an album.
+func (p *PostgresAlbumManager) AddImage(albumID, imageID string) (*models.AlbumImage, error) {
+	album, err := p.GetAlbum(albumID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrent adds may compute the same position; ordering ties are broken by added_at.
+	query := `
+		INSERT INTO album_images (album_id, image_id, position, added_at)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(position), -1) + 1 FROM album_images WHERE album_id = $1), NOW())
+		ON CONFLICT (album_id, image_id) DO UPDATE SET album_id = EXCLUDED.album_id
+		RETURNING position, added_at
+	`
+	member := &models.AlbumImage{AlbumID: album.ID, ImageID: imageID}
+	if err := p.DB.QueryRow(query, album.ID, imageID).Scan(&member.Position, &member.AddedAt); err != nil {
+		return nil, fmt.Errorf("error adding image to album: %w", err)
+	}
+	p.touch(album.ID)
+	return member, nil
+}
+
+// RemoveImage removes an image from an album, clearing the cover if it was the cover image.
+func (p *PostgresAlbumManager) RemoveImage(albumID, imageID string) error {
+	album, err := p.GetAlbum(albumID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM album_images WHERE album_id = $1 AND image_id = $2`, album.ID, imageID)
+	if err != nil {
+		return fmt.Errorf("error removing image from album: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrImageNotInAlbum
+	}
+	if _, err := tx.Exec(`UPDATE albums SET cover_image_id = NULL, updated_at = NOW()
+		WHERE id = $1 AND cover_image_id = $2`, album.ID, imageID); err != nil {
+		return fmt.Errorf("error clearing album cover: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	p.touch(album.ID)
+	return nil
+}
+
+// ReorderImages rewrites the positions of all album images in a single transaction.
+func (p *PostgresAlbumManager) ReorderImages(albumID string, imageIDs []string) error {
+	album, err := p.GetAlbum(albumID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the memberships so a concurrent add cannot slip in between the check and the update.
+	rows, err := tx.Query(`SELECT image_id FROM album_images WHERE album_id = $1 FOR UPDATE`, album.ID)
+	if err != nil {
+		return fmt.Errorf("error reading album images: %w", err)
+	}
+	members := make(map[string]bool)
+	for rows.Next() {
+		var imageID string
+		if err := rows.Scan(&imageID); err != nil {
+			rows.Close()
+			return err
+		}
+		members[imageID] = true
+	}
+	rows.Close()
+
+	if len(imageIDs) != len(members) {
+		return ErrInvalidOrder
+	}
+	seen := make(map[string]bool, len(imageIDs))
+	for _, imageID := range imageIDs {
+		if !members[imageID] || seen[imageID] {
+			return ErrInvalidOrder
+		}
+		seen[imageID] = true
+	}
+
+	for position, imageID := range imageIDs {
+		if _, err := tx.Exec(`UPDATE album_images SET position = $3 WHERE album_id = $1 AND image_id = $2`,
+			album.ID, imageID, position); err != nil {
+			return fmt.Errorf("error reordering album images: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE albums SET updated_at = NOW() WHERE id = $1`, album.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListImages returns the images of an album in album order.
+func (p *PostgresAlbumManager) ListImages(albumID string) ([]models.AlbumImage, error) {
+	album, err := p.GetAlbum(albumID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := p.DB.Query(`
+		SELECT album_id, image_id, position, added_at
+		FROM album_images
+		WHERE album_id = $1
+		ORDER BY position ASC, added_at ASC
+	`, album.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying album images: %w", err)
+	}
+	defer rows.Close()
+
+	images := []models.AlbumImage{}
+	for rows.Next() {
+		var image models.AlbumImage
+		if err := rows.Scan(&image.AlbumID, &image.ImageID, &image.Position, &image.AddedAt); err != nil {
+			return nil, fmt.Errorf("error scanning album image row: %w", err)
+		}
+		images = append(images, image)
+	}
+	return images, rows.Err()
+}
+
+// GetAlbumStats aggregates the engagement of every image in the album.
+func (p *PostgresAlbumManager) GetAlbumStats(albumID string) (*commons.AlbumStats, error) {
+	album, err := p.GetAlbum(albumID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &commons.AlbumStats{ImageCount: album.ImageCount}
+
+	// Reactions and comments are counted from their current state, like the per-image stats,
+	// and views include those archived by retention.
+	rows, err := p.DB.Query(`
+		SELECT 'user-view', (
+			SELECT COUNT(*)
+			FROM user_events e
+			JOIN album_images ai ON ai.image_id = e.target_id
+			WHERE ai.album_id = $1 AND e.event_type = 'user-view'
+		) + (
+			SELECT COALESCE(SUM(ac.count), 0)
+			FROM archived_event_counts ac
+			JOIN album_images ai ON ai.image_id = ac.target_id
+			WHERE ai.album_id = $1 AND ac.event_type = 'user-view'
+		)
+		UNION ALL
+		SELECT ur.reaction, COUNT(*)
+		FROM user_reactions ur
+		JOIN album_images ai ON ai.image_id = ur.target_id
+		WHERE ai.album_id = $1
+		GROUP BY ur.reaction
+		UNION ALL
+		SELECT 'user-comment', COUNT(*)
+		FROM comments c
+		JOIN album_images ai ON ai.image_id = c.target_id
+		WHERE ai.album_id = $1 AND c.deleted_at IS NULL AND c.state <> 'hidden'
+	`, album.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying album stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var eventType string
+		var count int
+		if err := rows.Scan(&eventType, &count); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		switch eventType {
+		case "user-like":
+			stats.Likes = count
+		case "user-dislike":
+			stats.Dislikes = count
+		case "user-view":
+			stats.Views = count
+		case "user-comment":
+			stats.Comments = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := p.DB.QueryRow(`
+		SELECT COUNT(DISTINCT e.user_id)
+		FROM user_events e
+		JOIN album_images ai ON ai.image_id = e.target_id
+		WHERE ai.album_id = $1
+	`, album.ID).Scan(&stats.UniqueUsers); err != nil {
+		return nil, fmt.Errorf("error querying album unique users: %w", err)
+	}
+	return stats, nil
+}
+
+// touch bumps the updated_at timestamp of an album after its images changed.
+func (p *PostgresAlbumManager) touch(albumID uuid.UUID) {
+	p.DB.Exec(`UPDATE albums SET updated_at = NOW() WHERE id = $1`, albumID)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAlbum(row rowScanner) (*models.Album, error) {
+	var album models.Album
+	if err := row.Scan(&album.ID, &album.OwnerID, &album.Name, &album.Description, &album.CoverImageID,
+		&album.IsPrivate, &album.ImageCount, &album.CreatedAt, &album.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
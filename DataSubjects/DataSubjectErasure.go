@@ -0,0 +1,94 @@
+package DataSubjects
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/commons/models"
+	"context"
+	"fmt"
+	"log"
+)
+
+// erase deletes the images of the subject and runs the erase steps every package registered
+// with Erasure for its tables, in one transaction: albums, share links and comments are
+// deleted, while events and reactions keep counting in the stats of their targets, but under a
+// pseudonym derived from the request instead of the user ID. Earlier export archives of the
+// subject are deleted as well.
+//
+// Event archives written by retention are not rewritten; anyone restoring them must apply the
+// erasures recorded in data_subject_requests.
+func (p *PostgresDataSubjectManager) erase(ctx context.Context, request *models.DataSubjectRequest) (map[string]int, error) {
+	summary := map[string]int{}
+	subjectID, pseudonym := request.SubjectID, "erased:"+request.ID
+
+	// Buffered events of the subject must be in the table to be anonymised.
+	if err := p.Events.SaveEvents(); err != nil {
+		return nil, fmt.Errorf("error flushing events: %w", err)
+	}
+
+	images, err := p.ownedImages(ctx, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	change := Metadata.MetadataChange{ActorID: request.RequestedBy, Source: Metadata.SourceErasure}
+	for _, imageID := range images {
+		if err := p.Images.DeleteImage(imageID); err != nil {
+			return nil, fmt.Errorf("error deleting image %s: %w", imageID, err)
+		}
+		if err := p.Metadata.DeleteImageMetadata(imageID, change); err != nil {
+			return nil, fmt.Errorf("error deleting metadata of %s: %w", imageID, err)
+		}
+		if p.OnImageErased != nil {
+			p.OnImageErased(imageID, request.RequestedBy)
+		}
+	}
+	summary["images"] = len(images)
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	subject := Erasure.Subject{ID: subjectID, Pseudonym: pseudonym, Images: images}
+	for _, step := range Erasure.Steps() {
+		result, err := tx.ExecContext(ctx, step.Query, step.Args(subject)...)
+		if err != nil {
+			return nil, fmt.Errorf("error erasing %s: %w", step.Name, err)
+		}
+		affected, _ := result.RowsAffected()
+		summary[step.Name] += int(affected)
+	}
+
+	// Exports of the subject would otherwise outlive the erasure.
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE data_subject_requests SET archive_key = ''
+		WHERE subject_id = $1 AND kind = $2 AND archive_key <> ''
+		RETURNING archive_key
+	`, subjectID, models.DataSubjectExport)
+	if err != nil {
+		return nil, fmt.Errorf("error clearing exports: %w", err)
+	}
+	var exports []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		exports = append(exports, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, key := range exports {
+		if err := p.Images.DeleteImage(key); err != nil {
+			log.Printf("Error deleting export archive %s: %v", key, err)
+		}
+	}
+	summary["exports"] = len(exports)
+	return summary, nil
+}
@@ -0,0 +1,94 @@
+package DataSubjects_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	_ "GOLA/AlbumManagers"
+	"GOLA/DataSubjects/Erasure"
+	_ "GOLA/ImageManagers/Metadata"
+	_ "GOLA/Mentions"
+	_ "GOLA/Moderation"
+	_ "GOLA/Notifications"
+	_ "GOLA/ShareLinkManagers"
+	_ "GOLA/StatsRollups"
+	_ "GOLA/Trending"
+	_ "GOLA/UserEventManagers"
+	_ "GOLA/Webhooks"
+)
+
+// userColumns are the columns that hold user IDs.
+var userColumns = map[string]bool{
+	"user_id": true, "author_id": true, "owner_id": true, "actor_id": true, "actor_ids": true,
+	"recipient_id": true, "reporter_id": true, "reviewer_id": true, "mentioned_user_id": true,
+	"client_id": true, "edited_by": true, "subject_id": true, "requested_by": true,
+	"scope_id": true,
+}
+
+// keptTables hold user IDs on purpose and are not erased.
+var keptTables = map[string]string{
+	"data_subject_requests": "records the requests themselves",
+	"data_subject_audit":    "is the audit trail of the requests",
+}
+
+var tablePattern = regexp.MustCompile("(?s)CREATE TABLE IF NOT EXISTS (\\w+) \\((.*?)\n\\s*\\)[;`]")
+
+// schemaSources returns the schema file and the Go sources of the service, since some
+// packages create their tables from Go code only.
+func schemaSources(t *testing.T) []byte {
+	schema, err := os.ReadFile("../schemas/user_events.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.WalkDir("..", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		source, err := os.ReadFile(path)
+		schema = append(schema, source...)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// TestEraseStepsCoverSchema fails when a table of the schema holds user IDs but no package
+// registered an erase step for it.
+func TestEraseStepsCoverSchema(t *testing.T) {
+	schema := schemaSources(t)
+	erased := map[string]bool{}
+	for _, step := range Erasure.Steps() {
+		erased[step.Table] = true
+	}
+
+	seen := map[string]bool{}
+	for _, match := range tablePattern.FindAllStringSubmatch(string(schema), -1) {
+		table, body := match[1], match[2]
+		// Tables defined both in the schema file and in Go code are checked once.
+		_, kept := keptTables[table]
+		checked := seen[table]
+		seen[table] = true
+		if kept || checked || erased[table] {
+			continue
+		}
+		for _, line := range strings.Split(body, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 0 && userColumns[fields[0]] {
+				t.Errorf("%s holds user IDs in %s, but no erase step is registered for it", table, fields[0])
+				break
+			}
+		}
+	}
+	// Tables created from Go code only must be scanned as well.
+	for _, table := range []string{"albums", "share_links", "image_metadata_history"} {
+		if !seen[table] {
+			t.Errorf("the scan did not find the table %s", table)
+		}
+	}
+}
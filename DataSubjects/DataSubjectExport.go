@@ -0,0 +1,160 @@
+package DataSubjects
+
+import (
+	userEventsManager "GOLA/UserEventManagers"
+	"GOLA/commons"
+	"GOLA/commons/models"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// exportPrefix is where export archives are stored, followed by <request id>.zip.
+const exportPrefix = "data-exports/"
+
+// export bundles the data of the subject into a zip archive and stores it next to the images:
+//
+//	request.json             the request being served
+//	events.ndjson            every event of the subject, oldest first
+//	reactions.json           the subject's current reactions
+//	comments.json            the subject's comments, with comment_revisions.json their edits
+//	albums.json              the subject's albums and their images
+//	share_links.json         the share links the subject created
+//	images/<id>/metadata.json, images/<id>/history.json and images/<id>/original
+//	                         every image the subject owns
+func (p *PostgresDataSubjectManager) export(ctx context.Context, request *models.DataSubjectRequest) (map[string]int, string, error) {
+	summary := map[string]int{}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	if err := writeJSONFile(archive, "request.json", request); err != nil {
+		return nil, "", err
+	}
+
+	file, err := archive.Create("events.ndjson")
+	if err != nil {
+		return nil, "", err
+	}
+	writer, _ := userEventsManager.NewEventWriter(userEventsManager.ExportNDJSON, file)
+	filter := commons.EventFilter{UserID: request.SubjectID, Ascending: true}
+	if summary["events"], err = p.Events.ExportEvents(ctx, filter, writer.Write); err != nil {
+		return nil, "", fmt.Errorf("error exporting events: %w", err)
+	}
+
+	tables := []struct {
+		name  string
+		query string
+	}{
+		{"reactions", `SELECT target_id, reaction, created_at, updated_at FROM user_reactions WHERE user_id = $1 ORDER BY created_at`},
+		{"comments", `SELECT id, target_id, parent_id, content, state, created_at, edited_at, deleted_at
+			FROM comments WHERE author_id = $1 ORDER BY id`},
+		{"comment_revisions", `SELECT r.comment_id, r.revision, r.content, r.created_at
+			FROM comment_revisions r JOIN comments c ON c.id = r.comment_id
+			WHERE c.author_id = $1 ORDER BY r.comment_id, r.revision`},
+		{"albums", `SELECT a.id, a.name, a.description, a.cover_image_id, a.is_private, a.created_at, a.updated_at,
+				COALESCE((SELECT json_agg(ai.image_id ORDER BY ai.position) FROM album_images ai WHERE ai.album_id = a.id), '[]') AS images
+			FROM albums a WHERE a.owner_id = $1 ORDER BY a.created_at`},
+		{"share_links", `SELECT id, image_id, expires_at, max_views, view_count, revoked_at, created_at
+			FROM share_links WHERE owner_id = $1 ORDER BY created_at`},
+	}
+	for _, table := range tables {
+		rows, count, err := p.queryJSON(ctx, table.query, request.SubjectID)
+		if err != nil {
+			return nil, "", fmt.Errorf("error exporting %s: %w", table.name, err)
+		}
+		file, err := archive.Create(table.name + ".json")
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := file.Write(rows); err != nil {
+			return nil, "", err
+		}
+		summary[table.name] = count
+	}
+
+	images, err := p.ownedImages(ctx, request.SubjectID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, imageID := range images {
+		dir := "images/" + imageID + "/"
+		metadata, err := p.Metadata.GetImageMetadata(imageID)
+		if err != nil {
+			return nil, "", fmt.Errorf("error exporting metadata of %s: %w", imageID, err)
+		}
+		if err := writeJSONFile(archive, dir+"metadata.json", metadata); err != nil {
+			return nil, "", err
+		}
+		history, err := p.Metadata.GetImageMetadataHistory(imageID)
+		if err != nil {
+			return nil, "", fmt.Errorf("error exporting metadata history of %s: %w", imageID, err)
+		}
+		if err := writeJSONFile(archive, dir+"history.json", history); err != nil {
+			return nil, "", err
+		}
+		original, err := p.Images.FetchImage(imageID)
+		if err != nil {
+			// The metadata is still worth exporting when the stored file is gone.
+			log.Printf("Error fetching image %s for export %s: %v", imageID, request.ID, err)
+			continue
+		}
+		file, err := archive.Create(dir + "original")
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := file.Write(original); err != nil {
+			return nil, "", err
+		}
+	}
+	summary["images"] = len(images)
+
+	if err := archive.Close(); err != nil {
+		return nil, "", err
+	}
+	key := exportPrefix + request.ID + ".zip"
+	if err := p.Images.UploadImage(key, buf.Bytes()); err != nil {
+		return nil, "", fmt.Errorf("error storing export archive: %w", err)
+	}
+	return summary, key, nil
+}
+
+// queryJSON runs a query returning rows and returns them as a JSON array, with their count.
+func (p *PostgresDataSubjectManager) queryJSON(ctx context.Context, query string, args ...interface{}) ([]byte, int, error) {
+	var rows []byte
+	var count int
+	err := p.DB.QueryRowContext(ctx, `SELECT COALESCE(json_agg(t), '[]'), COUNT(*) FROM (`+query+`) t`, args...).Scan(&rows, &count)
+	return rows, count, err
+}
+
+// ownedImages returns the IDs of the images owned by the subject.
+func (p *PostgresDataSubjectManager) ownedImages(ctx context.Context, subjectID string) ([]string, error) {
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT image_id FROM image_metadata WHERE metadata->>'owner_id' = $1 ORDER BY image_id
+	`, subjectID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying owned images: %w", err)
+	}
+	defer rows.Close()
+	var images []string
+	for rows.Next() {
+		var imageID string
+		if err := rows.Scan(&imageID); err != nil {
+			return nil, err
+		}
+		images = append(images, imageID)
+	}
+	return images, rows.Err()
+}
+
+func writeJSONFile(archive *zip.Writer, name string, value interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,131 @@
+package DataSubjects
+
+import (
+	"GOLA/commons/models"
+	"GOLA/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+// DataSubjectHandlers exposes data subject requests over HTTP. Users may file requests about
+// themselves; admins may file them about anyone. Every handler expects the JWT middleware to
+// have put the caller's client ID into the request context.
+type DataSubjectHandlers struct {
+	Requests DataSubjectManager
+}
+
+type requestInput struct {
+	Kind      string `json:"kind"`
+	SubjectID string `json:"subject_id"`
+}
+
+// HandleRequests queues an export or erasure (POST {"kind": "export"|"erasure", "subject_id"};
+// the subject defaults to the caller) or returns the status of the request given by ?id= (GET).
+func (h *DataSubjectHandlers) HandleRequests(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		var input requestInput
+		if err := json.Unmarshal(body, &input); err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		if input.SubjectID == "" {
+			input.SubjectID = clientID
+		}
+		if input.SubjectID != clientID && !utils.IsAdmin(clientID) {
+			http.Error(w, "Only admins can file requests about other users", http.StatusForbidden)
+			return
+		}
+		request, err := h.Requests.CreateRequest(r.Context(), input.Kind, input.SubjectID, clientID)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusAccepted, request)
+	case http.MethodGet:
+		request, ok := h.authorize(w, r, clientID)
+		if !ok {
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, request)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleDownload returns the archive of the completed export given by ?id=.
+func (h *DataSubjectHandlers) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	request, ok := h.authorize(w, r, clientID)
+	if !ok {
+		return
+	}
+	archive, err := h.Requests.FetchExport(r.Context(), request.ID, clientID)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="data-export-`+request.ID+`.zip"`)
+	if _, err := w.Write(archive); err != nil {
+		log.Printf("Error writing export archive: %v", err)
+	}
+}
+
+// authorize loads the request given by ?id= and checks the caller is its subject, the user
+// who filed it, or an admin. Only admins see the audit trail.
+func (h *DataSubjectHandlers) authorize(w http.ResponseWriter, r *http.Request, clientID string) (*models.DataSubjectRequest, bool) {
+	requestID := r.URL.Query().Get("id")
+	if requestID == "" {
+		http.Error(w, "Request ID is required", http.StatusBadRequest)
+		return nil, false
+	}
+	isAdmin := utils.IsAdmin(clientID)
+	request, err := h.Requests.GetRequest(r.Context(), requestID, isAdmin)
+	if err != nil {
+		writeRequestError(w, err)
+		return nil, false
+	}
+	if !isAdmin && request.SubjectID != clientID && request.RequestedBy != clientID {
+		// Do not reveal requests about other users.
+		http.Error(w, "Request not found", http.StatusNotFound)
+		return nil, false
+	}
+	return request, true
+}
+
+func writeRequestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRequestNotFound):
+		http.Error(w, "Request not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrExportNotReady):
+		http.Error(w, "Export is not ready", http.StatusConflict)
+	default:
+		log.Printf("Data subject request error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,55 @@
+package DataSubjects
+
+import (
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/ImageManagers/RawStore"
+	userEventsManager "GOLA/UserEventManagers"
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrRequestNotFound is returned when a data subject request does not exist.
+	ErrRequestNotFound = errors.New("data subject request not found")
+	// ErrInvalidRequest is returned for unknown request kinds and missing subjects.
+	ErrInvalidRequest = errors.New("invalid data subject request")
+	// ErrExportNotReady is returned when downloading an export that has not completed.
+	ErrExportNotReady = errors.New("export is not ready")
+)
+
+// Stores are the stores holding the data of a subject.
+type Stores struct {
+	Events   userEventsManager.EventManager
+	Images   RawStore.ImageStoreManager
+	Metadata Metadata.ImageMetadataManager
+	// OnImageErased, when set, is called for every erased image, e.g. to update the search index.
+	OnImageErased func(imageID, actorID string)
+}
+
+// DataSubjectManager runs data subject requests: exports bundle every piece of data of a user
+// into a downloadable archive, erasures delete the user's images and anonymise their events.
+// Requests are queued and processed in the background, and every step is audited.
+type DataSubjectManager interface {
+	Initialize() error
+	// CreateRequest queues a request, or returns the one already queued for the same subject
+	// and kind.
+	CreateRequest(ctx context.Context, kind, subjectID, requestedBy string) (*models.DataSubjectRequest, error)
+	// GetRequest returns a request, with its audit trail when withAudit is set.
+	GetRequest(ctx context.Context, requestID string, withAudit bool) (*models.DataSubjectRequest, error)
+	// ProcessPending runs the queued requests; run it periodically.
+	ProcessPending(ctx context.Context) error
+	// FetchExport returns the archive of a completed export and audits the download.
+	FetchExport(ctx context.Context, requestID, actorID string) ([]byte, error)
+}
+
+// GetDataSubjectManager returns an instance of the requested data subject manager.
+func GetDataSubjectManager(storageType string, stores Stores) (DataSubjectManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresDataSubjectManager{Stores: stores}, nil
+	default:
+		return nil, fmt.Errorf("unsupported data subject storage type: %s", storageType)
+	}
+}
@@ -0,0 +1,71 @@
+// Package Erasure is the registry of the steps that erase a data subject. Every package that
+// stores personal data of users registers the steps for its tables from an init function next
+// to the table definitions, so a new table cannot hold on to the data of erased users unseen.
+// The package imports nothing of the service, so that every package can register.
+package Erasure
+
+import (
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// Subject is the data subject an erasure works on.
+type Subject struct {
+	ID string
+	// Pseudonym replaces the ID in data that keeps counting after the erasure, such as events.
+	Pseudonym string
+	// Images are the IDs of the subject's images, which the erasure has deleted.
+	Images []string
+}
+
+// Step erases or pseudonymises the data of the subject held in one table. Steps run in the
+// registration order, in the transaction of the erasure.
+type Step struct {
+	// Name is the key of the number of affected rows in the erasure summary.
+	Name string
+	// Table is the table the step erases.
+	Table string
+	Query string
+	// Args returns the arguments of Query for the subject.
+	Args func(subject Subject) []interface{}
+}
+
+var (
+	mu    sync.Mutex
+	steps []Step
+)
+
+// Register adds steps to every erasure.
+func Register(registered ...Step) {
+	mu.Lock()
+	defer mu.Unlock()
+	steps = append(steps, registered...)
+}
+
+// Steps returns the registered steps in registration order.
+func Steps() []Step {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Step(nil), steps...)
+}
+
+// BySubject passes the subject's ID as $1.
+func BySubject(subject Subject) []interface{} {
+	return []interface{}{subject.ID}
+}
+
+// ByPseudonym passes the subject's ID as $1 and the pseudonym as $2.
+func ByPseudonym(subject Subject) []interface{} {
+	return []interface{}{subject.ID, subject.Pseudonym}
+}
+
+// ByImages passes the IDs of the subject's images as $1.
+func ByImages(subject Subject) []interface{} {
+	return []interface{}{pq.Array(subject.Images)}
+}
+
+// BySubjectAndImages passes the subject's ID as $1 and the IDs of their images as $2.
+func BySubjectAndImages(subject Subject) []interface{} {
+	return []interface{}{subject.ID, pq.Array(subject.Images)}
+}
@@ -0,0 +1,292 @@
+package DataSubjects
+
+import (
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// staleAfter is how long a request may run before it is considered abandoned, e.g. by an
+// instance that crashed, and is run again. Both kinds of requests can safely run twice.
+const staleAfter = time.Hour
+
+const requestColumns = `id, kind, subject_id, requested_by, status, error, summary, created_at, started_at, completed_at`
+
+// PostgresDataSubjectManager keeps data subject requests and their audit trail in PostgreSQL
+// and reads the data of subjects directly from the tables of the other managers.
+type PostgresDataSubjectManager struct {
+	DB *sql.DB
+	Stores
+}
+
+// Initialize connects to the database (if needed) and ensures the request tables exist.
+func (p *PostgresDataSubjectManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS data_subject_requests (
+		id UUID PRIMARY KEY,
+		kind VARCHAR(20) NOT NULL,
+		subject_id VARCHAR(100) NOT NULL,
+		requested_by VARCHAR(100) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		summary JSONB,
+		archive_key TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		started_at TIMESTAMP,
+		completed_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS data_subject_requests_status_idx ON data_subject_requests (status, created_at);
+	CREATE INDEX IF NOT EXISTS data_subject_requests_subject_idx ON data_subject_requests (subject_id, kind);
+	CREATE TABLE IF NOT EXISTS data_subject_audit (
+		id BIGSERIAL PRIMARY KEY,
+		request_id UUID NOT NULL REFERENCES data_subject_requests(id),
+		action VARCHAR(50) NOT NULL,
+		actor_id VARCHAR(100) NOT NULL,
+		detail JSONB,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS data_subject_audit_request_idx ON data_subject_audit (request_id, id);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating data subject tables: %w", err)
+	}
+	return nil
+}
+
+// CreateRequest queues an export or erasure of the data of subjectID.
+func (p *PostgresDataSubjectManager) CreateRequest(ctx context.Context, kind, subjectID, requestedBy string) (*models.DataSubjectRequest, error) {
+	if kind != models.DataSubjectExport && kind != models.DataSubjectErasure {
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidRequest, kind)
+	}
+	if subjectID == "" {
+		return nil, fmt.Errorf("%w: subject is required", ErrInvalidRequest)
+	}
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Serialize requests of a subject so two clicks do not queue two requests.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "data-subject:"+subjectID); err != nil {
+		return nil, err
+	}
+	existing, err := scanRequest(tx.QueryRowContext(ctx, `
+		SELECT `+requestColumns+` FROM data_subject_requests
+		WHERE subject_id = $1 AND kind = $2 AND status IN ('pending', 'running')
+		ORDER BY created_at LIMIT 1
+	`, subjectID, kind))
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, ErrRequestNotFound) {
+		return nil, err
+	}
+
+	request, err := scanRequest(tx.QueryRowContext(ctx, `
+		INSERT INTO data_subject_requests (id, kind, subject_id, requested_by, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+requestColumns,
+		uuid.New(), kind, subjectID, requestedBy, models.RequestPending))
+	if err != nil {
+		return nil, fmt.Errorf("error creating data subject request: %w", err)
+	}
+	if err := audit(ctx, tx, request.ID, "requested", requestedBy, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetRequest returns a request, with its audit trail when withAudit is set.
+func (p *PostgresDataSubjectManager) GetRequest(ctx context.Context, requestID string, withAudit bool) (*models.DataSubjectRequest, error) {
+	if _, err := uuid.Parse(requestID); err != nil {
+		return nil, ErrRequestNotFound
+	}
+	request, err := scanRequest(p.DB.QueryRowContext(ctx,
+		`SELECT `+requestColumns+` FROM data_subject_requests WHERE id = $1`, requestID))
+	if err != nil || !withAudit {
+		return request, err
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT action, actor_id, detail, created_at FROM data_subject_audit
+		WHERE request_id = $1 ORDER BY id
+	`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit trail: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry models.DataSubjectAuditEntry
+		var detail []byte
+		if err := rows.Scan(&entry.Action, &entry.ActorID, &detail, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %w", err)
+		}
+		if detail != nil {
+			if err := json.Unmarshal(detail, &entry.Detail); err != nil {
+				return nil, fmt.Errorf("error decoding audit detail: %w", err)
+			}
+		}
+		request.Audit = append(request.Audit, entry)
+	}
+	return request, rows.Err()
+}
+
+// ProcessPending runs queued requests one at a time, oldest first, until none is left. Each
+// request is claimed with SKIP LOCKED, so several instances can share the queue.
+func (p *PostgresDataSubjectManager) ProcessPending(ctx context.Context) error {
+	for {
+		request, err := scanRequest(p.DB.QueryRowContext(ctx, `
+			UPDATE data_subject_requests SET status = $1, started_at = NOW()
+			WHERE id = (
+				SELECT id FROM data_subject_requests
+				WHERE status = $2 OR (status = $1 AND started_at < NOW()::timestamp - make_interval(secs => $3))
+				ORDER BY created_at
+				FOR UPDATE SKIP LOCKED
+				LIMIT 1
+			)
+			RETURNING `+requestColumns,
+			models.RequestRunning, models.RequestPending, staleAfter.Seconds()))
+		if errors.Is(err, ErrRequestNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error claiming data subject request: %w", err)
+		}
+		if err := audit(ctx, p.DB, request.ID, "started", "system", nil); err != nil {
+			return err
+		}
+		p.run(ctx, request)
+	}
+}
+
+// run executes a claimed request and records its outcome.
+func (p *PostgresDataSubjectManager) run(ctx context.Context, request *models.DataSubjectRequest) {
+	var summary map[string]int
+	var archiveKey string
+	var err error
+	switch request.Kind {
+	case models.DataSubjectExport:
+		summary, archiveKey, err = p.export(ctx, request)
+	case models.DataSubjectErasure:
+		summary, err = p.erase(ctx, request)
+	default:
+		err = fmt.Errorf("%w: unknown kind %q", ErrInvalidRequest, request.Kind)
+	}
+
+	status, action, message := models.RequestCompleted, "completed", ""
+	if err != nil {
+		log.Printf("Data subject %s %s failed: %v", request.Kind, request.ID, err)
+		status, action, message = models.RequestFailed, "failed", err.Error()
+	} else {
+		log.Printf("Data subject %s %s completed: %v", request.Kind, request.ID, summary)
+	}
+	summaryJSON, _ := json.Marshal(summary)
+	_, dbErr := p.DB.ExecContext(ctx, `
+		UPDATE data_subject_requests
+		SET status = $2, error = $3, summary = $4, archive_key = $5, completed_at = NOW()
+		WHERE id = $1
+	`, request.ID, status, message, summaryJSON, archiveKey)
+	if dbErr == nil {
+		dbErr = audit(ctx, p.DB, request.ID, action, "system", summary)
+	}
+	if dbErr != nil {
+		log.Printf("Error recording outcome of data subject request %s: %v", request.ID, dbErr)
+	}
+}
+
+// FetchExport returns the archive of a completed export and audits the download.
+func (p *PostgresDataSubjectManager) FetchExport(ctx context.Context, requestID, actorID string) ([]byte, error) {
+	if _, err := uuid.Parse(requestID); err != nil {
+		return nil, ErrRequestNotFound
+	}
+	var kind, status, key string
+	err := p.DB.QueryRowContext(ctx, `SELECT kind, status, archive_key FROM data_subject_requests WHERE id = $1`,
+		requestID).Scan(&kind, &status, &key)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && kind != models.DataSubjectExport) {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != models.RequestCompleted || key == "" {
+		return nil, ErrExportNotReady
+	}
+	archive, err := p.Images.FetchImage(key)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching export archive: %w", err)
+	}
+	if err := audit(ctx, p.DB, requestID, "downloaded", actorID, nil); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// audit appends an entry to the audit trail of a request.
+func audit(ctx context.Context, db execer, requestID, action, actorID string, detail map[string]int) error {
+	var detailJSON interface{}
+	if detail != nil {
+		detailJSON, _ = json.Marshal(detail)
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO data_subject_audit (request_id, action, actor_id, detail) VALUES ($1, $2, $3, $4)
+	`, requestID, action, actorID, detailJSON)
+	if err != nil {
+		return fmt.Errorf("error auditing data subject request: %w", err)
+	}
+	return nil
+}
+
+func scanRequest(row *sql.Row) (*models.DataSubjectRequest, error) {
+	var request models.DataSubjectRequest
+	var summary []byte
+	var startedAt, completedAt sql.NullTime
+	err := row.Scan(&request.ID, &request.Kind, &request.SubjectID, &request.RequestedBy, &request.Status,
+		&request.Error, &summary, &request.CreatedAt, &startedAt, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning data subject request: %w", err)
+	}
+	if summary != nil {
+		if err := json.Unmarshal(summary, &request.Summary); err != nil {
+			return nil, fmt.Errorf("error decoding request summary: %w", err)
+		}
+	}
+	if startedAt.Valid {
+		request.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		request.CompletedAt = &completedAt.Time
+	}
+	return &request, nil
+}
@@ -0,0 +1,25 @@
+package Deserializers
+
+import (
+	"GOLA/commons"
+	"encoding/json"
+	"fmt"
+)
+
+// DeserializeAlbumInput takes a JSON byte slice and returns an AlbumInputModel.
+func DeserializeAlbumInput(data []byte) (*commons.AlbumInputModel, error) {
+	var input commons.AlbumInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize album input: %w", err)
+	}
+	return &input, nil
+}
+
+// DeserializeAlbumImagesInput takes a JSON byte slice and returns an AlbumImagesInputModel.
+func DeserializeAlbumImagesInput(data []byte) (*commons.AlbumImagesInputModel, error) {
+	var input commons.AlbumImagesInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize album images input: %w", err)
+	}
+	return &input, nil
+}
@@ -0,0 +1,25 @@
+package Deserializers
+
+import (
+	"GOLA/commons"
+	"encoding/json"
+	"fmt"
+)
+
+// DeserializeCommentInput takes a JSON byte slice and returns a CommentInputModel.
+func DeserializeCommentInput(data []byte) (*commons.CommentInputModel, error) {
+	var input commons.CommentInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize comment input: %w", err)
+	}
+	return &input, nil
+}
+
+// DeserializeCommentUpdateInput takes a JSON byte slice and returns a CommentUpdateInputModel.
+func DeserializeCommentUpdateInput(data []byte) (*commons.CommentUpdateInputModel, error) {
+	var input commons.CommentUpdateInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize comment update input: %w", err)
+	}
+	return &input, nil
+}
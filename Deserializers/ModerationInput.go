@@ -0,0 +1,25 @@
+package Deserializers
+
+import (
+	"GOLA/commons"
+	"encoding/json"
+	"fmt"
+)
+
+// DeserializeModerationReportInput takes a JSON byte slice and returns a ModerationReportInputModel.
+func DeserializeModerationReportInput(data []byte) (*commons.ModerationReportInputModel, error) {
+	var input commons.ModerationReportInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize moderation report input: %w", err)
+	}
+	return &input, nil
+}
+
+// DeserializeModerationReviewInput takes a JSON byte slice and returns a ModerationReviewInputModel.
+func DeserializeModerationReviewInput(data []byte) (*commons.ModerationReviewInputModel, error) {
+	var input commons.ModerationReviewInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize moderation review input: %w", err)
+	}
+	return &input, nil
+}
@@ -0,0 +1,16 @@
+package Deserializers
+
+import (
+	"GOLA/commons"
+	"encoding/json"
+	"fmt"
+)
+
+// DeserializeShareLinkInput takes a JSON byte slice and returns a ShareLinkInputModel.
+func DeserializeShareLinkInput(data []byte) (*commons.ShareLinkInputModel, error) {
+	var input commons.ShareLinkInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize share link input: %w", err)
+	}
+	return &input, nil
+}
@@ -0,0 +1,16 @@
+package Deserializers
+
+import (
+	"GOLA/commons"
+	"encoding/json"
+	"fmt"
+)
+
+// DeserializeWebhookInput takes a JSON byte slice and returns a WebhookInputModel.
+func DeserializeWebhookInput(data []byte) (*commons.WebhookInputModel, error) {
+	var input commons.WebhookInputModel
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to deserialize webhook input: %w", err)
+	}
+	return &input, nil
+}
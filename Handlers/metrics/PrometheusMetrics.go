@@ -31,3 +31,11 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 	rw.StatusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Flush passes flushes through, so streaming handlers such as Server-Sent Events work when
+// wrapped.
+func (rw *ResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
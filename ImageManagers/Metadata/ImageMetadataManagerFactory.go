@@ -1,6 +1,7 @@
 package Metadata
 
 import (
+	dbCommons "GOLA/commons/db"
 	"errors"
 )
 
@@ -8,15 +9,31 @@ import (
 type ImageMetadataManager interface {
 	Initialize() error
 	GetImageMetadata(imageID string) (map[string]string, error) /* THIS DOES NOT NEED TO BE DONE BY KAFKA */
-	SetImageMetadata(imageID string, metadata map[string]string) error
-	DeleteImageMetadata(imageID string) error
+	// SetImageMetadata replaces the metadata of an image, recording the change in its history.
+	SetImageMetadata(imageID string, metadata map[string]string, change MetadataChange) error
+	DeleteImageMetadata(imageID string, change MetadataChange) error
+	// ListImageMetadata returns the metadata of every known image keyed by image ID.
+	ListImageMetadata() (map[string]map[string]string, error)
+	// GetImageMetadataHistory returns the recorded revisions of an image, oldest first.
+	GetImageMetadataHistory(imageID string) ([]MetadataRevision, error)
+	// RevertImageMetadata restores the metadata as it was after the given revision.
+	RevertImageMetadata(imageID string, revision int, change MetadataChange) (map[string]string, error)
 }
 
 // GetImageMetadataManager returns an instance of the requested image metadata manager.
 func GetImageMetadataManager(storageType string) (ImageMetadataManager, error) {
 	switch storageType {
 	case "postgres":
-		return &PostgresImageMetadataManager{}, nil
+		// Read the connection settings from the DB_* environment variables.
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return nil, err
+		}
+		return &PostgresImageMetadataManager{DB: db}, nil
 	default:
 		return nil, errors.New("unsupported metadata storage type")
 	}
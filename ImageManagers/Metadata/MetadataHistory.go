@@ -0,0 +1,71 @@
+package Metadata
+
+import (
+	"errors"
+	"time"
+)
+
+// Sources of a metadata change, recorded in the history.
+const (
+	SourceHTTP    = "http"
+	SourceKafka   = "kafka"
+	SourceRevert  = "revert"
+	SourceErasure = "erasure"
+	// SourceMigration marks metadata recorded by scripts/BackfillImageOwners.
+	SourceMigration = "migration"
+	// SourceModeration marks titles and descriptions applied or removed by a moderator.
+	SourceModeration = "moderation"
+)
+
+// ErrRevisionNotFound is returned when a requested revision does not exist or cannot be restored.
+var ErrRevisionNotFound = errors.New("metadata revision not found")
+
+// MetadataChange describes who made a metadata mutation and through which path.
+type MetadataChange struct {
+	ActorID string
+	Source  string
+}
+
+// FieldChange is the before and after value of a single metadata key; nil means absent.
+type FieldChange struct {
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+// MetadataRevision is an entry of the append-only metadata history of an image.
+type MetadataRevision struct {
+	ImageID   string                 `json:"image_id"`
+	Revision  int                    `json:"revision"`
+	ActorID   string                 `json:"actor_id"`
+	Source    string                 `json:"source"`
+	Before    map[string]string      `json:"before"`
+	After     map[string]string      `json:"after"`
+	Diff      map[string]FieldChange `json:"diff"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// DiffMetadata returns the keys whose values differ between two metadata maps.
+func DiffMetadata(before, after map[string]string) map[string]FieldChange {
+	diff := make(map[string]FieldChange)
+	for key, oldValue := range before {
+		newValue, ok := after[key]
+		if ok && newValue == oldValue {
+			continue
+		}
+		change := FieldChange{Before: stringPtr(oldValue)}
+		if ok {
+			change.After = stringPtr(newValue)
+		}
+		diff[key] = change
+	}
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok {
+			diff[key] = FieldChange{After: stringPtr(newValue)}
+		}
+	}
+	return diff
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -1,6 +1,7 @@
 package Metadata
 
 import (
+	"GOLA/DataSubjects/Erasure"
 	dbCommons "GOLA/commons/db"
 	"database/sql"
 	"encoding/json"
@@ -8,6 +9,17 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// Erasure deletes the history of the subject's images and pseudonymises their changes to the
+// metadata of other images.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "metadata_history", Table: "image_metadata_history",
+			Query: `DELETE FROM image_metadata_history WHERE image_id = ANY($1)`, Args: Erasure.ByImages},
+		Erasure.Step{Name: "metadata_changes", Table: "image_metadata_history",
+			Query: `UPDATE image_metadata_history SET actor_id = $2 WHERE actor_id = $1`, Args: Erasure.ByPseudonym},
+	)
+}
+
 // PostgresImageMetadataManager manages image metadata using PostgreSQL.
 type PostgresImageMetadataManager struct {
 	DB *sql.DB
@@ -28,11 +40,23 @@ func NewPostgresImageMetadataManager(config dbCommons.DBConfig) (*PostgresImageM
 	return manager, nil
 }
 
-// Initialize ensures the metadata table exists.
+// Initialize ensures the metadata and metadata history tables exist.
 func (p *PostgresImageMetadataManager) Initialize() error {
 	query := `CREATE TABLE IF NOT EXISTS image_metadata (
 		image_id TEXT PRIMARY KEY,
 		metadata JSONB NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS image_metadata_history (
+		id SERIAL PRIMARY KEY,
+		image_id TEXT NOT NULL,
+		revision INT NOT NULL,
+		actor_id VARCHAR(100) NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		before JSONB,
+		after JSONB,
+		diff JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (image_id, revision)
 	)`
 
 	_, err := p.DB.Exec(query)
@@ -46,7 +70,7 @@ func (p *PostgresImageMetadataManager) GetImageMetadata(imageID string) (map[str
 	row := p.DB.QueryRow(query, imageID)
 	if err := row.Scan(&jsonMetadata); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("metadata not found")
+			return nil, ErrMetadataNotFound
 		}
 		return nil, err
 	}
@@ -60,22 +84,193 @@ func (p *PostgresImageMetadataManager) GetImageMetadata(imageID string) (map[str
 	return metadata, nil
 }
 
-// SetImageMetadata saves metadata for an image.
-func (p *PostgresImageMetadataManager) SetImageMetadata(imageID string, metadata map[string]string) error {
-	jsonMetadata, err := json.Marshal(metadata)
+// SetImageMetadata saves metadata for an image and appends the change to its history.
+func (p *PostgresImageMetadataManager) SetImageMetadata(imageID string, metadata map[string]string, change MetadataChange) error {
+	_, err := p.mutate(imageID, change, func(*sql.Tx, map[string]string) (map[string]string, error) {
+		return metadata, nil
+	})
+	return err
+}
+
+// DeleteImageMetadata deletes metadata for a given image ID. The history is kept.
+func (p *PostgresImageMetadataManager) DeleteImageMetadata(imageID string, change MetadataChange) error {
+	_, err := p.mutate(imageID, change, func(*sql.Tx, map[string]string) (map[string]string, error) {
+		return nil, nil
+	})
+	return err
+}
+
+// GetImageMetadataHistory returns every recorded revision of an image, oldest first.
+func (p *PostgresImageMetadataManager) GetImageMetadataHistory(imageID string) ([]MetadataRevision, error) {
+	rows, err := p.DB.Query(`
+		SELECT image_id, revision, actor_id, source, before, after, diff, created_at
+		FROM image_metadata_history
+		WHERE image_id = $1
+		ORDER BY revision ASC
+	`, imageID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	query := `INSERT INTO image_metadata (image_id, metadata) VALUES ($1, $2)
-		ON CONFLICT (image_id) DO UPDATE SET metadata = EXCLUDED.metadata`
-	_, err = p.DB.Exec(query, imageID, jsonMetadata)
-	return err
+	history := []MetadataRevision{}
+	for rows.Next() {
+		var revision MetadataRevision
+		var before, after, diff []byte
+		if err := rows.Scan(&revision.ImageID, &revision.Revision, &revision.ActorID, &revision.Source,
+			&before, &after, &diff, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		for _, field := range []struct {
+			raw  []byte
+			dest interface{}
+		}{{before, &revision.Before}, {after, &revision.After}, {diff, &revision.Diff}} {
+			if field.raw == nil {
+				continue
+			}
+			if err := json.Unmarshal(field.raw, field.dest); err != nil {
+				return nil, err
+			}
+		}
+		history = append(history, revision)
+	}
+	return history, rows.Err()
 }
 
-// DeleteImageMetadata deletes metadata for a given image ID.
-func (p *PostgresImageMetadataManager) DeleteImageMetadata(imageID string) error {
-	query := `DELETE FROM image_metadata WHERE image_id = $1`
-	_, err := p.DB.Exec(query, imageID)
-	return err
+// RevertImageMetadata restores the metadata recorded after the given revision. The revert
+// itself is recorded as a new revision; ownership is never changed by a revert, and only the
+// owner may revert. The revision is read in the transaction that writes the revert, so no change
+// can slip in between.
+func (p *PostgresImageMetadataManager) RevertImageMetadata(imageID string, revision int, change MetadataChange) (map[string]string, error) {
+	return p.mutate(imageID, change, func(tx *sql.Tx, current map[string]string) (map[string]string, error) {
+		if current == nil {
+			return nil, ErrMetadataNotFound
+		}
+		// Ownership may have changed since the caller was authorized.
+		if !IsImageOwner(current, change.ActorID) {
+			return nil, ErrImageForbidden
+		}
+		var after []byte
+		err := tx.QueryRow(`SELECT after FROM image_metadata_history WHERE image_id = $1 AND revision = $2`,
+			imageID, revision).Scan(&after)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && after == nil) {
+			// Revisions that deleted the metadata have nothing to restore.
+			return nil, ErrRevisionNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var restored map[string]string
+		if err := json.Unmarshal(after, &restored); err != nil {
+			return nil, err
+		}
+		if owner, ok := current[OwnerIDKey]; ok {
+			restored[OwnerIDKey] = owner
+		} else {
+			delete(restored, OwnerIDKey)
+		}
+		return restored, nil
+	})
+}
+
+// mutate writes (or, for nil metadata, deletes) the metadata that apply computes from the
+// current metadata of an image, and records the change in image_metadata_history, within one
+// transaction. Changes to the same image are serialized with an advisory lock and the row is
+// locked FOR UPDATE, so revisions are gap free and ordered and apply sees the latest metadata.
+func (p *PostgresImageMetadataManager) mutate(imageID string, change MetadataChange,
+	apply func(tx *sql.Tx, before map[string]string) (map[string]string, error)) (map[string]string, error) {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, imageID); err != nil {
+		return nil, err
+	}
+
+	var before map[string]string
+	var jsonBefore []byte
+	err = tx.QueryRow(`SELECT metadata FROM image_metadata WHERE image_id = $1 FOR UPDATE`, imageID).Scan(&jsonBefore)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(jsonBefore, &before); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata, err := apply(tx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonAfter []byte
+	if metadata == nil {
+		if _, err := tx.Exec(`DELETE FROM image_metadata WHERE image_id = $1`, imageID); err != nil {
+			return nil, err
+		}
+	} else {
+		if jsonAfter, err = json.Marshal(metadata); err != nil {
+			return nil, err
+		}
+		query := `INSERT INTO image_metadata (image_id, metadata) VALUES ($1, $2)
+			ON CONFLICT (image_id) DO UPDATE SET metadata = EXCLUDED.metadata`
+		if _, err := tx.Exec(query, imageID, jsonAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	// Writes that change nothing (e.g. repeated stats updates) are not worth a revision.
+	diff := DiffMetadata(before, metadata)
+	if len(diff) == 0 && (before == nil) == (metadata == nil) {
+		return metadata, tx.Commit()
+	}
+	jsonDiff, err := json.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO image_metadata_history (image_id, revision, actor_id, source, before, after, diff)
+		VALUES ($1, (SELECT COALESCE(MAX(revision), 0) + 1 FROM image_metadata_history WHERE image_id = $1),
+			$2, $3, $4, $5, $6)
+	`, imageID, change.ActorID, change.Source, nullableJSON(jsonBefore), nullableJSON(jsonAfter), jsonDiff); err != nil {
+		return nil, err
+	}
+	return metadata, tx.Commit()
+}
+
+// nullableJSON maps an empty document to SQL NULL.
+func nullableJSON(doc []byte) interface{} {
+	if len(doc) == 0 {
+		return nil
+	}
+	return doc
+}
+
+// ListImageMetadata retrieves the metadata of all images.
+func (p *PostgresImageMetadataManager) ListImageMetadata() (map[string]map[string]string, error) {
+	rows, err := p.DB.Query(`SELECT image_id, metadata FROM image_metadata ORDER BY image_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make(map[string]map[string]string)
+	for rows.Next() {
+		var imageID string
+		var jsonMetadata []byte
+		if err := rows.Scan(&imageID, &jsonMetadata); err != nil {
+			return nil, err
+		}
+		var metadata map[string]string
+		if err := json.Unmarshal(jsonMetadata, &metadata); err != nil {
+			return nil, err
+		}
+		all[imageID] = metadata
+	}
+	return all, rows.Err()
 }
@@ -66,3 +66,27 @@ func (m *MinioRawImageStorageManager) FetchImage(imageID string) ([]byte, error)
 	}
 	return data, nil
 }
+
+// ListImages returns the IDs of every image in the bucket.
+func (m *MinioRawImageStorageManager) ListImages() ([]string, error) {
+	var imageIDs []string
+	for object := range m.Client.ListObjects(context.Background(), m.BucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		imageIDs = append(imageIDs, object.Key)
+	}
+	return imageIDs, nil
+}
+
+// ImageExists reports whether an object is stored under the image ID.
+func (m *MinioRawImageStorageManager) ImageExists(imageID string) (bool, error) {
+	_, err := m.Client.StatObject(context.Background(), m.BucketName, imageID, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, err
+}
@@ -14,6 +14,10 @@ type ImageStoreManager interface {
 	UploadImage(imageID string, imageData []byte) error
 	DeleteImage(imageID string) error
 	FetchImage(imageID string) ([]byte, error) /* THIS DOES NOT NEED TO BE DONE BY KAFKA */
+	// ListImages returns the IDs of every stored image.
+	ListImages() ([]string, error)
+	// ImageExists reports whether an image is stored under the ID.
+	ImageExists(imageID string) (bool, error)
 }
 
 // GetImageStoreManager returns an instance of the requested image storage manager.
@@ -0,0 +1,111 @@
+package Jobs
+
+import (
+	redisCache "GOLA/caches/Redis"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic background work.
+type Job func(ctx context.Context) error
+
+// Locker makes sure only one instance of the service runs a job at a time.
+type Locker interface {
+	// TryLock takes the lock of a job for at most ttl and reports whether it got it.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error)
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      Job
+}
+
+// Scheduler runs registered jobs at fixed intervals until it is stopped. A job never overlaps
+// with itself; when a Locker is set, a run is skipped if another instance holds the job's lock.
+type Scheduler struct {
+	Locker Locker
+
+	jobs    []scheduledJob
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler that coordinates through Redis when it is available.
+func NewScheduler() *Scheduler {
+	return &Scheduler{Locker: RedisLocker{}}
+}
+
+// Every registers a job to run once per interval. Jobs must be registered before Start.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		log.Printf("Job %s disabled", name)
+		return
+	}
+	s.jobs = append(s.jobs, scheduledJob{name: name, interval: interval, run: job})
+}
+
+// Start launches every registered job in the background.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, job := range s.jobs {
+		s.running.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.running.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job scheduledJob) {
+	defer s.running.Done()
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job scheduledJob) {
+	if s.Locker != nil {
+		// Hold the lock for most of the interval so a slower instance does not run it again.
+		locked, err := s.Locker.TryLock(ctx, job.name, job.interval*9/10)
+		if err != nil {
+			log.Printf("Error locking job %s, running it anyway: %v", job.name, err)
+		} else if !locked {
+			return
+		}
+	}
+	started := time.Now()
+	if err := job.run(ctx); err != nil {
+		log.Printf("Job %s failed after %s: %v", job.name, time.Since(started), err)
+		return
+	}
+	log.Printf("Job %s finished in %s", job.name, time.Since(started))
+}
+
+// RedisLocker implements Locker with a Redis key per job. Without Redis every instance runs
+// every job, which the jobs of this service tolerate.
+type RedisLocker struct{}
+
+// TryLock sets the lock key of the job if nobody holds it.
+func (RedisLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return true, nil
+	}
+	return client.SetNX(ctx, "jobs:lock:"+name, 1, ttl).Result()
+}
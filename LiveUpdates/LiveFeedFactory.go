@@ -0,0 +1,55 @@
+package LiveUpdates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Kinds of live updates.
+const (
+	// EventComment carries a new comment of the image.
+	EventComment = "comment"
+	// EventStats carries the current stats of the image.
+	EventStats = "stats"
+)
+
+// Update is a change to an image, sent to its viewers as one Server-Sent Event.
+type Update struct {
+	// ID orders the updates of an image; viewers resume after it with Last-Event-ID.
+	ID    string
+	Event string
+	Data  json.RawMessage
+}
+
+// Subscription delivers the updates of one image to one viewer. Updates is closed when the
+// viewer falls too far behind; the viewer then reconnects with the last ID it received.
+type Subscription struct {
+	Updates <-chan Update
+	cancel  func()
+}
+
+// Close stops the delivery of updates.
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// LiveFeed distributes updates of images between the API replicas: an update published on one
+// replica reaches the viewers connected to every replica.
+type LiveFeed interface {
+	// Publish sends an update to every viewer of the image.
+	Publish(ctx context.Context, imageID, event string, data interface{}) error
+	// Subscribe delivers the updates of the image. With lastEventID set, the updates after it
+	// that are still retained are delivered first.
+	Subscribe(ctx context.Context, imageID, lastEventID string) (*Subscription, error)
+}
+
+// GetLiveFeed returns an instance of the requested live feed.
+func GetLiveFeed(feedType string) (LiveFeed, error) {
+	switch feedType {
+	case "redis":
+		return NewRedisLiveFeed(), nil
+	default:
+		return nil, fmt.Errorf("unsupported live feed type: %s", feedType)
+	}
+}
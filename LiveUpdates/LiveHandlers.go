@@ -0,0 +1,125 @@
+package LiveUpdates
+
+import (
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/commons"
+	"GOLA/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// StatsSource provides the stats sent when a viewer connects.
+type StatsSource interface {
+	GetStats(ctx context.Context, targetID string) (*commons.EventStats, error)
+}
+
+// LiveHandlers streams the updates of images to their viewers as Server-Sent Events. Every
+// handler expects the JWT middleware to have put the caller's client ID into the request
+// context.
+type LiveHandlers struct {
+	Feed                 LiveFeed
+	ImageMetadataManager Metadata.ImageMetadataManager
+	Stats                StatsSource
+	// Heartbeat is the interval of keep-alive comments; access to the image is checked again
+	// on each of them.
+	Heartbeat time.Duration
+	// MaxConnectionAge ends streams after a while, so that viewers reconnect and present a
+	// current token.
+	MaxConnectionAge time.Duration
+}
+
+// HandleStream streams the new comments and stat changes of ?image_id=. The current stats are
+// sent first. Viewers resume after the ID in the Last-Event-ID header (or ?last_event_id=,
+// for clients that cannot set headers).
+func (h *LiveHandlers) HandleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	imageID := r.URL.Query().Get("image_id")
+	if imageID == "" {
+		http.Error(w, "image_id is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, imageID, false); !ok {
+		return
+	}
+	clientID, _ := utils.GetClientIDFromContext(r.Context())
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	sub, err := h.Feed.Subscribe(r.Context(), imageID, lastEventID)
+	if err != nil {
+		if errors.Is(err, ErrFeedUnavailable) {
+			http.Error(w, "Live updates unavailable", http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// Ask browsers to reconnect after 3 seconds.
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if h.Stats != nil {
+		if stats, err := h.Stats.GetStats(r.Context(), imageID); err == nil {
+			// Without an ID, so that it does not move the viewer's position in the feed.
+			data, _ := json.Marshal(stats)
+			writeEvent(w, Update{Event: EventStats, Data: data})
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	expired := time.After(h.MaxConnectionAge)
+	for {
+		select {
+		case update, ok := <-sub.Updates:
+			if !ok {
+				return
+			}
+			writeEvent(w, update)
+			flusher.Flush()
+		case <-heartbeat.C:
+			// The image may have been deleted or made private since the viewer connected.
+			if _, err := Metadata.AuthorizeImageAccess(h.ImageMetadataManager, imageID, clientID, false); err != nil {
+				return
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-expired:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes an update in the text/event-stream format. The data is JSON and so fits
+// on one line.
+func writeEvent(w http.ResponseWriter, update Update) {
+	if update.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", update.ID)
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Event, update.Data); err != nil {
+		log.Printf("Error writing live update: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package LiveUpdates
+
+import (
+	redisCache "GOLA/caches/Redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Updates of an image are appended to a Redis stream, whose entry IDs serve as event IDs and
+// which keeps the latest retainedUpdates updates for reconnecting viewers, and announced on a
+// pub/sub channel that every replica listens to.
+const (
+	streamKeyPrefix  = "live:image:"
+	channelPrefix    = "live:updates:"
+	retainedUpdates  = 100
+	streamTTL        = time.Hour
+	subscriberBuffer = 32
+)
+
+// ErrFeedUnavailable is returned when Redis is not connected.
+var ErrFeedUnavailable = errors.New("live feed unavailable")
+
+// RedisLiveFeed distributes updates through Redis. Each replica keeps one pattern
+// subscription and hands the updates to the viewers connected to it.
+type RedisLiveFeed struct {
+	start       sync.Once
+	mu          sync.Mutex
+	subscribers map[string]map[*subscriber]struct{}
+}
+
+// subscriber receives the updates of one image for one viewer.
+type subscriber struct {
+	updates chan Update
+}
+
+// NewRedisLiveFeed creates a feed on the shared Redis client.
+func NewRedisLiveFeed() *RedisLiveFeed {
+	return &RedisLiveFeed{subscribers: map[string]map[*subscriber]struct{}{}}
+}
+
+// announcement is the pub/sub message of an update.
+type announcement struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Publish appends the update to the stream of the image and announces it to every replica.
+func (f *RedisLiveFeed) Publish(ctx context.Context, imageID, event string, data interface{}) error {
+	client := redisCache.RedisClient
+	if client == nil {
+		return ErrFeedUnavailable
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding %s update: %w", event, err)
+	}
+	key := streamKeyPrefix + imageID
+	id, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: retainedUpdates,
+		Approx: true,
+		Values: map[string]interface{}{"event": event, "data": string(payload)},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("error appending %s update: %w", event, err)
+	}
+	message, _ := json.Marshal(announcement{ID: id, Event: event, Data: payload})
+	pipe := client.Pipeline()
+	pipe.Expire(ctx, key, streamTTL)
+	pipe.Publish(ctx, channelPrefix+imageID, message)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error announcing %s update: %w", event, err)
+	}
+	return nil
+}
+
+// Subscribe delivers the updates of the image, starting with the retained updates after
+// lastEventID. Updates announced while the retained ones are replayed are not delivered twice.
+func (f *RedisLiveFeed) Subscribe(ctx context.Context, imageID, lastEventID string) (*Subscription, error) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return nil, ErrFeedUnavailable
+	}
+	if lastEventID != "" {
+		if _, _, ok := parseStreamID(lastEventID); !ok {
+			return nil, fmt.Errorf("malformed event ID: %s", lastEventID)
+		}
+	}
+	f.start.Do(func() { go f.listen(client) })
+
+	// Register before replaying, so nothing announced in between is missed.
+	sub := &subscriber{updates: make(chan Update, subscriberBuffer)}
+	f.add(imageID, sub)
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan Update, subscriberBuffer)
+
+	go func() {
+		defer close(out)
+		defer f.remove(imageID, sub)
+		last := lastEventID
+		send := func(update Update) bool {
+			select {
+			case out <- update:
+				last = update.ID
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if lastEventID != "" {
+			entries, err := client.XRange(ctx, streamKeyPrefix+imageID, lastEventID, "+").Result()
+			if err != nil {
+				log.Printf("Error replaying updates of image %s: %v", imageID, err)
+			}
+			for _, entry := range entries {
+				if entry.ID == lastEventID {
+					continue
+				}
+				event, _ := entry.Values["event"].(string)
+				data, _ := entry.Values["data"].(string)
+				if !send(Update{ID: entry.ID, Event: event, Data: json.RawMessage(data)}) {
+					return
+				}
+			}
+		}
+
+		for {
+			select {
+			case update, ok := <-sub.updates:
+				if !ok {
+					return
+				}
+				if last != "" && !streamIDAfter(update.ID, last) {
+					continue
+				}
+				if !send(update) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return &Subscription{Updates: out, cancel: cancel}, nil
+}
+
+// listen hands the announced updates to the local subscribers of their images. The client
+// resubscribes by itself after connection failures; updates announced meanwhile reach the
+// viewers when they reconnect.
+func (f *RedisLiveFeed) listen(client *redis.Client) {
+	pubsub := client.PSubscribe(context.Background(), channelPrefix+"*")
+	for message := range pubsub.Channel() {
+		var a announcement
+		if err := json.Unmarshal([]byte(message.Payload), &a); err != nil {
+			log.Printf("Ignoring malformed live update on %s: %v", message.Channel, err)
+			continue
+		}
+		f.dispatch(strings.TrimPrefix(message.Channel, channelPrefix), Update{ID: a.ID, Event: a.Event, Data: a.Data})
+	}
+}
+
+// dispatch never blocks: a subscriber whose buffer is full is dropped and its viewer
+// reconnects.
+func (f *RedisLiveFeed) dispatch(imageID string, update Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subscribers[imageID] {
+		select {
+		case sub.updates <- update:
+		default:
+			close(sub.updates)
+			delete(f.subscribers[imageID], sub)
+		}
+	}
+	if len(f.subscribers[imageID]) == 0 {
+		delete(f.subscribers, imageID)
+	}
+}
+
+func (f *RedisLiveFeed) add(imageID string, sub *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subscribers[imageID] == nil {
+		f.subscribers[imageID] = map[*subscriber]struct{}{}
+	}
+	f.subscribers[imageID][sub] = struct{}{}
+}
+
+func (f *RedisLiveFeed) remove(imageID string, sub *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscribers[imageID], sub)
+	if len(f.subscribers[imageID]) == 0 {
+		delete(f.subscribers, imageID)
+	}
+}
+
+// parseStreamID splits a Redis stream entry ID such as "1700000000000-0".
+func parseStreamID(id string) (uint64, uint64, bool) {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	msValue, err := strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seqValue, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return msValue, seqValue, true
+}
+
+// streamIDAfter reports whether stream entry ID a comes after b.
+func streamIDAfter(a, b string) bool {
+	aMs, aSeq, _ := parseStreamID(a)
+	bMs, bSeq, _ := parseStreamID(b)
+	return aMs > bMs || (aMs == bMs && aSeq > bSeq)
+}
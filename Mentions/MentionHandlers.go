@@ -0,0 +1,97 @@
+package Mentions
+
+import (
+	"GOLA/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// MentionHandlers exposes mentions and hashtags over HTTP. Every handler expects the JWT
+// middleware to have put the caller's client ID into the request context; listings only show
+// what the caller can see.
+type MentionHandlers struct {
+	Mentions MentionManager
+}
+
+// HandleListMentions returns a page of the mentions of ?user_id= (the caller by default),
+// newest first. ?cursor= continues from a previous page and ?limit= sets the page size.
+func (h *MentionHandlers) HandleListMentions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	query := MentionQuery{UserID: params.Get("user_id"), ViewerID: clientID, Cursor: params.Get("cursor")}
+	if query.UserID == "" {
+		query.UserID = clientID
+	}
+	var ok bool
+	if query.Limit, ok = parseLimit(w, params.Get("limit")); !ok {
+		return
+	}
+
+	page, err := h.Mentions.ListMentions(r.Context(), query)
+	if err != nil {
+		writeMentionError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandleHashtagImages returns a page of the images of ?tag= (with or without "#"), by image
+// ID. ?cursor= continues from a previous page and ?limit= sets the page size.
+func (h *MentionHandlers) HandleHashtagImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	query := HashtagQuery{Hashtag: params.Get("tag"), ViewerID: clientID, Cursor: params.Get("cursor")}
+	var ok bool
+	if query.Limit, ok = parseLimit(w, params.Get("limit")); !ok {
+		return
+	}
+
+	page, err := h.Mentions.ListImagesByHashtag(r.Context(), query)
+	if err != nil {
+		writeMentionError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// parseLimit parses the optional ?limit= of a listing, writing an error if it is invalid.
+func parseLimit(w http.ResponseWriter, value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+// writeMentionError maps mention manager errors onto HTTP status codes.
+func writeMentionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidMentionQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Mention operation failed: %v", err)
+		http.Error(w, "Mention operation failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,77 @@
+package Mentions
+
+import (
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Kinds of text mentions and hashtags are parsed from.
+const (
+	KindComment          = "comment"
+	KindImageDescription = "image_description"
+)
+
+// Page sizes of mention and hashtag listings.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidMentionQuery is returned for malformed cursors, missing users or hashtags and
+// oversized pages.
+var ErrInvalidMentionQuery = errors.New("invalid mention query")
+
+// Source identifies a piece of text mentions and hashtags are parsed from.
+type Source struct {
+	Kind string
+	// ID is the comment ID for comments and the image ID for descriptions.
+	ID       string
+	ImageID  string
+	AuthorID string
+}
+
+// MentionQuery selects a page of the mentions of a user, newest first.
+type MentionQuery struct {
+	UserID string
+	// ViewerID only sees mentions on images they can see.
+	ViewerID string
+	Cursor   string
+	Limit    int
+}
+
+// HashtagQuery selects a page of the images of a hashtag, by image ID.
+type HashtagQuery struct {
+	Hashtag  string
+	ViewerID string
+	Cursor   string
+	Limit    int
+}
+
+// MentionManager indexes the @mentions and #hashtags of comments and image descriptions.
+// Listings leave out hidden and deleted comments and images the viewer cannot see.
+type MentionManager interface {
+	Initialize() error
+	// IndexText replaces the mentions and hashtags of a source with those of its text. Mentions
+	// and hashtags the text still has keep the time they were first written.
+	IndexText(ctx context.Context, source Source, text string) error
+	// RemoveSource forgets the mentions and hashtags of a source.
+	RemoveSource(ctx context.Context, kind, id string) error
+	// RemoveImage forgets the mentions and hashtags of an image and of its comments.
+	RemoveImage(ctx context.Context, imageID string) error
+	ListMentions(ctx context.Context, query MentionQuery) (*models.MentionPage, error)
+	// ListImagesByHashtag returns the images with the hashtag in their description or comments,
+	// or among their tags.
+	ListImagesByHashtag(ctx context.Context, query HashtagQuery) (*models.HashtagImagePage, error)
+}
+
+// GetMentionManager returns an instance of the requested mention manager.
+func GetMentionManager(storageType string) (MentionManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresMentionManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mention storage type: %s", storageType)
+	}
+}
@@ -0,0 +1,76 @@
+package Mentions
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaxHashtagLength is the longest hashtag that is recognized, in characters.
+const MaxHashtagLength = 64
+
+// mentionPattern matches @mentions of user IDs.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@-])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// hashtagPattern matches #hashtags; a hashtag must not follow a letter, digit, "&" or "#", so
+// that URL fragments, HTML entities and "##" are left alone.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#/])#([\p{L}\p{N}_]+)`)
+
+// ParseMentions returns the distinct user IDs mentioned in text, in order of appearance.
+func ParseMentions(text string) []string {
+	var mentions []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// A trailing dot ends the sentence rather than the user ID.
+		user := strings.TrimRight(match[1], ".-")
+		if user != "" && !seen[user] {
+			seen[user] = true
+			mentions = append(mentions, user)
+		}
+	}
+	return mentions
+}
+
+// ParseHashtags returns the distinct hashtags of text, lowercased and without "#", in order of
+// appearance. Hashtags need a letter, so that "#1" is not one, and overlong ones are ignored.
+func ParseHashtags(text string) []string {
+	var hashtags []string
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(match[1])
+		if seen[tag] || len([]rune(tag)) > MaxHashtagLength || strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+			continue
+		}
+		seen[tag] = true
+		hashtags = append(hashtags, tag)
+	}
+	return hashtags
+}
+
+// MergeHashtagTags adds the hashtags of the description to the comma separated tags of image
+// metadata, so that search and trending know them as tags. Tags are only ever added: removing
+// a hashtag from the description leaves the tag for the owner to remove.
+func MergeHashtagTags(metadata map[string]string) {
+	hashtags := ParseHashtags(metadata["description"])
+	if len(hashtags) == 0 {
+		return
+	}
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(metadata["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+			seen[strings.ToLower(tag)] = true
+		}
+	}
+	added := false
+	for _, tag := range hashtags {
+		if !seen[tag] {
+			tags = append(tags, tag)
+			added = true
+		}
+	}
+	if added {
+		metadata["tags"] = strings.Join(tags, ",")
+	}
+}
@@ -0,0 +1,315 @@
+package Mentions
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Erasure drops the index entries of the subject's comments and images, whose content is
+// erased, and the mentions of the subject. Comments are matched under the subject's ID or the
+// pseudonym, whichever step runs first.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "mentions", Table: "mentions",
+			Query: `DELETE FROM mentions WHERE author_id = $1 OR mentioned_user_id = $1 OR image_id = ANY($2)`,
+			Args:  Erasure.BySubjectAndImages},
+		Erasure.Step{Name: "hashtags", Table: "hashtags",
+			Query: `DELETE FROM hashtags WHERE image_id = ANY($3) OR (source_kind = 'comment'
+				AND source_id IN (SELECT id::text FROM comments WHERE author_id IN ($1, $2)))`,
+			Args: func(subject Erasure.Subject) []interface{} {
+				return []interface{}{subject.ID, subject.Pseudonym, pq.Array(subject.Images)}
+			}},
+	)
+}
+
+// visibleSource joins the comment of a mention or hashtag row (aliased r) as c, and keeps rows
+// of descriptions and of comments that are neither hidden nor deleted. Comment IDs are only
+// cast for comment rows.
+const visibleSource = `
+	LEFT JOIN comments c ON r.source_kind = 'comment'
+		AND c.id = CASE WHEN r.source_kind = 'comment' THEN r.source_id::bigint END`
+
+const visibleSourceCondition = `(r.source_kind <> 'comment' OR (c.deleted_at IS NULL AND c.state <> 'hidden'))`
+
+// visibleImageCondition keeps images that are public or owned by the viewer (the parameter).
+const visibleImageCondition = `(COALESCE(im.metadata->>'is_private', '') <> 'true' OR im.metadata->>'owner_id' = %s)`
+
+// PostgresMentionManager keeps the mention and hashtag indexes in PostgreSQL, next to the
+// comments and image metadata it filters them by.
+type PostgresMentionManager struct {
+	DB *sql.DB
+}
+
+// Initialize connects to the database (if needed) and ensures the mention and hashtag tables
+// exist.
+func (p *PostgresMentionManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS mentions (
+		id BIGSERIAL PRIMARY KEY,
+		source_kind VARCHAR(30) NOT NULL,
+		source_id TEXT NOT NULL,
+		image_id TEXT NOT NULL,
+		author_id VARCHAR(100) NOT NULL,
+		mentioned_user_id VARCHAR(100) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (source_kind, source_id, mentioned_user_id)
+	);
+	CREATE INDEX IF NOT EXISTS mentions_user_idx ON mentions (mentioned_user_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS mentions_image_idx ON mentions (image_id);
+	CREATE TABLE IF NOT EXISTS hashtags (
+		id BIGSERIAL PRIMARY KEY,
+		source_kind VARCHAR(30) NOT NULL,
+		source_id TEXT NOT NULL,
+		image_id TEXT NOT NULL,
+		tag VARCHAR(100) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (source_kind, source_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS hashtags_tag_idx ON hashtags (tag, image_id);
+	CREATE INDEX IF NOT EXISTS hashtags_image_idx ON hashtags (image_id);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating mention tables: %w", err)
+	}
+	return nil
+}
+
+// IndexText replaces the mentions and hashtags of a source with those of its text.
+func (p *PostgresMentionManager) IndexText(ctx context.Context, source Source, text string) error {
+	mentions := ParseMentions(text)
+	hashtags := ParseHashtags(text)
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, index := range []struct {
+		table, column string
+		values        []string
+	}{
+		{"mentions", "mentioned_user_id", mentions},
+		{"hashtags", "tag", hashtags},
+	} {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			DELETE FROM %s WHERE source_kind = $1 AND source_id = $2 AND NOT (%s = ANY($3))
+		`, index.table, index.column), source.Kind, source.ID, pq.Array(index.values)); err != nil {
+			return fmt.Errorf("error clearing %s: %w", index.table, err)
+		}
+		if len(index.values) == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (source_kind, source_id, image_id, author_id, %s)
+			SELECT $1, $2, $3, $4, value FROM unnest($5::text[]) AS value
+			ON CONFLICT (source_kind, source_id, %s) DO NOTHING
+		`, index.table, index.column, index.column), source.Kind, source.ID, source.ImageID, source.AuthorID,
+			pq.Array(index.values)); err != nil {
+			return fmt.Errorf("error indexing %s: %w", index.table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveSource forgets the mentions and hashtags of a source.
+func (p *PostgresMentionManager) RemoveSource(ctx context.Context, kind, id string) error {
+	if _, err := p.DB.ExecContext(ctx, `DELETE FROM mentions WHERE source_kind = $1 AND source_id = $2`, kind, id); err != nil {
+		return fmt.Errorf("error removing mentions: %w", err)
+	}
+	if _, err := p.DB.ExecContext(ctx, `DELETE FROM hashtags WHERE source_kind = $1 AND source_id = $2`, kind, id); err != nil {
+		return fmt.Errorf("error removing hashtags: %w", err)
+	}
+	return nil
+}
+
+// RemoveImage forgets the mentions and hashtags of an image and of its comments.
+func (p *PostgresMentionManager) RemoveImage(ctx context.Context, imageID string) error {
+	if _, err := p.DB.ExecContext(ctx, `DELETE FROM mentions WHERE image_id = $1`, imageID); err != nil {
+		return fmt.Errorf("error removing mentions: %w", err)
+	}
+	if _, err := p.DB.ExecContext(ctx, `DELETE FROM hashtags WHERE image_id = $1`, imageID); err != nil {
+		return fmt.Errorf("error removing hashtags: %w", err)
+	}
+	return nil
+}
+
+// ListMentions returns a page of the mentions of a user, newest first.
+func (p *PostgresMentionManager) ListMentions(ctx context.Context, query MentionQuery) (*models.MentionPage, error) {
+	if query.UserID == "" {
+		return nil, fmt.Errorf("%w: user is required", ErrInvalidMentionQuery)
+	}
+	limit, err := pageLimit(query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	conditions := []string{"r.mentioned_user_id = $1", visibleSourceCondition, fmt.Sprintf(visibleImageCondition, "$2")}
+	args := []interface{}{query.UserID, query.ViewerID}
+	if query.Cursor != "" {
+		createdAt, id, err := decodeMentionCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(r.created_at, r.id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit+1)
+
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT r.id, r.source_kind, r.source_id, r.image_id, r.author_id, r.mentioned_user_id,
+			CASE WHEN r.source_kind = 'comment' THEN c.content ELSE COALESCE(im.metadata->>'description', '') END,
+			r.created_at
+		FROM mentions r
+		JOIN image_metadata im ON im.image_id = r.image_id`+visibleSource+`
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying mentions: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.MentionPage{Mentions: []models.Mention{}}
+	for rows.Next() {
+		var m models.Mention
+		if err := rows.Scan(&m.ID, &m.Kind, &m.SourceID, &m.ImageID, &m.AuthorID, &m.MentionedUserID,
+			&m.Text, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning mention: %w", err)
+		}
+		page.Mentions = append(page.Mentions, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// One row more than the limit was fetched to know whether another page follows.
+	if len(page.Mentions) > limit {
+		page.Mentions = page.Mentions[:limit]
+		last := page.Mentions[limit-1]
+		page.NextCursor = encodeMentionCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// ListImagesByHashtag returns a page of the images of a hashtag, by image ID.
+func (p *PostgresMentionManager) ListImagesByHashtag(ctx context.Context, query HashtagQuery) (*models.HashtagImagePage, error) {
+	hashtag := NormalizeHashtag(query.Hashtag)
+	if hashtag == "" {
+		return nil, fmt.Errorf("%w: hashtag is required", ErrInvalidMentionQuery)
+	}
+	limit, err := pageLimit(query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	after := ""
+	if query.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if err != nil || len(raw) == 0 {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidMentionQuery)
+		}
+		after = string(raw)
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `
+		WITH used AS (
+			SELECT r.image_id, MAX(r.created_at) AS last_used
+			FROM hashtags r`+visibleSource+`
+			WHERE r.tag = $1 AND `+visibleSourceCondition+`
+			GROUP BY r.image_id
+		)
+		SELECT im.image_id, COALESCE(im.metadata->>'title', ''), u.last_used
+		FROM image_metadata im
+		LEFT JOIN used u ON u.image_id = im.image_id
+		WHERE (u.image_id IS NOT NULL
+				OR $1 = ANY(regexp_split_to_array(lower(trim(COALESCE(im.metadata->>'tags', ''))), '\s*,\s*')))
+			AND `+fmt.Sprintf(visibleImageCondition, "$2")+`
+			AND im.image_id > $3
+		ORDER BY im.image_id
+		LIMIT $4
+	`, hashtag, query.ViewerID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("error querying images of #%s: %w", hashtag, err)
+	}
+	defer rows.Close()
+
+	page := &models.HashtagImagePage{Hashtag: hashtag, Images: []models.HashtagImage{}}
+	for rows.Next() {
+		var image models.HashtagImage
+		if err := rows.Scan(&image.ImageID, &image.Title, &image.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("error scanning hashtag image: %w", err)
+		}
+		page.Images = append(page.Images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Images) > limit {
+		page.Images = page.Images[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(page.Images[limit-1].ImageID))
+	}
+	return page, nil
+}
+
+// NormalizeHashtag turns "#Sunset" or "sunset" into "sunset".
+func NormalizeHashtag(hashtag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(hashtag), "#"))
+}
+
+func pageLimit(limit int) (int, error) {
+	if limit <= 0 {
+		return DefaultPageSize, nil
+	}
+	if limit > MaxPageSize {
+		return 0, fmt.Errorf("%w: limit must be at most %d", ErrInvalidMentionQuery, MaxPageSize)
+	}
+	return limit, nil
+}
+
+// encodeMentionCursor and decodeMentionCursor turn the position of the last mention of a page
+// into an opaque cursor and back.
+func encodeMentionCursor(createdAt time.Time, id int64) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeMentionCursor(cursor string) (time.Time, int64, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidMentionQuery)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	mentionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, unixNano).UTC(), mentionID, nil
+}
@@ -0,0 +1,182 @@
+package KafkaOperations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Events whose handling fails are not retried in the consumer loop, which would hold up the
+// partition. They are moved to the retry topic instead, where StartRetryConsumer tries them
+// again after a growing delay, and after eventAttempts failed attempts to the dead-letter topic,
+// from which scripts/ReplayDeadLetters puts them back once the cause is fixed. Offsets are only
+// committed once an event is handled or moved, so no event is lost. A retried event may be
+// applied after later events of its key, e.g. a like after its removal.
+const (
+	// eventAttempts is how often an event is tried before it is dead-lettered.
+	eventAttempts = 5
+	// retryBaseDelay is the delay before the first retry; it doubles with every attempt.
+	retryBaseDelay = 5 * time.Second
+)
+
+// Headers of retried and dead-lettered messages.
+const (
+	headerAttempts = "x-attempts"
+	headerRetryAt  = "x-retry-at"
+	headerError    = "x-error"
+)
+
+// permanentError marks a failure that retrying cannot fix, such as a message that is not a
+// valid event; such messages go straight to the dead-letter topic.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// isPermanent reports whether err was marked as permanent.
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// messageWriter is implemented by *kafka.Writer.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// newFailureWriter returns a writer for the retry and dead-letter topics; every message names
+// its topic.
+func newFailureWriter(brokerAddress string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokerAddress),
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+	}
+}
+
+// retryTopic and deadLetterTopic default to the topic with a "-retry" or "-dead-letter" suffix.
+func (config KafkaConsumerConfig) retryTopic() string {
+	if config.RetryTopic != "" {
+		return config.RetryTopic
+	}
+	return config.Topic + "-retry"
+}
+
+func (config KafkaConsumerConfig) deadLetterTopic() string {
+	if config.DeadLetterTopic != "" {
+		return config.DeadLetterTopic
+	}
+	return config.Topic + "-dead-letter"
+}
+
+// messageAttempts returns how often the message was tried before.
+func messageAttempts(msg kafka.Message) int {
+	for _, header := range msg.Headers {
+		if header.Key == headerAttempts {
+			attempts, _ := strconv.Atoi(string(header.Value))
+			return attempts
+		}
+	}
+	return 0
+}
+
+// messageRetryAt returns when the message may be tried again.
+func messageRetryAt(msg kafka.Message) time.Time {
+	for _, header := range msg.Headers {
+		if header.Key == headerRetryAt {
+			if retryAt, err := time.Parse(time.RFC3339Nano, string(header.Value)); err == nil {
+				return retryAt
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// routeFailedMessage moves a message whose handling failed to the retry topic, or to the
+// dead-letter topic once it was tried eventAttempts times or failed permanently. It only returns once the message is
+// written, so the caller can commit it.
+func routeFailedMessage(ctx context.Context, writer messageWriter, config KafkaConsumerConfig, msg kafka.Message, cause error, now time.Time) error {
+	attempts := messageAttempts(msg) + 1
+	failed := kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: []kafka.Header{
+			{Key: headerAttempts, Value: []byte(strconv.Itoa(attempts))},
+			{Key: headerError, Value: []byte(cause.Error())},
+		},
+	}
+	if attempts < eventAttempts && !isPermanent(cause) {
+		failed.Topic = config.retryTopic()
+		retryAt := now.Add(retryBaseDelay << (attempts - 1))
+		failed.Headers = append(failed.Headers, kafka.Header{Key: headerRetryAt, Value: []byte(retryAt.Format(time.RFC3339Nano))})
+	} else {
+		failed.Topic = config.deadLetterTopic()
+	}
+
+	backoff := time.Second
+	for {
+		err := writer.WriteMessages(ctx, failed)
+		if err == nil {
+			log.Printf("Moved failed event to %s after %d attempts: %v", failed.Topic, attempts, cause)
+			return nil
+		}
+		log.Printf("Error moving failed event to %s, retrying in %s: %v", failed.Topic, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("moving failed event to %s: %w", failed.Topic, ctx.Err())
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// StartRetryConsumer tries the events of the retry topic again once their delay has passed.
+// Waiting for a delay only holds up the retry topic, never the main one.
+func StartRetryConsumer(config KafkaConsumerConfig) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{config.BrokerAddress},
+		Topic:    config.retryTopic(),
+		GroupID:  config.GroupID + "-retry",
+		MaxBytes: 10e6, // 10MB max per message.
+	})
+	log.Printf("Kafka retry consumer started for topic %s on broker %s", config.retryTopic(), config.BrokerAddress)
+	consumeMessages(reader, newFailureWriter(config.BrokerAddress), config, true)
+}
+
+// consumeMessages handles the messages of the reader and commits each once it was handled or
+// moved to the retry or dead-letter topic. With delayed set, messages wait for their retry time.
+func consumeMessages(reader *kafka.Reader, writer messageWriter, config KafkaConsumerConfig, delayed bool) {
+	ctx := context.Background()
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			log.Println("Error reading message:", err)
+			continue
+		}
+		if delayed {
+			if wait := time.Until(messageRetryAt(msg)); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		if err := handleMessage(msg, config); err != nil {
+			if err := routeFailedMessage(ctx, writer, config, msg, err, time.Now()); err != nil {
+				log.Printf("Error moving failed event at offset %d: %v", msg.Offset, err)
+				continue
+			}
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("Error committing offset %d: %v", msg.Offset, err)
+		}
+	}
+}
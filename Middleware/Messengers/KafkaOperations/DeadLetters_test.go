@@ -0,0 +1,82 @@
+package KafkaOperations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type recordingWriter struct {
+	messages []kafka.Message
+	failures int
+}
+
+func (w *recordingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker unavailable")
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func TestRouteFailedMessage(t *testing.T) {
+	config := KafkaConsumerConfig{Topic: "events"}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cause := errors.New("database unavailable")
+	msg := kafka.Message{Key: []byte("user|image"), Value: []byte(`{"event_id":"1"}`)}
+
+	writer := &recordingWriter{}
+	for attempt := 1; attempt <= eventAttempts; attempt++ {
+		if err := routeFailedMessage(context.Background(), writer, config, msg, cause, now); err != nil {
+			t.Fatal(err)
+		}
+		msg = writer.messages[len(writer.messages)-1]
+		if got := messageAttempts(msg); got != attempt {
+			t.Fatalf("attempts = %d, want %d", got, attempt)
+		}
+		if string(msg.Key) != "user|image" || string(msg.Value) != `{"event_id":"1"}` {
+			t.Fatalf("message changed: %s %s", msg.Key, msg.Value)
+		}
+		if attempt < eventAttempts {
+			if msg.Topic != "events-retry" {
+				t.Fatalf("attempt %d went to %s, want the retry topic", attempt, msg.Topic)
+			}
+			if want := now.Add(retryBaseDelay << (attempt - 1)); !messageRetryAt(msg).Equal(want) {
+				t.Fatalf("attempt %d retries at %s, want %s", attempt, messageRetryAt(msg), want)
+			}
+		} else if msg.Topic != "events-dead-letter" {
+			t.Fatalf("last attempt went to %s, want the dead-letter topic", msg.Topic)
+		}
+	}
+}
+
+func TestRouteFailedMessageWaitsForTheBroker(t *testing.T) {
+	config := KafkaConsumerConfig{Topic: "events", RetryTopic: "retries"}
+	writer := &recordingWriter{failures: 1}
+	err := routeFailedMessage(context.Background(), writer, config, kafka.Message{}, errors.New("failed"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.messages) != 1 || writer.messages[0].Topic != "retries" {
+		t.Fatalf("messages = %+v, want one on the retry topic", writer.messages)
+	}
+}
+
+func TestRouteFailedMessageDeadLettersPermanentFailures(t *testing.T) {
+	config := KafkaConsumerConfig{Topic: "events"}
+	writer := &recordingWriter{}
+	err := handleMessage(kafka.Message{Key: []byte("test"), Value: []byte("test")}, config)
+	if !isPermanent(err) {
+		t.Fatalf("handleMessage(invalid JSON) = %v, want a permanent error", err)
+	}
+	if err := routeFailedMessage(context.Background(), writer, config, kafka.Message{}, err, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.messages) != 1 || writer.messages[0].Topic != "events-dead-letter" {
+		t.Fatalf("messages = %+v, want one on the dead-letter topic", writer.messages)
+	}
+}
@@ -3,10 +3,16 @@ package KafkaOperations
 import (
 	"GOLA/ImageManagers/Metadata"
 	"GOLA/ImageManagers/RawStore"
+	"GOLA/Mentions"
+	"GOLA/Moderation"
 	"GOLA/constants"
+	"GOLA/utils"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 )
 
 // Global managers initialized in main.
@@ -19,6 +25,20 @@ func SetManagers(storeManager RawStore.ImageStoreManager, metadataManager Metada
 	imageMetadataManager = metadataManager
 }
 
+// MetadataScreener screens the text of image metadata before it is stored. It returns the
+// metadata to store, which may hold back text for review, and refuses the change with an error.
+type MetadataScreener interface {
+	ScreenMetadata(ctx context.Context, imageID, authorID string, current, proposed map[string]string) (map[string]string, error)
+}
+
+// metadataScreener screens the metadata supplied with uploads; set by SetMetadataScreener.
+var metadataScreener MetadataScreener
+
+// SetMetadataScreener is called from main to screen the metadata supplied with uploads.
+func SetMetadataScreener(screener MetadataScreener) {
+	metadataScreener = screener
+}
+
 // ImageTask represents an image-related event.
 type ImageTask struct {
 	Action  string `json:"action"` // "upload", "delete", or "metadata"
@@ -40,9 +60,16 @@ func ImageHandler(action string, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleImageUpload processes image uploads.
+// handleImageUpload processes image uploads. The caller is recorded as the owner of a new
+// image; re-uploading an existing image ID is only allowed for its owner.
 func handleImageUpload(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(10 << 20) // 10MB limit
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	err = r.ParseMultipartForm(10 << 20) // 10MB limit
 	if err != nil {
 		http.Error(w, "File too large", http.StatusBadRequest)
 		return
@@ -62,11 +89,72 @@ func handleImageUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ensure the ImageStoreManager is initialized.
+	// Ensure the managers are initialized.
 	if imageStoreManager == nil {
 		http.Error(w, "Image store manager not initialized", http.StatusInternalServerError)
 		return
 	}
+	if imageMetadataManager == nil {
+		http.Error(w, "Metadata manager not initialized", http.StatusInternalServerError)
+		return
+	}
+
+	// Images are keyed by filename, so an upload must not overwrite somebody else's image.
+	// Images stored before ownership was recorded may have no metadata at all; nobody may
+	// take them over until scripts/BackfillImageOwners has assigned them an owner.
+	meta, err := imageMetadataManager.GetImageMetadata(header.Filename)
+	if errors.Is(err, Metadata.ErrMetadataNotFound) {
+		exists, storeErr := imageStoreManager.ImageExists(header.Filename)
+		switch {
+		case storeErr != nil:
+			http.Error(w, "Image lookup failed", http.StatusInternalServerError)
+			return
+		case exists:
+			http.Error(w, Metadata.ErrImageForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	switch {
+	case errors.Is(err, Metadata.ErrMetadataNotFound):
+		meta = map[string]string{Metadata.OwnerIDKey: clientID}
+	case err != nil:
+		http.Error(w, "Metadata retrieval failed", http.StatusInternalServerError)
+		return
+	case !Metadata.IsImageOwner(meta, clientID):
+		if Metadata.CanViewImage(meta, clientID) {
+			http.Error(w, Metadata.ErrImageForbidden.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, "Image not found", http.StatusNotFound)
+		}
+		return
+	}
+	current := make(map[string]string, len(meta))
+	for key, value := range meta {
+		current[key] = value
+	}
+	// Re-uploads keep the visibility of the image unless the form changes it.
+	if value := r.FormValue("is_private"); value != "" {
+		meta[Metadata.IsPrivateKey] = strconv.FormatBool(value == "true")
+	} else if _, ok := meta[Metadata.IsPrivateKey]; !ok {
+		meta[Metadata.IsPrivateKey] = "false"
+	}
+	for _, key := range []string{"title", "description", "tags"} {
+		if value := r.FormValue(key); value != "" {
+			meta[key] = value
+		}
+	}
+	if metadataScreener != nil {
+		if meta, err = metadataScreener.ScreenMetadata(r.Context(), header.Filename, clientID, current, meta); err != nil {
+			if errors.Is(err, Moderation.ErrRateLimited) {
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			} else {
+				http.Error(w, "Metadata screening failed", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+	// Hashtags of the description become tags of the image.
+	Mentions.MergeHashtagTags(meta)
 
 	// Upload the image.
 	err = imageStoreManager.UploadImage(header.Filename, imageData)
@@ -75,18 +163,16 @@ func handleImageUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ensure the ImageMetadataManager is initialized.
-	if imageMetadataManager == nil {
-		http.Error(w, "Metadata manager not initialized", http.StatusInternalServerError)
-		return
-	}
-
-	// Retrieve metadata for the uploaded image.
-	meta, err := imageMetadataManager.GetImageMetadata(header.Filename)
-	if err != nil {
-		http.Error(w, "Metadata retrieval failed", http.StatusInternalServerError)
+	// Record ownership and visibility alongside any metadata supplied with the upload.
+	if err := imageMetadataManager.SetImageMetadata(header.Filename, meta,
+		Metadata.MetadataChange{ActorID: clientID, Source: Metadata.SourceHTTP}); err != nil {
+		http.Error(w, "Metadata update failed", http.StatusInternalServerError)
 		return
 	}
+	SendKafkaEvent(constants.IMAGE_METADATA_UPDATE, nil, nil,
+		map[string]string{"image_id": header.Filename}, r.URL.Path, clientID)
+	SendKafkaEvent(constants.IMAGE_UPLOADED, nil, nil,
+		map[string]string{"image_id": header.Filename, "owner_id": meta[Metadata.OwnerIDKey]}, r.URL.Path, clientID)
 
 	// Convert metadata to JSON and send response.
 	jsonResponse, err := json.Marshal(meta)
@@ -99,7 +185,7 @@ func handleImageUpload(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonResponse)
 }
 
-// handleImageDelete processes image deletion.
+// handleImageDelete processes image deletion. Only the owner may delete an image.
 func handleImageDelete(w http.ResponseWriter, r *http.Request) {
 	imageID := r.URL.Query().Get("id")
 	if imageID == "" {
@@ -113,12 +199,28 @@ func handleImageDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	meta, ok := Metadata.AuthorizeImageRequest(w, r, imageMetadataManager, imageID, true)
+	if !ok {
+		return
+	}
+
 	// Delete the image.
 	err := imageStoreManager.DeleteImage(imageID)
 	if err != nil {
 		http.Error(w, "Failed to delete image", http.StatusInternalServerError)
 		return
 	}
+	clientID, _ := utils.GetClientIDFromContext(r.Context())
+	if err := imageMetadataManager.DeleteImageMetadata(imageID,
+		Metadata.MetadataChange{ActorID: clientID, Source: Metadata.SourceHTTP}); err != nil {
+		http.Error(w, "Failed to delete image metadata", http.StatusInternalServerError)
+		return
+	}
+
+	// Let downstream consumers (e.g. the search indexer) know the image is gone. The owner is
+	// included since the metadata recording it no longer exists.
+	SendKafkaEvent(constants.IMAGE_DELETE, extractHeaders(r), extractQueryParams(r),
+		map[string]string{"image_id": imageID, "owner_id": meta[Metadata.OwnerIDKey]}, r.URL.Path, clientID)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Image deleted successfully"))
@@ -132,16 +234,9 @@ func handleImageMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ensure the ImageMetadataManager is initialized.
-	if imageMetadataManager == nil {
-		http.Error(w, "Metadata manager not initialized", http.StatusInternalServerError)
-		return
-	}
-
-	// Retrieve metadata for the specified image.
-	meta, err := imageMetadataManager.GetImageMetadata(imageID)
-	if err != nil {
-		http.Error(w, "Failed to retrieve metadata", http.StatusInternalServerError)
+	// Retrieve metadata for the specified image, hiding private images of other owners.
+	meta, ok := Metadata.AuthorizeImageRequest(w, r, imageMetadataManager, imageID, false)
+	if !ok {
 		return
 	}
 
@@ -1,7 +1,6 @@
 package KafkaOperations
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -11,6 +10,7 @@ import (
 
 	metaDataManager "GOLA/ImageManagers/Metadata"
 	rawStoreManager "GOLA/ImageManagers/RawStore"
+	"GOLA/LiveUpdates"
 	"GOLA/UserEventManagers"
 	constants "GOLA/constants"
 )
@@ -23,9 +23,15 @@ type KafkaConsumerConfig struct {
 	EventManager         UserEventManagers.EventManager       // Task/event manager instance.
 	ImageMetadataManager metaDataManager.ImageMetadataManager // Image metadata manager.
 	ImageStoreManager    rawStoreManager.ImageStoreManager    // Image store manager.
+	LiveFeed             LiveUpdates.LiveFeed                 // Optional; receives stat changes.
+	// RetryTopic and DeadLetterTopic receive the events whose handling failed; they default
+	// to Topic with a "-retry" and "-dead-letter" suffix.
+	RetryTopic      string
+	DeadLetterTopic string
 }
 
-// StartKafkaConsumer initializes and starts the Kafka consumer.
+// StartKafkaConsumer initializes and starts the Kafka consumer. Offsets are committed once an
+// event is handled; events whose handling fails go to the retry topic (see DeadLetters.go).
 func StartKafkaConsumer(config KafkaConsumerConfig) {
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:  []string{config.BrokerAddress},
@@ -35,52 +41,58 @@ func StartKafkaConsumer(config KafkaConsumerConfig) {
 	})
 
 	log.Printf("Kafka consumer started for topic %s on broker %s", config.Topic, config.BrokerAddress)
+	consumeMessages(reader, newFailureWriter(config.BrokerAddress), config, false)
+}
 
-	for {
-		msg, err := reader.ReadMessage(context.Background())
-		if err != nil {
-			log.Println("Error reading message:", err)
-			continue
-		}
-
-		log.Printf("Message received: key=%s, value=%s", string(msg.Key), string(msg.Value))
-
-		// Deserialize the Kafka message into a KafkaEvent.
-		var event KafkaEvent
-		if err := json.Unmarshal(msg.Value, &event); err != nil {
-			log.Println("Error deserializing Kafka message:", err)
-			continue
-		}
+// handleMessage deserializes a Kafka message and processes its event.
+func handleMessage(msg kafka.Message, config KafkaConsumerConfig) error {
+	log.Printf("Message received: key=%s, value=%s", string(msg.Key), string(msg.Value))
 
-		// Process the Kafka event using the full configuration.
-		handleKafkaEvent(event, config)
+	var event KafkaEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return permanentError{fmt.Errorf("error deserializing Kafka message: %w", err)}
 	}
+	return handleKafkaEvent(event, config)
 }
 
 // handleKafkaEvent processes a Kafka event based on its type.
-func handleKafkaEvent(event KafkaEvent, config KafkaConsumerConfig) {
+func handleKafkaEvent(event KafkaEvent, config KafkaConsumerConfig) error {
 	log.Printf("Processing event: ID=%s, Type=%s, Endpoint=%s, ClientID=%s",
 		event.EventID, event.EventType, event.Endpoint, event.ClientID)
+
+	// The payload was decoded into a generic value; re-encode it so each handler can
+	// unmarshal it into its own typed event.
+	payload, err := event.PayloadBytes()
+	if err != nil {
+		return permanentError{fmt.Errorf("error reading payload of event %s: %w", event.EventID, err)}
+	}
+
 	switch event.EventType {
 	// IMAGE EVENTS
 	case constants.IMAGE_UPLOAD:
 		fmt.Println("Handle ImageUpload event")
-		if err := HandleImageUploadEvent(event.Payload, config); err != nil {
-			log.Printf("Error processing image upload event: %v", err)
+		if err := HandleImageUploadEvent(payload, config); err != nil {
+			return fmt.Errorf("error processing image upload event: %w", err)
 		}
 	case constants.IMAGE_UPDATE:
 		fmt.Println("Handle ImageUpdate event")
-		if err := HandleImageUpdateEvent(event.Payload, config); err != nil {
-			log.Printf("Error processing image update event: %v", err)
+		if err := HandleImageUpdateEvent(payload, config); err != nil {
+			return fmt.Errorf("error processing image update event: %w", err)
 		}
 	case constants.IMAGE_STATS_UPDATE:
 		fmt.Println("Handle ImageStatsUpdate event")
-		if err := HandleImageStatsUpdateEvent(event.Payload, config); err != nil {
-			log.Printf("Error processing image stats update event: %v", err)
+		if err := HandleImageStatsUpdateEvent(payload, event.ClientID, config); err != nil {
+			return fmt.Errorf("error processing image stats update event: %w", err)
+		}
+	// USER INTERACTION EVENTS
+	case constants.USER_EVENT_ADD, constants.USER_EVENT_REMOVE:
+		if err := HandleUserInteractionEvent(event, config); err != nil {
+			return fmt.Errorf("error processing user interaction event: %w", err)
 		}
 	default:
 		log.Printf("Unhandled event type: %s", event.EventType)
 	}
+	return nil
 }
 
 // HandleImageUploadEvent processes an image upload event.
@@ -133,8 +145,8 @@ func HandleImageUpdateEvent(payload []byte, config KafkaConsumerConfig) error {
 	return nil
 }
 
-// HandleImageStatsUpdateEvent processes an image statistics update event.
-func HandleImageStatsUpdateEvent(payload []byte, config KafkaConsumerConfig) error {
+// HandleImageStatsUpdateEvent processes an image statistics update event on behalf of actorID.
+func HandleImageStatsUpdateEvent(payload []byte, actorID string, config KafkaConsumerConfig) error {
 	type ImageStatsUpdateEvent struct {
 		ImageID  string `json:"image_id"`
 		Likes    int    `json:"likes"`
@@ -163,7 +175,8 @@ func HandleImageStatsUpdateEvent(payload []byte, config KafkaConsumerConfig) err
 	meta["views"] = strconv.Itoa(event.Views)
 	meta["comments"] = strconv.Itoa(event.Comments)
 
-	if err := config.ImageMetadataManager.SetImageMetadata(event.ImageID, meta); err != nil {
+	if err := config.ImageMetadataManager.SetImageMetadata(event.ImageID, meta,
+		metaDataManager.MetadataChange{ActorID: actorID, Source: metaDataManager.SourceKafka}); err != nil {
 		return fmt.Errorf("failed to update metadata: %w", err)
 	}
 
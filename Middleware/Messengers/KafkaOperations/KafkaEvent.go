@@ -34,6 +34,14 @@ func NewKafkaEvent(eventType string, endpoint string, headers map[string]string,
 	}
 }
 
+// PayloadBytes returns the payload as raw JSON, whatever form it was decoded into.
+func (e KafkaEvent) PayloadBytes() ([]byte, error) {
+	if raw, ok := e.Payload.([]byte); ok {
+		return raw, nil
+	}
+	return json.Marshal(e.Payload)
+}
+
 // Helper function to generate a unique event ID
 func generateUniqueID() string {
 	return uuid.New().String() // Generates a new UUID
@@ -4,14 +4,19 @@ import (
 	"GOLA/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/segmentio/kafka-go"
 	"log"
 	"net/http"
+	"time"
 )
 
 var kafkaProducer *kafka.Writer
 
+// errProducerNotInitialized is returned when publishing before InitKafkaProducer succeeded.
+var errProducerNotInitialized = errors.New("kafka producer is not initialized")
+
 // InitKafkaProducer initializes the Kafka producer and returns an error if initialization fails
 func InitKafkaProducer(brokerAddress, topic string) error {
 	if brokerAddress == "" || topic == "" {
@@ -21,18 +26,18 @@ func InitKafkaProducer(brokerAddress, topic string) error {
 	kafkaProducer = &kafka.Writer{
 		Addr:     kafka.TCP(brokerAddress), // Kafka broker address
 		Topic:    topic,                    // Kafka topic
-		Balancer: &kafka.LeastBytes{},      // Distribute messages based on size
+		Balancer: &kafka.Hash{},            // Messages with the same key go to the same partition
+		// Requests wait for their event to be written; don't hold them for a full batch.
+		BatchTimeout: 10 * time.Millisecond,
 	}
 
-	// Check if the producer is successfully connected (try to write a dummy message to validate)
-	err := kafkaProducer.WriteMessages(context.Background(), kafka.Message{
-		Key:   []byte("test"),
-		Value: []byte("test"),
-	})
-
+	// Check that the broker is reachable without writing to the topic, whose consumers would
+	// have to dead-letter a probe message.
+	conn, err := kafka.DialContext(context.Background(), "tcp", brokerAddress)
 	if err != nil {
 		return fmt.Errorf("failed to initialize Kafka producer: %v", err)
 	}
+	conn.Close()
 
 	log.Println("Kafka producer initialized successfully")
 	return nil
@@ -48,27 +53,28 @@ func SendKafkaEvent(eventType string, headers map[string]string, queryParams map
 	// Build Kafka event
 	event := NewKafkaEvent(eventType, endpoint, headers, queryParams, payload, clientID)
 
-	// Serialize event to JSON
-	eventBytes, err := json.Marshal(event)
-	if err != nil {
-		log.Println("Error serializing Kafka event:", err)
-		return
-	}
-
-	// Send message to Kafka
-	err = kafkaProducer.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:   []byte(event.EventID), // Use EventID as the message key
-			Value: eventBytes,
-		},
-	)
-	if err != nil {
+	// Use EventID as the message key
+	if err := PublishKafkaEvent(context.Background(), event, event.EventID); err != nil {
 		log.Println("Error sending Kafka message:", err)
 	} else {
 		log.Printf("Kafka event sent successfully: %v\n", event.EventID)
 	}
 }
 
+// PublishKafkaEvent writes an event to Kafka and waits until the broker has accepted it. Events
+// with the same key land on the same partition, so they are consumed in the order they were
+// published.
+func PublishKafkaEvent(ctx context.Context, event KafkaEvent, key string) error {
+	if kafkaProducer == nil {
+		return errProducerNotInitialized
+	}
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error serializing Kafka event: %w", err)
+	}
+	return kafkaProducer.WriteMessages(ctx, kafka.Message{Key: []byte(key), Value: eventBytes})
+}
+
 // TaskHandler handles HTTP requests and sends Kafka events
 func TaskHandler(eventType string, w http.ResponseWriter, r *http.Request) {
 	// Extract relevant data
@@ -0,0 +1,49 @@
+package KafkaOperations
+
+import (
+	"GOLA/LiveUpdates"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// statsUpdateDelay coalesces the stat changes of an image: its viewers get at most one stats
+// update per delay, however many views and reactions arrive meanwhile.
+const statsUpdateDelay = time.Second
+
+var (
+	pendingStatsMu sync.Mutex
+	pendingStats   = map[string]bool{}
+)
+
+// scheduleStatsUpdate pushes the stats of the image to its viewers after statsUpdateDelay,
+// unless an update is already scheduled.
+func scheduleStatsUpdate(config KafkaConsumerConfig, imageID string) {
+	if config.LiveFeed == nil || imageID == "" {
+		return
+	}
+	pendingStatsMu.Lock()
+	defer pendingStatsMu.Unlock()
+	if pendingStats[imageID] {
+		return
+	}
+	pendingStats[imageID] = true
+
+	time.AfterFunc(statsUpdateDelay, func() {
+		// Cleared before the stats are read, so later changes schedule another update.
+		pendingStatsMu.Lock()
+		delete(pendingStats, imageID)
+		pendingStatsMu.Unlock()
+
+		ctx := context.Background()
+		stats, err := config.EventManager.GetStats(ctx, imageID)
+		if err != nil {
+			log.Printf("Error reading stats of %s for live update: %v", imageID, err)
+			return
+		}
+		if err := config.LiveFeed.Publish(ctx, imageID, LiveUpdates.EventStats, stats); err != nil {
+			log.Printf("Error pushing stats of %s: %v", imageID, err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package KafkaOperations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+
+	metaDataManager "GOLA/ImageManagers/Metadata"
+	"GOLA/Mentions"
+	constants "GOLA/constants"
+)
+
+// MentionConsumerConfig holds the configuration for the mention consumer. Like the search
+// index consumer it reads the main topic under its own consumer group.
+type MentionConsumerConfig struct {
+	BrokerAddress        string
+	Topic                string
+	GroupID              string
+	Mentions             Mentions.MentionManager
+	ImageMetadataManager metaDataManager.ImageMetadataManager
+}
+
+// StartMentionConsumer indexes the mentions and hashtags of image descriptions as their
+// metadata changes. Comments are indexed as they are written.
+func StartMentionConsumer(config MentionConsumerConfig) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{config.BrokerAddress},
+		Topic:    config.Topic,
+		GroupID:  config.GroupID,
+		MaxBytes: 10e6, // 10MB max per message.
+	})
+
+	log.Printf("Mention consumer started for topic %s with group %s", config.Topic, config.GroupID)
+
+	for {
+		msg, err := reader.ReadMessage(context.Background())
+		if err != nil {
+			log.Println("Error reading message:", err)
+			continue
+		}
+
+		var event KafkaEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			// Not every message on the topic is a KafkaEvent (e.g. the producer health check).
+			continue
+		}
+
+		if err := HandleMentionEvent(event, config); err != nil {
+			log.Printf("Error indexing mentions for event %s (%s): %v", event.EventID, event.EventType, err)
+		}
+	}
+}
+
+// HandleMentionEvent re-indexes the description of an image whose metadata changed, and
+// forgets the mentions and hashtags of deleted images. Other events are ignored.
+func HandleMentionEvent(event KafkaEvent, config MentionConsumerConfig) error {
+	switch event.EventType {
+	case constants.IMAGE_METADATA_UPDATE, constants.IMAGE_DELETE:
+	default:
+		return nil
+	}
+	if config.Mentions == nil {
+		return fmt.Errorf("mention manager not initialized")
+	}
+	imageID, err := searchEventImageID(event)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if event.EventType == constants.IMAGE_DELETE {
+		return config.Mentions.RemoveImage(ctx, imageID)
+	}
+
+	if config.ImageMetadataManager == nil {
+		return fmt.Errorf("metadata manager not initialized")
+	}
+	// Index the current description rather than the event, so that out-of-order delivery
+	// still converges on the latest metadata.
+	meta, err := config.ImageMetadataManager.GetImageMetadata(imageID)
+	if errors.Is(err, metaDataManager.ErrMetadataNotFound) {
+		return config.Mentions.RemoveImage(ctx, imageID)
+	}
+	if err != nil {
+		return err
+	}
+	source := Mentions.Source{
+		Kind:     Mentions.KindImageDescription,
+		ID:       imageID,
+		ImageID:  imageID,
+		AuthorID: meta[metaDataManager.OwnerIDKey],
+	}
+	return config.Mentions.IndexText(ctx, source, meta["description"])
+}
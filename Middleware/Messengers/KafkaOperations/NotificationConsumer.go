@@ -0,0 +1,127 @@
+package KafkaOperations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	metaDataManager "GOLA/ImageManagers/Metadata"
+	"GOLA/Mentions"
+	"GOLA/Notifications"
+	"GOLA/ShareLinkManagers"
+	"GOLA/UserEventManagers"
+	"GOLA/commons"
+	constants "GOLA/constants"
+)
+
+// NotificationConsumerConfig holds the configuration for the notification consumer. Like the
+// search index consumer it reads the main topic under its own consumer group.
+type NotificationConsumerConfig struct {
+	BrokerAddress        string
+	Topic                string
+	GroupID              string
+	Notifications        Notifications.NotificationManager
+	ImageMetadataManager metaDataManager.ImageMetadataManager
+}
+
+// StartNotificationConsumer notifies image owners of likes, comments, mentions and share link
+// opens as the user interactions arrive.
+func StartNotificationConsumer(config NotificationConsumerConfig) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{config.BrokerAddress},
+		Topic:    config.Topic,
+		GroupID:  config.GroupID,
+		MaxBytes: 10e6, // 10MB max per message.
+	})
+
+	log.Printf("Notification consumer started for topic %s with group %s", config.Topic, config.GroupID)
+
+	for {
+		msg, err := reader.ReadMessage(context.Background())
+		if err != nil {
+			log.Println("Error reading message:", err)
+			continue
+		}
+
+		var event KafkaEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			// Not every message on the topic is a KafkaEvent (e.g. the producer health check).
+			continue
+		}
+
+		if err := HandleNotificationEvent(event, config); err != nil {
+			log.Printf("Error notifying for event %s (%s): %v", event.EventID, event.EventType, err)
+		}
+	}
+}
+
+// HandleNotificationEvent turns a user interaction into notifications. Removals and event
+// types nobody is notified of are ignored.
+func HandleNotificationEvent(event KafkaEvent, config NotificationConsumerConfig) error {
+	if event.EventType != constants.USER_EVENT_ADD {
+		return nil
+	}
+	payload, err := event.PayloadBytes()
+	if err != nil {
+		return err
+	}
+	var input commons.EventInputModel
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return fmt.Errorf("failed to unmarshal user interaction event: %w", err)
+	}
+
+	var kind string
+	switch {
+	case input.EventType == UserEventManagers.EventTypeLike:
+		kind = Notifications.KindLike
+	case input.EventType == UserEventManagers.EventTypeComment:
+		kind = Notifications.KindComment
+	case input.EventType == UserEventManagers.EventTypeView && strings.HasPrefix(input.UserID, ShareLinkManagers.ShareViewerPrefix):
+		kind = Notifications.KindShare
+	default:
+		return nil
+	}
+
+	if config.Notifications == nil || config.ImageMetadataManager == nil {
+		return fmt.Errorf("notification consumer not initialized")
+	}
+	meta, err := config.ImageMetadataManager.GetImageMetadata(input.TargetID)
+	if err != nil {
+		// The image is gone; nobody is left to notify.
+		return nil
+	}
+
+	ctx := context.Background()
+	err = config.Notifications.Notify(ctx, Notifications.Event{
+		Kind:        kind,
+		RecipientID: meta[metaDataManager.OwnerIDKey],
+		ActorID:     input.UserID,
+		TargetID:    input.TargetID,
+		Excerpt:     input.Comment,
+	})
+	if err != nil || kind != Notifications.KindComment {
+		return err
+	}
+
+	// The owner already hears of the comment; others only when they can see the image.
+	for _, mentioned := range Mentions.ParseMentions(input.Comment) {
+		if metaDataManager.IsImageOwner(meta, mentioned) || !metaDataManager.CanViewImage(meta, mentioned) {
+			continue
+		}
+		err := config.Notifications.Notify(ctx, Notifications.Event{
+			Kind:        Notifications.KindMention,
+			RecipientID: mentioned,
+			ActorID:     input.UserID,
+			TargetID:    input.TargetID,
+			Excerpt:     input.Comment,
+		})
+		if err != nil {
+			log.Printf("Error notifying %s of a mention: %v", mentioned, err)
+		}
+	}
+	return nil
+}
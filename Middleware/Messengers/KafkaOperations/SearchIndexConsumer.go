@@ -0,0 +1,143 @@
+package KafkaOperations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	metaDataManager "GOLA/ImageManagers/Metadata"
+	"GOLA/SearchIndexers"
+	constants "GOLA/constants"
+)
+
+// SearchIndexConsumerConfig holds the configuration for the search index consumer.
+// It reads the same topic as the main consumer but under its own consumer group, so
+// indexing lag never holds back image processing.
+type SearchIndexConsumerConfig struct {
+	BrokerAddress        string
+	Topic                string
+	GroupID              string
+	Indexer              SearchIndexers.SearchIndexer
+	ImageMetadataManager metaDataManager.ImageMetadataManager
+}
+
+// StartSearchIndexConsumer keeps the search index in sync with image lifecycle events.
+func StartSearchIndexConsumer(config SearchIndexConsumerConfig) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{config.BrokerAddress},
+		Topic:    config.Topic,
+		GroupID:  config.GroupID,
+		MaxBytes: 10e6, // 10MB max per message.
+	})
+
+	log.Printf("Search index consumer started for topic %s with group %s", config.Topic, config.GroupID)
+
+	ctx := context.Background()
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			log.Println("Error reading message:", err)
+			continue
+		}
+
+		indexMessage(msg, config)
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("Error committing offset %d: %v", msg.Offset, err)
+		}
+	}
+}
+
+// indexMessage applies a message to the search index, retrying until it succeeds so that no
+// index update is lost. The index converges on the current metadata, so retrying an update
+// later is harmless; waiting only holds up this consumer group. Messages that can never be
+// indexed are skipped.
+func indexMessage(msg kafka.Message, config SearchIndexConsumerConfig) {
+	var event KafkaEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		// Not every message on the topic is a KafkaEvent.
+		return
+	}
+
+	backoff := time.Second
+	for {
+		err := HandleSearchIndexEvent(event, config)
+		if err == nil {
+			return
+		}
+		if isPermanent(err) {
+			log.Printf("Skipping event %s (%s): %v", event.EventID, event.EventType, err)
+			return
+		}
+		log.Printf("Error indexing event %s (%s), retrying in %s: %v", event.EventID, event.EventType, backoff, err)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// HandleSearchIndexEvent applies a single image lifecycle event to the search index.
+// Events that do not affect images are ignored.
+func HandleSearchIndexEvent(event KafkaEvent, config SearchIndexConsumerConfig) error {
+	switch event.EventType {
+	case constants.IMAGE_UPLOAD, constants.IMAGE_UPDATE, constants.IMAGE_STATS_UPDATE,
+		constants.IMAGE_METADATA_UPDATE, constants.IMAGE_DELETE:
+	default:
+		return nil
+	}
+
+	imageID, err := searchEventImageID(event)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	if event.EventType == constants.IMAGE_DELETE {
+		return config.Indexer.DeleteImage(imageID)
+	}
+
+	if config.ImageMetadataManager == nil {
+		return fmt.Errorf("metadata manager not initialized")
+	}
+	// Index the current state of the metadata rather than the event contents, so that
+	// out-of-order delivery still converges on the latest metadata.
+	meta, err := config.ImageMetadataManager.GetImageMetadata(imageID)
+	switch {
+	case errors.Is(err, metaDataManager.ErrMetadataNotFound):
+		// Freshly uploaded images may not have metadata yet; index what we know.
+		meta = map[string]string{}
+	case err != nil:
+		// Indexing an empty document would overwrite the stored one.
+		return fmt.Errorf("error loading metadata of %s: %w", imageID, err)
+	}
+	return config.Indexer.IndexImage(SearchIndexers.BuildImageDocument(imageID, meta))
+}
+
+// searchEventImageID extracts the image ID from the payload or query parameters of an event.
+func searchEventImageID(event KafkaEvent) (string, error) {
+	payload, err := event.PayloadBytes()
+	if err != nil {
+		return "", err
+	}
+	var ids struct {
+		ImageID  string `json:"image_id"`
+		Filename string `json:"filename"`
+	}
+	// A payload that is not an object simply carries no IDs.
+	_ = json.Unmarshal(payload, &ids)
+
+	switch {
+	case ids.ImageID != "":
+		return ids.ImageID, nil
+	case ids.Filename != "":
+		return ids.Filename, nil
+	case event.QueryParams["id"] != "":
+		return event.QueryParams["id"], nil
+	default:
+		return "", fmt.Errorf("event carries no image ID")
+	}
+}
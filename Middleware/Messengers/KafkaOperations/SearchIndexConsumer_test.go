@@ -0,0 +1,84 @@
+package KafkaOperations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/SearchIndexers"
+	"GOLA/SearchIndexers/FakeElasticsearch"
+	constants "GOLA/constants"
+)
+
+// fixedMetadata is a metadata store holding a fixed set of images.
+type fixedMetadata struct {
+	Metadata.ImageMetadataManager
+	images map[string]map[string]string
+	err    error
+}
+
+func (m fixedMetadata) GetImageMetadata(imageID string) (map[string]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	meta, ok := m.images[imageID]
+	if !ok {
+		return nil, Metadata.ErrMetadataNotFound
+	}
+	return meta, nil
+}
+
+func TestHandleSearchIndexEventIndexesAndDeletes(t *testing.T) {
+	server := FakeElasticsearch.NewServer()
+	defer server.Close()
+	indexer := &SearchIndexers.ElasticsearchIndexer{BaseURL: server.URL, IndexName: "images", HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	if err := indexer.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	config := SearchIndexConsumerConfig{
+		Indexer: indexer,
+		ImageMetadataManager: fixedMetadata{images: map[string]map[string]string{
+			"a.jpg": {"title": "Sunset", "owner_id": "alice"},
+		}},
+	}
+
+	update := NewKafkaEvent(constants.IMAGE_METADATA_UPDATE, "/images/metadata", nil, nil,
+		map[string]string{"image_id": "a.jpg"}, "alice")
+	if err := HandleSearchIndexEvent(update, config); err != nil {
+		t.Fatalf("indexing update: %v", err)
+	}
+	raw, ok := server.Document("images", "a.jpg")
+	if !ok {
+		t.Fatal("a.jpg was not indexed")
+	}
+	var doc SearchIndexers.ImageDocument
+	if err := json.Unmarshal(raw, &doc); err != nil || doc.Title != "Sunset" {
+		t.Fatalf("unexpected document %s (%v)", raw, err)
+	}
+
+	// Events unrelated to images are ignored.
+	if err := HandleSearchIndexEvent(NewKafkaEvent("Other", "/", nil, nil, nil, ""), config); err != nil {
+		t.Fatalf("ignoring event: %v", err)
+	}
+
+	// A failing metadata store must not replace the document with an empty one.
+	outage := config
+	outage.ImageMetadataManager = fixedMetadata{err: errors.New("connection refused")}
+	if err := HandleSearchIndexEvent(update, outage); err == nil || isPermanent(err) {
+		t.Fatalf("indexing during an outage = %v, want a transient error", err)
+	}
+	if raw, _ := server.Document("images", "a.jpg"); json.Unmarshal(raw, &doc) != nil || doc.Title != "Sunset" {
+		t.Fatalf("document changed during an outage: %s", raw)
+	}
+
+	remove := NewKafkaEvent(constants.IMAGE_DELETE, "/image", nil, map[string]string{"id": "a.jpg"}, nil, "alice")
+	if err := HandleSearchIndexEvent(remove, config); err != nil {
+		t.Fatalf("indexing delete: %v", err)
+	}
+	if _, ok := server.Document("images", "a.jpg"); ok {
+		t.Error("a.jpg is still indexed after its deletion")
+	}
+}
@@ -0,0 +1,182 @@
+package KafkaOperations
+
+import (
+	"GOLA/Deserializers"
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/UserEventManagers"
+	redisCache "GOLA/caches/Redis"
+	"GOLA/commons"
+	"GOLA/constants"
+	"GOLA/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// User interactions (likes, views, comments, ...) are published to Kafka by the HTTP handlers
+// and recorded by the consumer, so that spikes queue up in the broker instead of the database.
+// The IDs of applied events are remembered in Redis for appliedEventTTL, so that events Kafka
+// delivers again are applied once.
+const (
+	appliedEventKey = "kafka:applied:%s"
+	appliedEventTTL = 24 * time.Hour
+)
+
+// interactionEvents validates interactions before they are published; set by SetEventManager.
+var interactionEvents UserEventManagers.EventManager
+
+// SetEventManager is called from main to set the manager whose event types interactions are
+// validated against.
+func SetEventManager(manager UserEventManagers.EventManager) {
+	interactionEvents = manager
+}
+
+// InteractionPublisher publishes user interactions to Kafka. It implements
+// UserEventManagers.EventPublisher.
+type InteractionPublisher struct{}
+
+// PublishEvent publishes an event of any registered type.
+func (InteractionPublisher) PublishEvent(ctx context.Context, eventType string, input *commons.EventInputModel) (string, error) {
+	return publishInteraction(ctx, constants.USER_EVENT_ADD, eventType, input, "")
+}
+
+// PublishRemoval publishes the retraction of a reaction.
+func (InteractionPublisher) PublishRemoval(ctx context.Context, eventType string, input *commons.EventInputModel) (string, error) {
+	return publishInteraction(ctx, constants.USER_EVENT_REMOVE, eventType, input, "")
+}
+
+func publishInteraction(ctx context.Context, kind, eventType string, input *commons.EventInputModel, endpoint string) (string, error) {
+	payload := *input
+	payload.EventType = eventType
+	event := NewKafkaEvent(kind, endpoint, nil, nil, payload, input.UserID)
+	// Keyed by user and target, so the changes of a reaction are consumed in order.
+	if err := PublishKafkaEvent(ctx, event, input.UserID+"|"+input.TargetID); err != nil {
+		return "", fmt.Errorf("error publishing %s event: %w", eventType, err)
+	}
+	return event.EventID, nil
+}
+
+// InteractionHandler validates a user interaction and publishes it to Kafka. It answers 202
+// with {"event_id"} once the broker has the event; the consumer records it. The event is
+// recorded for the caller; an empty eventType takes the type from "event_type" in the body.
+// With removal set, the retraction of the reaction is published instead.
+func InteractionHandler(eventType string, removal bool, w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+	input, err := Deserializers.DeserializeEventInput(bodyBytes)
+	if eventType == "" && input != nil {
+		eventType = input.EventType
+	}
+	if err != nil || eventType == "" {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+	// Events are always recorded for the authenticated caller.
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	input.UserID = clientID
+	// Engagement on private images is limited to their owner; retracting it is always allowed.
+	if !removal {
+		if _, ok := Metadata.AuthorizeImageRequest(w, r, imageMetadataManager, input.TargetID, false); !ok {
+			return
+		}
+	}
+	if interactionEvents == nil {
+		http.Error(w, "Event manager not initialized", http.StatusInternalServerError)
+		return
+	}
+	if err := UserEventManagers.CheckEvent(interactionEvents, eventType, input, removal); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kind := constants.USER_EVENT_ADD
+	if removal {
+		kind = constants.USER_EVENT_REMOVE
+	}
+	eventID, err := publishInteraction(r.Context(), kind, eventType, input, r.URL.Path)
+	if err != nil {
+		log.Printf("Error queueing interaction: %v", err)
+		http.Error(w, "Failed to queue event", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"event_id": eventID})
+}
+
+// HandleUserInteractionEvent records a published interaction with the event manager. Events
+// that were already applied are skipped, and events the event manager rejects are logged and
+// dropped, since they would be rejected again. Failures of the event store are returned, for
+// the consumer to retry the event later. Without Redis, events delivered twice are applied
+// twice.
+func HandleUserInteractionEvent(event KafkaEvent, config KafkaConsumerConfig) error {
+	if config.EventManager == nil {
+		return fmt.Errorf("event manager not initialized")
+	}
+	payload, err := event.PayloadBytes()
+	if err != nil {
+		return err
+	}
+	var input commons.EventInputModel
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return fmt.Errorf("failed to unmarshal user interaction event: %w", err)
+	}
+
+	ctx := context.Background()
+	client := redisCache.RedisClient
+	key := fmt.Sprintf(appliedEventKey, event.EventID)
+	if client != nil {
+		applied, err := client.Exists(ctx, key).Result()
+		if err != nil {
+			log.Printf("Redis unavailable for de-duplication of event %s: %v", event.EventID, err)
+		} else if applied > 0 {
+			log.Printf("Skipping event %s, already applied", event.EventID)
+			return nil
+		}
+	}
+
+	err = applyInteraction(ctx, event.EventType, &input, config.EventManager)
+	if err != nil && !isRejectedInteraction(err) {
+		return fmt.Errorf("error applying event %s: %w", event.EventID, err)
+	}
+	if err == nil {
+		scheduleStatsUpdate(config, input.TargetID)
+	} else {
+		log.Printf("Rejected event %s: %v", event.EventID, err)
+	}
+	// Rejected events are marked too: they would be rejected again.
+	if client != nil {
+		if markErr := client.Set(ctx, key, 1, appliedEventTTL).Err(); markErr != nil {
+			log.Printf("Error marking event %s as applied: %v", event.EventID, markErr)
+		}
+	}
+	return nil
+}
+
+func applyInteraction(ctx context.Context, kind string, input *commons.EventInputModel, manager UserEventManagers.EventManager) error {
+	if kind == constants.USER_EVENT_REMOVE {
+		_, err := manager.RemoveEvent(ctx, input.EventType, input)
+		return err
+	}
+	_, err := manager.AddEvent(ctx, input.EventType, input)
+	return err
+}
+
+// isRejectedInteraction reports whether the event itself is at fault, so retrying is useless.
+func isRejectedInteraction(err error) bool {
+	return errors.Is(err, UserEventManagers.ErrUnknownEventType) ||
+		errors.Is(err, UserEventManagers.ErrInvalidEvent) ||
+		errors.Is(err, UserEventManagers.ErrEventNotRemovable)
+}
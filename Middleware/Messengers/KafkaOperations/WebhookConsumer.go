@@ -0,0 +1,122 @@
+package KafkaOperations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+
+	metaDataManager "GOLA/ImageManagers/Metadata"
+	"GOLA/UserEventManagers"
+	"GOLA/Webhooks"
+	"GOLA/commons"
+	constants "GOLA/constants"
+)
+
+// WebhookConsumerConfig holds the configuration for the webhook consumer. Like the search
+// index consumer it reads the main topic under its own consumer group; it only records
+// deliveries, which the webhook manager sends in the background.
+type WebhookConsumerConfig struct {
+	BrokerAddress        string
+	Topic                string
+	GroupID              string
+	Webhooks             Webhooks.WebhookManager
+	ImageMetadataManager metaDataManager.ImageMetadataManager
+}
+
+// StartWebhookConsumer enqueues webhook deliveries for image uploads, deletions and comments.
+func StartWebhookConsumer(config WebhookConsumerConfig) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{config.BrokerAddress},
+		Topic:    config.Topic,
+		GroupID:  config.GroupID,
+		MaxBytes: 10e6, // 10MB max per message.
+	})
+
+	log.Printf("Webhook consumer started for topic %s with group %s", config.Topic, config.GroupID)
+
+	for {
+		msg, err := reader.ReadMessage(context.Background())
+		if err != nil {
+			log.Println("Error reading message:", err)
+			continue
+		}
+
+		var event KafkaEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			// Not every message on the topic is a KafkaEvent (e.g. the producer health check).
+			continue
+		}
+
+		if err := HandleWebhookEvent(event, config); err != nil {
+			log.Printf("Error enqueueing webhooks for event %s (%s): %v", event.EventID, event.EventType, err)
+		}
+	}
+}
+
+// webhookData is the "data" of a webhook payload.
+type webhookData struct {
+	ImageID string `json:"image_id"`
+	ActorID string `json:"actor_id"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// HandleWebhookEvent enqueues a delivery of the event to the webhooks of the image owner.
+// Events nobody subscribes to are ignored.
+func HandleWebhookEvent(event KafkaEvent, config WebhookConsumerConfig) error {
+	if config.Webhooks == nil {
+		return fmt.Errorf("webhook manager not initialized")
+	}
+	payload, err := event.PayloadBytes()
+	if err != nil {
+		return err
+	}
+
+	var webhookEvent, ownerID string
+	var data webhookData
+	switch event.EventType {
+	case constants.IMAGE_UPLOADED, constants.IMAGE_DELETE:
+		var ids struct {
+			ImageID string `json:"image_id"`
+			OwnerID string `json:"owner_id"`
+		}
+		// A payload that is not an object simply carries no IDs.
+		_ = json.Unmarshal(payload, &ids)
+		webhookEvent = Webhooks.EventImageUploaded
+		if event.EventType == constants.IMAGE_DELETE {
+			webhookEvent = Webhooks.EventImageDeleted
+		}
+		ownerID = ids.OwnerID
+		data = webhookData{ImageID: ids.ImageID, ActorID: event.ClientID}
+	case constants.USER_EVENT_ADD:
+		var input commons.EventInputModel
+		if err := json.Unmarshal(payload, &input); err != nil {
+			return fmt.Errorf("failed to unmarshal user interaction event: %w", err)
+		}
+		if input.EventType != UserEventManagers.EventTypeComment {
+			return nil
+		}
+		if config.ImageMetadataManager == nil {
+			return fmt.Errorf("metadata manager not initialized")
+		}
+		meta, err := config.ImageMetadataManager.GetImageMetadata(input.TargetID)
+		if err != nil {
+			// The image is gone; nobody is left to notify.
+			return nil
+		}
+		webhookEvent = Webhooks.EventImageCommented
+		ownerID = meta[metaDataManager.OwnerIDKey]
+		data = webhookData{ImageID: input.TargetID, ActorID: input.UserID, Comment: input.Comment}
+	default:
+		return nil
+	}
+	// Images deleted without naming their owner, e.g. by erasure requests, have nobody to notify.
+	if ownerID == "" || data.ImageID == "" {
+		return nil
+	}
+
+	_, err = config.Webhooks.Enqueue(context.Background(), ownerID, event.EventID, webhookEvent, data)
+	return err
+}
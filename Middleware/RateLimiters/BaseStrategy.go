@@ -4,6 +4,7 @@ import (
 	"GOLA/Deserializers"
 	"fmt"
 	"golang.org/x/time/rate"
+	"net"
 	"net/http"
 	"sync"
 )
@@ -60,3 +61,19 @@ func (rl *RateLimiter) Apply(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// ApplyByIP enforces rate limiting per client address, for routes that carry no client
+// credentials. Addresses are kept apart from client IDs in the limiter map.
+func (rl *RateLimiter) ApplyByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !rl.GetLimiter("ip:" + host).Allow() {
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,182 @@
+package Moderation
+
+import (
+	"GOLA/Deserializers"
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/UserEventManagers"
+	"GOLA/commons/models"
+	"GOLA/utils"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ModerationHandlers exposes the review queue and user reports over HTTP. Every handler
+// expects the JWT middleware to have put the caller's client ID into the request context.
+// The queue and reviews are restricted to admins.
+type ModerationHandlers struct {
+	Moderator *Moderator
+}
+
+// HandleQueue returns a page of the review queue, oldest first. ?status= selects pending
+// (default), approved or rejected items, ?kind= a kind of content, ?cursor= continues from a
+// previous page and ?limit= sets the page size.
+func (h *ModerationHandlers) HandleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	params := r.URL.Query()
+	query := QueueQuery{Status: params.Get("status"), Kind: params.Get("kind"), Cursor: params.Get("cursor")}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+	page, err := h.Moderator.Queue.ListQueue(r.Context(), query)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandleReview approves or rejects a pending item.
+func (h *ModerationHandlers) HandleReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+	input, err := Deserializers.DeserializeModerationReviewInput(bodyBytes)
+	if err != nil || input.ID <= 0 {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+	if input.Decision != "approve" && input.Decision != "reject" {
+		http.Error(w, `decision must be "approve" or "reject"`, http.StatusBadRequest)
+		return
+	}
+	item, err := h.Moderator.Review(r.Context(), input.ID, clientID, input.Decision == "approve", input.Note)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, item)
+}
+
+// HandleReport reports a comment, image title or image description the caller can see.
+func (h *ModerationHandlers) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+	input, err := Deserializers.DeserializeModerationReportInput(bodyBytes)
+	if err != nil || input.ContentID == "" {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	item := models.ModerationItem{Kind: input.Kind, ContentID: input.ContentID}
+	switch input.Kind {
+	case KindComment:
+		commentID, err := strconv.ParseInt(input.ContentID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+		comment, err := h.Moderator.Comments.GetComment(r.Context(), commentID)
+		if err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		if _, ok := Metadata.AuthorizeImageRequest(w, r, h.Moderator.ImageMetadataManager, comment.TargetID, false); !ok {
+			return
+		}
+		// Hidden comments are already out of sight; reporting them would also reveal them.
+		if comment.Deleted || comment.State == models.CommentHidden {
+			writeModerationError(w, UserEventManagers.ErrCommentNotFound)
+			return
+		}
+		item.ImageID, item.AuthorID, item.Text = comment.TargetID, comment.AuthorID, comment.Content
+	case KindImageTitle, KindImageDescription:
+		meta, ok := Metadata.AuthorizeImageRequest(w, r, h.Moderator.ImageMetadataManager, input.ContentID, false)
+		if !ok {
+			return
+		}
+		key, _ := metadataKey(input.Kind)
+		if meta[key] == "" {
+			http.Error(w, "The image has no "+key, http.StatusNotFound)
+			return
+		}
+		item.ImageID, item.AuthorID, item.Text = input.ContentID, meta[Metadata.OwnerIDKey], meta[key]
+	default:
+		http.Error(w, "Unknown kind", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Moderator.Report(r.Context(), item, clientID, input.Reason); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	// Reporters are not shown the queue item, which belongs to the moderators.
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requireAdmin returns the caller's client ID if they are an admin, and writes an error
+// otherwise.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return "", false
+	}
+	if !utils.IsAdmin(clientID) {
+		http.Error(w, "Only admins may moderate content", http.StatusForbidden)
+		return "", false
+	}
+	return clientID, true
+}
+
+// writeModerationError maps moderation errors onto HTTP status codes.
+func writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrItemNotFound):
+		http.Error(w, "Moderation item not found", http.StatusNotFound)
+	case errors.Is(err, UserEventManagers.ErrCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyReviewed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidModerationQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Moderation operation failed: %v", err)
+		http.Error(w, "Moderation operation failed", http.StatusInternalServerError)
+	}
+}
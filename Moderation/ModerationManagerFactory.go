@@ -0,0 +1,61 @@
+package Moderation
+
+import (
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Page sizes of the review queue.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	// ErrItemNotFound is returned for unknown moderation items.
+	ErrItemNotFound = errors.New("moderation item not found")
+	// ErrAlreadyReviewed is returned when an item that was already reviewed is reviewed again.
+	ErrAlreadyReviewed = errors.New("moderation item already reviewed")
+	// ErrInvalidModerationQuery is returned for malformed cursors, unknown filters and oversized
+	// pages.
+	ErrInvalidModerationQuery = errors.New("invalid moderation query")
+)
+
+// QueueQuery selects a page of the review queue, oldest first.
+type QueueQuery struct {
+	// Status defaults to pending.
+	Status string
+	Kind   string
+	Cursor string
+	Limit  int
+}
+
+// ModerationManager stores the review queue. Content has at most one pending item, which
+// later screenings and reports of the content update.
+type ModerationManager interface {
+	Initialize() error
+	// Enqueue files content for review, or updates its pending item: the text is replaced, the
+	// reasons are merged and the stricter action is kept.
+	Enqueue(ctx context.Context, item models.ModerationItem) (*models.ModerationItem, error)
+	// Report records a user report of content, filing it for review if needed, and returns the
+	// item with the number of distinct reporters.
+	Report(ctx context.Context, item models.ModerationItem, reporterID, reason string) (*models.ModerationItem, error)
+	// SetAction records what moderation did with the content of a pending item.
+	SetAction(ctx context.Context, itemID int64, action string) error
+	GetItem(ctx context.Context, itemID int64) (*models.ModerationItem, error)
+	ListQueue(ctx context.Context, query QueueQuery) (*models.ModerationQueuePage, error)
+	// Resolve records the review of a pending item.
+	Resolve(ctx context.Context, itemID int64, status, reviewerID, note string) (*models.ModerationItem, error)
+}
+
+// GetModerationManager returns an instance of the requested moderation manager.
+func GetModerationManager(storageType string) (ModerationManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresModerationManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported moderation storage type: %s", storageType)
+	}
+}
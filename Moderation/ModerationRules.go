@@ -0,0 +1,344 @@
+package Moderation
+
+import (
+	redisCache "GOLA/caches/Redis"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kinds of moderated content.
+const (
+	KindComment          = "comment"
+	KindImageTitle       = "image_title"
+	KindImageDescription = "image_description"
+)
+
+// ErrRateLimited is returned when an author writes faster than the rate rule allows.
+var ErrRateLimited = errors.New("posting too fast, try again later")
+
+// Content is a piece of user-written text to screen.
+type Content struct {
+	Kind     string
+	AuthorID string
+	ImageID  string
+	Text     string
+}
+
+// Action is what moderation does with content; stricter actions have higher values.
+type Action int
+
+const (
+	// ActionAllow shows the content.
+	ActionAllow Action = iota
+	// ActionQueue shows the content and queues it for review.
+	ActionQueue
+	// ActionHide hides the content until a moderator approves it.
+	ActionHide
+	// ActionReject refuses the content.
+	ActionReject
+)
+
+// String returns the name under which the action is recorded in the review queue.
+func (a Action) String() string {
+	switch a {
+	case ActionQueue:
+		return "queued"
+	case ActionHide:
+		return "hidden"
+	case ActionReject:
+		return "rejected"
+	default:
+		return "allowed"
+	}
+}
+
+// Verdict is the outcome of screening. Reasons explain every action but ActionAllow.
+type Verdict struct {
+	Action  Action
+	Reasons []string
+}
+
+// Rule is one check of the pipeline. Rules are pluggable: anything implementing Rule can be
+// added to Pipeline.Rules.
+type Rule interface {
+	Check(ctx context.Context, content Content) (Verdict, error)
+}
+
+// Pipeline runs every rule over content and keeps the strictest verdict, with the reasons of
+// every rule that objected.
+type Pipeline struct {
+	Rules []Rule
+}
+
+// Screen runs the rules. A rule that fails is skipped, so that content is not refused because
+// a check was unavailable.
+func (p *Pipeline) Screen(ctx context.Context, content Content) Verdict {
+	var verdict Verdict
+	for _, rule := range p.Rules {
+		v, err := rule.Check(ctx, content)
+		if err != nil {
+			log.Printf("Moderation rule %T failed: %v", rule, err)
+			continue
+		}
+		if v.Action == ActionAllow {
+			continue
+		}
+		verdict.Reasons = append(verdict.Reasons, v.Reasons...)
+		if v.Action > verdict.Action {
+			verdict.Action = v.Action
+		}
+		if verdict.Action == ActionReject {
+			break
+		}
+	}
+	return verdict
+}
+
+// Config configures the built-in rules. Zero values disable a rule.
+type Config struct {
+	// BlockedWords hide content containing them; FlaggedWords queue it. Entries match whole
+	// words or phrases, ignoring case and punctuation.
+	BlockedWords []string `json:"blocked_words"`
+	FlaggedWords []string `json:"flagged_words"`
+	// MaxLinks queues content with more links.
+	MaxLinks int `json:"max_links"`
+	// RateLimit refuses more than RateLimit pieces of content of a kind per author and
+	// RateWindow, e.g. "1m".
+	RateLimit  int    `json:"rate_limit"`
+	RateWindow string `json:"rate_window"`
+	// DuplicateWindow hides comments an author already posted within the window, e.g. "10m".
+	DuplicateWindow string `json:"duplicate_window"`
+	// ReportThreshold hides a comment once that many users reported it.
+	ReportThreshold int `json:"report_threshold"`
+}
+
+// DefaultConfig returns the configuration used without a configuration file.
+func DefaultConfig() Config {
+	return Config{
+		MaxLinks:        2,
+		RateLimit:       10,
+		RateWindow:      "1m",
+		DuplicateWindow: "10m",
+		ReportThreshold: 3,
+	}
+}
+
+// LoadConfig returns the default configuration overridden by the JSON file at path. An empty
+// path returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading moderation config: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("error parsing moderation config: %w", err)
+	}
+	return config, nil
+}
+
+// NewPipeline builds the pipeline of the built-in rules from a configuration.
+func NewPipeline(config Config) (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	if config.RateLimit > 0 {
+		window, err := time.ParseDuration(config.RateWindow)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid moderation rate_window %q", config.RateWindow)
+		}
+		pipeline.Rules = append(pipeline.Rules, &RateRule{Limit: config.RateLimit, Window: window})
+	}
+	if len(config.BlockedWords) > 0 || len(config.FlaggedWords) > 0 {
+		pipeline.Rules = append(pipeline.Rules, NewWordListRule(config.BlockedWords, config.FlaggedWords))
+	}
+	if config.MaxLinks > 0 {
+		pipeline.Rules = append(pipeline.Rules, &LinkRule{MaxLinks: config.MaxLinks})
+	}
+	spam := &SpamRule{}
+	if config.DuplicateWindow != "" {
+		window, err := time.ParseDuration(config.DuplicateWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation duplicate_window %q", config.DuplicateWindow)
+		}
+		spam.DuplicateWindow = window
+	}
+	pipeline.Rules = append(pipeline.Rules, spam)
+	return pipeline, nil
+}
+
+// wordPattern splits text into words for matching word lists.
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}']+`)
+
+// normalizeWords lowercases text and reduces it to its words, separated and surrounded by
+// single spaces, so that phrases can be matched with strings.Contains.
+func normalizeWords(text string) string {
+	return " " + strings.Join(wordPattern.FindAllString(strings.ToLower(text), -1), " ") + " "
+}
+
+// WordListRule hides content containing blocked words and queues content containing flagged
+// words.
+type WordListRule struct {
+	blocked []string
+	flagged []string
+}
+
+// NewWordListRule prepares the word lists for matching.
+func NewWordListRule(blocked, flagged []string) *WordListRule {
+	rule := &WordListRule{}
+	for _, word := range blocked {
+		if normalized := normalizeWords(word); strings.TrimSpace(normalized) != "" {
+			rule.blocked = append(rule.blocked, normalized)
+		}
+	}
+	for _, word := range flagged {
+		if normalized := normalizeWords(word); strings.TrimSpace(normalized) != "" {
+			rule.flagged = append(rule.flagged, normalized)
+		}
+	}
+	return rule
+}
+
+// Check matches the text against the word lists.
+func (r *WordListRule) Check(_ context.Context, content Content) (Verdict, error) {
+	text := normalizeWords(content.Text)
+	for _, word := range r.blocked {
+		if strings.Contains(text, word) {
+			return Verdict{Action: ActionHide, Reasons: []string{"blocked word"}}, nil
+		}
+	}
+	for _, word := range r.flagged {
+		if strings.Contains(text, word) {
+			return Verdict{Action: ActionQueue, Reasons: []string{"flagged word"}}, nil
+		}
+	}
+	return Verdict{}, nil
+}
+
+// linkPattern matches web links, with or without scheme.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkRule queues content with more than MaxLinks links.
+type LinkRule struct {
+	MaxLinks int
+}
+
+// Check counts the links in the text.
+func (r *LinkRule) Check(_ context.Context, content Content) (Verdict, error) {
+	if links := len(linkPattern.FindAllString(content.Text, -1)); links > r.MaxLinks {
+		return Verdict{Action: ActionQueue, Reasons: []string{fmt.Sprintf("%d links", links)}}, nil
+	}
+	return Verdict{}, nil
+}
+
+// Spam heuristics: long runs of one character and text written mostly in capitals are queued.
+const (
+	maxRepeatedRun     = 10
+	minShoutingLetters = 20
+	shoutingRatio      = 0.8
+)
+
+// SpamRule queues text that looks like spam and hides comments an author repeats within
+// DuplicateWindow. Duplicates are only detected when Redis is available.
+type SpamRule struct {
+	DuplicateWindow time.Duration
+}
+
+// Check applies the spam heuristics.
+func (r *SpamRule) Check(ctx context.Context, content Content) (Verdict, error) {
+	if content.Kind == KindComment && r.DuplicateWindow > 0 {
+		duplicate, err := r.isDuplicate(ctx, content)
+		if err != nil {
+			return Verdict{}, err
+		}
+		if duplicate {
+			return Verdict{Action: ActionHide, Reasons: []string{"duplicate comment"}}, nil
+		}
+	}
+
+	var reasons []string
+	run, longest := 0, 0
+	var previous rune
+	letters, upper := 0, 0
+	for _, c := range content.Text {
+		if c == previous && !unicode.IsSpace(c) {
+			run++
+		} else {
+			run = 1
+		}
+		previous = c
+		if run > longest {
+			longest = run
+		}
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				upper++
+			}
+		}
+	}
+	if longest >= maxRepeatedRun {
+		reasons = append(reasons, "repeated characters")
+	}
+	if letters >= minShoutingLetters && float64(upper) >= shoutingRatio*float64(letters) {
+		reasons = append(reasons, "excessive capitals")
+	}
+	if len(reasons) > 0 {
+		return Verdict{Action: ActionQueue, Reasons: reasons}, nil
+	}
+	return Verdict{}, nil
+}
+
+// isDuplicate remembers the text for DuplicateWindow and reports whether the author already
+// posted it.
+func (r *SpamRule) isDuplicate(ctx context.Context, content Content) (bool, error) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return false, nil
+	}
+	sum := sha256.Sum256([]byte(normalizeWords(content.Text)))
+	key := "moderation:recent:" + content.AuthorID + ":" + hex.EncodeToString(sum[:16])
+	fresh, err := client.SetNX(ctx, key, 1, r.DuplicateWindow).Result()
+	if err != nil {
+		return false, err
+	}
+	return !fresh, nil
+}
+
+// RateRule refuses content once an author wrote more than Limit pieces of a kind within
+// Window. Windows are fixed and counted in Redis; without Redis nothing is refused.
+type RateRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Check counts the content towards the author's current window.
+func (r *RateRule) Check(ctx context.Context, content Content) (Verdict, error) {
+	client := redisCache.RedisClient
+	if client == nil || content.AuthorID == "" {
+		return Verdict{}, nil
+	}
+	window := time.Now().UnixNano() / int64(r.Window)
+	key := fmt.Sprintf("moderation:rate:%s:%s:%d", content.Kind, content.AuthorID, window)
+	pipe := client.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, r.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Verdict{}, err
+	}
+	if count.Val() > int64(r.Limit) {
+		return Verdict{Action: ActionReject, Reasons: []string{"rate limit exceeded"}}, nil
+	}
+	return Verdict{}, nil
+}
@@ -0,0 +1,238 @@
+package Moderation
+
+import (
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/Mentions"
+	"GOLA/UserEventManagers"
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// metadataFields maps the moderated kinds of image text to their metadata keys.
+var metadataFields = []struct {
+	Kind string
+	Key  string
+}{
+	{KindImageTitle, "title"},
+	{KindImageDescription, "description"},
+}
+
+// metadataKey returns the metadata key of a kind of image text.
+func metadataKey(kind string) (string, bool) {
+	for _, field := range metadataFields {
+		if field.Kind == kind {
+			return field.Key, true
+		}
+	}
+	return "", false
+}
+
+// Moderator screens comments and image text with the pipeline, files what the pipeline objects
+// to in the review queue and carries out reports and reviews.
+//
+// Comments are stored whatever the verdict: queued comments are flagged and stay visible,
+// hidden ones are only shown to their author and to moderators. Titles and descriptions the
+// pipeline objects to are held back instead: the previous value stays in place until a
+// moderator approves the new one.
+type Moderator struct {
+	Pipeline             *Pipeline
+	Queue                ModerationManager
+	Comments             UserEventManagers.CommentManager
+	ImageMetadataManager Metadata.ImageMetadataManager
+	// ReportThreshold hides a comment once that many users reported it; 0 never hides.
+	ReportThreshold int
+	// OnMetadataChanged, when set, is called after a review changed the metadata of an image.
+	OnMetadataChanged func(imageID, actorID string)
+}
+
+// ScreenComment screens the content of a new or edited comment.
+func (m *Moderator) ScreenComment(ctx context.Context, authorID, targetID, content string) (UserEventManagers.CommentScreening, error) {
+	verdict := m.Pipeline.Screen(ctx, Content{Kind: KindComment, AuthorID: authorID, ImageID: targetID, Text: content})
+	screening := UserEventManagers.CommentScreening{State: models.CommentVisible, Reasons: verdict.Reasons}
+	switch verdict.Action {
+	case ActionReject:
+		return screening, fmt.Errorf("%w: %s", UserEventManagers.ErrCommentRateLimited, strings.Join(verdict.Reasons, ", "))
+	case ActionHide:
+		screening.State = models.CommentHidden
+	case ActionQueue:
+		screening.State = models.CommentFlagged
+	}
+	return screening, nil
+}
+
+// ApplyScreening flags or hides a stored comment and queues it for review. A hidden comment
+// stays hidden whatever the screening of its new content.
+func (m *Moderator) ApplyScreening(ctx context.Context, comment *models.Comment, screening UserEventManagers.CommentScreening) (*models.Comment, error) {
+	if screening.State == "" || screening.State == models.CommentVisible {
+		return comment, nil
+	}
+	state := screening.State
+	if comment.State == models.CommentHidden {
+		state = models.CommentHidden
+	}
+	if state != comment.State {
+		updated, err := m.Comments.SetCommentState(ctx, comment.ID, state)
+		if err != nil {
+			return nil, err
+		}
+		comment = updated
+	}
+
+	action := ActionQueue
+	if state == models.CommentHidden {
+		action = ActionHide
+	}
+	// The comment is already stored and moderated; a failure to queue it is only logged.
+	if _, err := m.Queue.Enqueue(ctx, models.ModerationItem{
+		Kind:      KindComment,
+		ContentID: strconv.FormatInt(comment.ID, 10),
+		ImageID:   comment.TargetID,
+		AuthorID:  comment.AuthorID,
+		Text:      comment.Content,
+		Action:    action.String(),
+		Reasons:   screening.Reasons,
+	}); err != nil {
+		log.Printf("Error queueing comment %d for review: %v", comment.ID, err)
+	}
+	return comment, nil
+}
+
+// ScreenMetadata screens the changed title and description of image metadata and returns the
+// metadata to store, in which the text the pipeline objected to is held back: it keeps its
+// current value, or is left out, and is queued for review. ErrRateLimited refuses the change.
+func (m *Moderator) ScreenMetadata(ctx context.Context, imageID, authorID string, current, proposed map[string]string) (map[string]string, error) {
+	screened := make(map[string]string, len(proposed))
+	for key, value := range proposed {
+		screened[key] = value
+	}
+
+	var held []models.ModerationItem
+	for _, field := range metadataFields {
+		text, ok := proposed[field.Key]
+		if !ok || text == "" || text == current[field.Key] {
+			continue
+		}
+		verdict := m.Pipeline.Screen(ctx, Content{Kind: field.Kind, AuthorID: authorID, ImageID: imageID, Text: text})
+		switch verdict.Action {
+		case ActionAllow:
+			continue
+		case ActionReject:
+			return nil, fmt.Errorf("%w: %s", ErrRateLimited, strings.Join(verdict.Reasons, ", "))
+		}
+		if previous, ok := current[field.Key]; ok {
+			screened[field.Key] = previous
+		} else {
+			delete(screened, field.Key)
+		}
+		held = append(held, models.ModerationItem{
+			Kind:      field.Kind,
+			ContentID: imageID,
+			ImageID:   imageID,
+			AuthorID:  authorID,
+			Text:      text,
+			Action:    ActionHide.String(),
+			Reasons:   verdict.Reasons,
+		})
+	}
+	// Held text is only queued once nothing refused the change.
+	for _, item := range held {
+		if _, err := m.Queue.Enqueue(ctx, item); err != nil {
+			return nil, err
+		}
+	}
+	return screened, nil
+}
+
+// Report records a user report of content. The caller has checked that the reporter can see
+// it. Once ReportThreshold users reported a comment, it is hidden until reviewed.
+func (m *Moderator) Report(ctx context.Context, item models.ModerationItem, reporterID, reason string) (*models.ModerationItem, error) {
+	reported, err := m.Queue.Report(ctx, item, reporterID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if reported.Kind != KindComment || m.ReportThreshold <= 0 || reported.ReportCount < m.ReportThreshold ||
+		reported.Action == ActionHide.String() {
+		return reported, nil
+	}
+	commentID, err := strconv.ParseInt(reported.ContentID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment ID %q: %w", reported.ContentID, err)
+	}
+	if _, err := m.Comments.SetCommentState(ctx, commentID, models.CommentHidden); err != nil {
+		return nil, err
+	}
+	if err := m.Queue.SetAction(ctx, reported.ID, ActionHide.String()); err != nil {
+		return nil, err
+	}
+	reported.Action = ActionHide.String()
+	return reported, nil
+}
+
+// Review resolves a pending item and carries out the decision. Approved comments become
+// visible and rejected ones hidden. Approved titles and descriptions that were held back are
+// applied; rejected ones that were shown are removed, unless the owner changed them since.
+func (m *Moderator) Review(ctx context.Context, itemID int64, reviewerID string, approve bool, note string) (*models.ModerationItem, error) {
+	status := models.ModerationRejected
+	if approve {
+		status = models.ModerationApproved
+	}
+	// Resolving first makes sure that concurrent reviews of an item act only once.
+	item, err := m.Queue.Resolve(ctx, itemID, status, reviewerID, note)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Kind == KindComment {
+		commentID, err := strconv.ParseInt(item.ContentID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid comment ID %q: %w", item.ContentID, err)
+		}
+		state := models.CommentHidden
+		if approve {
+			state = models.CommentVisible
+		}
+		if _, err := m.Comments.SetCommentState(ctx, commentID, state); err != nil && !errors.Is(err, UserEventManagers.ErrCommentNotFound) {
+			return nil, err
+		}
+		return item, nil
+	}
+
+	key, ok := metadataKey(item.Kind)
+	if !ok {
+		return item, nil
+	}
+	held := item.Action == ActionHide.String()
+	if approve != held {
+		// Approving text that is shown, or rejecting text that was held back, changes nothing.
+		return item, nil
+	}
+	meta, err := m.ImageMetadataManager.GetImageMetadata(item.ImageID)
+	if errors.Is(err, Metadata.ErrMetadataNotFound) {
+		return item, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if approve {
+		meta[key] = item.Text
+		Mentions.MergeHashtagTags(meta)
+	} else {
+		if meta[key] != item.Text {
+			return item, nil
+		}
+		delete(meta, key)
+	}
+	change := Metadata.MetadataChange{ActorID: reviewerID, Source: Metadata.SourceModeration}
+	if err := m.ImageMetadataManager.SetImageMetadata(item.ImageID, meta, change); err != nil {
+		return nil, err
+	}
+	if m.OnMetadataChanged != nil {
+		m.OnMetadataChanged(item.ImageID, reviewerID)
+	}
+	return item, nil
+}
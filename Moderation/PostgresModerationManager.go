@@ -0,0 +1,296 @@
+package Moderation
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Erasure drops the pending items of the subject, whose content is erased anyway, and keeps the
+// reviewed ones and the reports of the subject as a record without their text, under the
+// pseudonym.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "moderation_pending", Table: "moderation_items",
+			Query: `DELETE FROM moderation_items WHERE author_id = $1 AND status = 'pending'`, Args: Erasure.BySubject},
+		Erasure.Step{Name: "moderation_items", Table: "moderation_items",
+			Query: `UPDATE moderation_items SET author_id = $2, text = '', updated_at = NOW() WHERE author_id = $1`,
+			Args:  Erasure.ByPseudonym},
+		Erasure.Step{Name: "moderation_reviews", Table: "moderation_items",
+			Query: `UPDATE moderation_items SET reviewer_id = $2 WHERE reviewer_id = $1`, Args: Erasure.ByPseudonym},
+		Erasure.Step{Name: "moderation_reports", Table: "moderation_reports",
+			Query: `UPDATE moderation_reports SET reporter_id = $2, reason = '' WHERE reporter_id = $1`,
+			Args:  Erasure.ByPseudonym},
+	)
+}
+
+// itemColumns lists the stored columns of a moderation item in scan order.
+const itemColumns = `id, kind, content_id, image_id, author_id, text, action, reasons, report_count, status,
+	reviewer_id, review_note, reviewed_at, created_at, updated_at`
+
+// upsertItem files content for review, or merges into its pending item.
+const upsertItem = `
+	INSERT INTO moderation_items (kind, content_id, image_id, author_id, text, action, reasons, status)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending')
+	ON CONFLICT (kind, content_id) WHERE status = 'pending' DO UPDATE SET
+		text = EXCLUDED.text,
+		action = CASE WHEN moderation_items.action = 'hidden' THEN 'hidden' ELSE EXCLUDED.action END,
+		reasons = ARRAY(SELECT DISTINCT unnest(moderation_items.reasons || EXCLUDED.reasons)),
+		updated_at = NOW()
+	RETURNING ` + itemColumns
+
+// PostgresModerationManager keeps the review queue and user reports in PostgreSQL.
+type PostgresModerationManager struct {
+	DB *sql.DB
+}
+
+// Initialize connects to the database (if needed) and ensures the moderation tables exist.
+func (p *PostgresModerationManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS moderation_items (
+		id BIGSERIAL PRIMARY KEY,
+		kind VARCHAR(30) NOT NULL,
+		content_id TEXT NOT NULL,
+		image_id TEXT NOT NULL,
+		author_id VARCHAR(100) NOT NULL,
+		text TEXT NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		reasons TEXT[] NOT NULL,
+		report_count INT NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL,
+		reviewer_id VARCHAR(100) NOT NULL DEFAULT '',
+		review_note TEXT NOT NULL DEFAULT '',
+		reviewed_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS moderation_items_pending_idx ON moderation_items (kind, content_id) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS moderation_items_queue_idx ON moderation_items (status, created_at, id);
+	CREATE TABLE IF NOT EXISTS moderation_reports (
+		item_id BIGINT NOT NULL REFERENCES moderation_items(id) ON DELETE CASCADE,
+		reporter_id VARCHAR(100) NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (item_id, reporter_id)
+	);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating moderation tables: %w", err)
+	}
+	return nil
+}
+
+// Enqueue files content for review, or updates its pending item.
+func (p *PostgresModerationManager) Enqueue(ctx context.Context, item models.ModerationItem) (*models.ModerationItem, error) {
+	stored, err := scanItem(p.DB.QueryRowContext(ctx, upsertItem, item.Kind, item.ContentID, item.ImageID,
+		item.AuthorID, item.Text, item.Action, pq.Array(item.Reasons)))
+	if err != nil {
+		return nil, fmt.Errorf("error queueing %s for review: %w", item.Kind, err)
+	}
+	return stored, nil
+}
+
+// Report records a report and recounts the distinct reporters of the pending item.
+func (p *PostgresModerationManager) Report(ctx context.Context, item models.ModerationItem, reporterID, reason string) (*models.ModerationItem, error) {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored, err := scanItem(tx.QueryRowContext(ctx, upsertItem, item.Kind, item.ContentID, item.ImageID,
+		item.AuthorID, item.Text, ActionQueue.String(), pq.Array([]string{"reported"})))
+	if err != nil {
+		return nil, fmt.Errorf("error queueing reported %s: %w", item.Kind, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO moderation_reports (item_id, reporter_id, reason) VALUES ($1, $2, $3)
+		ON CONFLICT (item_id, reporter_id) DO NOTHING
+	`, stored.ID, reporterID, reason); err != nil {
+		return nil, fmt.Errorf("error recording report: %w", err)
+	}
+	stored, err = scanItem(tx.QueryRowContext(ctx, `
+		UPDATE moderation_items
+		SET report_count = (SELECT COUNT(*) FROM moderation_reports WHERE item_id = $1)
+		WHERE id = $1
+		RETURNING `+itemColumns, stored.ID))
+	if err != nil {
+		return nil, fmt.Errorf("error counting reports: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// SetAction records what moderation did with the content of a pending item.
+func (p *PostgresModerationManager) SetAction(ctx context.Context, itemID int64, action string) error {
+	_, err := p.DB.ExecContext(ctx, `
+		UPDATE moderation_items SET action = $2, updated_at = NOW() WHERE id = $1 AND status = 'pending'
+	`, itemID, action)
+	if err != nil {
+		return fmt.Errorf("error updating moderation item: %w", err)
+	}
+	return nil
+}
+
+// GetItem returns a moderation item.
+func (p *PostgresModerationManager) GetItem(ctx context.Context, itemID int64) (*models.ModerationItem, error) {
+	item, err := scanItem(p.DB.QueryRowContext(ctx, `SELECT `+itemColumns+` FROM moderation_items WHERE id = $1`, itemID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching moderation item: %w", err)
+	}
+	return item, nil
+}
+
+// ListQueue returns a page of the review queue, oldest first.
+func (p *PostgresModerationManager) ListQueue(ctx context.Context, query QueueQuery) (*models.ModerationQueuePage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidModerationQuery, MaxPageSize)
+	}
+	status := query.Status
+	if status == "" {
+		status = models.ModerationPending
+	}
+	switch status {
+	case models.ModerationPending, models.ModerationApproved, models.ModerationRejected:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidModerationQuery, status)
+	}
+	conditions := []string{"status = $1"}
+	args := []interface{}{status}
+	if query.Kind != "" {
+		switch query.Kind {
+		case KindComment, KindImageTitle, KindImageDescription:
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %s", ErrInvalidModerationQuery, query.Kind)
+		}
+		args = append(args, query.Kind)
+		conditions = append(conditions, fmt.Sprintf("kind = $%d", len(args)))
+	}
+	if query.Cursor != "" {
+		createdAt, id, err := decodeQueueCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit+1)
+
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT `+itemColumns+` FROM moderation_items
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY created_at, id
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying moderation queue: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.ModerationQueuePage{Items: []models.ModerationItem{}}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning moderation item: %w", err)
+		}
+		page.Items = append(page.Items, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// One row more than the limit was fetched to know whether another page follows.
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeQueueCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// Resolve records the review of a pending item.
+func (p *PostgresModerationManager) Resolve(ctx context.Context, itemID int64, status, reviewerID, note string) (*models.ModerationItem, error) {
+	item, err := scanItem(p.DB.QueryRowContext(ctx, `
+		UPDATE moderation_items
+		SET status = $2, reviewer_id = $3, review_note = $4, reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING `+itemColumns, itemID, status, reviewerID, note))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := p.GetItem(ctx, itemID); err != nil {
+			return nil, err
+		}
+		return nil, ErrAlreadyReviewed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error resolving moderation item: %w", err)
+	}
+	return item, nil
+}
+
+func scanItem(row interface{ Scan(...interface{}) error }) (*models.ModerationItem, error) {
+	var item models.ModerationItem
+	err := row.Scan(&item.ID, &item.Kind, &item.ContentID, &item.ImageID, &item.AuthorID, &item.Text,
+		&item.Action, pq.Array(&item.Reasons), &item.ReportCount, &item.Status, &item.ReviewerID,
+		&item.ReviewNote, &item.ReviewedAt, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// encodeQueueCursor and decodeQueueCursor turn the position of the last item of a page into
+// an opaque cursor and back.
+func encodeQueueCursor(createdAt time.Time, id int64) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeQueueCursor(cursor string) (time.Time, int64, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidModerationQuery)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	itemID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, unixNano).UTC(), itemID, nil
+}
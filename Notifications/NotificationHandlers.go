@@ -0,0 +1,156 @@
+package Notifications
+
+import (
+	"GOLA/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// NotificationHandlers exposes the notifications of the caller over HTTP. Every handler
+// expects the JWT middleware to have put the caller's client ID into the request context.
+type NotificationHandlers struct {
+	Notifications NotificationManager
+}
+
+// markReadInput selects the notifications to mark as read; no IDs mark all of them.
+type markReadInput struct {
+	IDs []int64 `json:"ids"`
+}
+
+// HandleListNotifications returns a page of the caller's notifications. ?unread=true limits it
+// to unread ones, ?cursor= continues from a previous page and ?limit= sets the page size.
+func (h *NotificationHandlers) HandleListNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	query := Query{Cursor: params.Get("cursor")}
+	if value := params.Get("unread"); value != "" {
+		if query.UnreadOnly, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Invalid unread", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.Notifications.ListNotifications(r.Context(), clientID, query)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandleMarkRead marks the notifications in {"ids": [...]} as read, or all of them when the
+// body is empty, and answers {"updated": n}.
+func (h *NotificationHandlers) HandleMarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+	var input markReadInput
+	if len(bodyBytes) > 0 {
+		if err := json.Unmarshal(bodyBytes, &input); err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updated, err := h.Notifications.MarkRead(r.Context(), clientID, input.IDs)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int{"updated": updated})
+}
+
+// HandleUnreadCount answers {"unread": n} for the caller.
+func (h *NotificationHandlers) HandleUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	unread, err := h.Notifications.UnreadCount(r.Context(), clientID)
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int{"unread": unread})
+}
+
+// HandlePreferences returns (GET) or changes (PUT, e.g. {"like": false}) which kinds of
+// notifications the caller receives.
+func (h *NotificationHandlers) HandlePreferences(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	var preferences map[string]bool
+	switch r.Method {
+	case http.MethodGet:
+		preferences, err = h.Notifications.GetPreferences(r.Context(), clientID)
+	case http.MethodPut:
+		bodyBytes, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		var input map[string]bool
+		if err := json.Unmarshal(bodyBytes, &input); err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		preferences, err = h.Notifications.SetPreferences(r.Context(), clientID, input)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeNotificationError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, preferences)
+}
+
+// writeNotificationError maps notification manager errors onto HTTP status codes.
+func writeNotificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidNotificationQuery), errors.Is(err, ErrUnknownKind):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Notification operation failed: %v", err)
+		http.Error(w, "Notification operation failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,88 @@
+package Notifications
+
+import (
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Kinds of notifications.
+const (
+	KindLike    = "like"
+	KindComment = "comment"
+	KindMention = "mention"
+	// KindShare is sent when someone opens a share link of the recipient's image.
+	KindShare = "share"
+)
+
+// Kinds lists every kind of notification; preferences may turn each of them off.
+var Kinds = []string{KindLike, KindComment, KindMention, KindShare}
+
+// Page sizes of notification listings.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	// ErrInvalidNotificationQuery is returned for malformed cursors and oversized pages.
+	ErrInvalidNotificationQuery = errors.New("invalid notification query")
+	// ErrUnknownKind is returned for preferences of kinds that do not exist.
+	ErrUnknownKind = errors.New("unknown notification kind")
+)
+
+// Event is something that happened to a recipient: ActorID did Kind on the image TargetID.
+type Event struct {
+	Kind        string
+	RecipientID string
+	ActorID     string
+	TargetID    string
+	// Excerpt is the start of the comment, for comments and mentions.
+	Excerpt string
+}
+
+// Query selects a page of the notifications of a recipient, most recently updated first.
+type Query struct {
+	UnreadOnly bool
+	// Cursor is the NextCursor of the previous page; empty for the first page.
+	Cursor string
+	Limit  int
+}
+
+// NotificationManager stores notifications per recipient.
+type NotificationManager interface {
+	Initialize() error
+	// Notify records an event for its recipient, merging it into the recipient's unread
+	// notification of the same kind and image. Nothing is recorded when actors act on their
+	// own images or the recipient turned the kind off.
+	Notify(ctx context.Context, event Event) error
+	ListNotifications(ctx context.Context, recipientID string, query Query) (*models.NotificationPage, error)
+	// MarkRead marks notifications of the recipient as read, all unread ones when ids is
+	// empty, and returns how many changed.
+	MarkRead(ctx context.Context, recipientID string, ids []int64) (int, error)
+	UnreadCount(ctx context.Context, recipientID string) (int, error)
+	// GetPreferences returns whether the user receives each kind of notification.
+	GetPreferences(ctx context.Context, userID string) (map[string]bool, error)
+	// SetPreferences changes the given kinds and returns the resulting preferences.
+	SetPreferences(ctx context.Context, userID string, preferences map[string]bool) (map[string]bool, error)
+}
+
+// GetNotificationManager returns an instance of the requested notification manager.
+func GetNotificationManager(storageType string) (NotificationManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresNotificationManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification storage type: %s", storageType)
+	}
+}
+
+func isKind(kind string) bool {
+	for _, known := range Kinds {
+		if kind == known {
+			return true
+		}
+	}
+	return false
+}
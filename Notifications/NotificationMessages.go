@@ -0,0 +1,44 @@
+package Notifications
+
+import (
+	"GOLA/commons/models"
+	"fmt"
+)
+
+// Message renders a notification as text, e.g. "alice and bob liked your photo".
+func Message(n models.Notification) string {
+	if n.Kind == KindShare {
+		// Share link viewers are anonymous.
+		if n.ActorCount > 1 {
+			return fmt.Sprintf("%d people opened your share link", n.ActorCount)
+		}
+		return "Someone opened your share link"
+	}
+
+	var action string
+	switch n.Kind {
+	case KindLike:
+		action = "liked your photo"
+	case KindComment:
+		action = "commented on your photo"
+	case KindMention:
+		action = "mentioned you in a comment"
+	default:
+		action = n.Kind
+	}
+	return actorNames(n) + " " + action
+}
+
+// actorNames names up to two actors and counts the rest.
+func actorNames(n models.Notification) string {
+	switch {
+	case len(n.Actors) == 0:
+		return "Someone"
+	case n.ActorCount <= 1:
+		return n.Actors[0]
+	case n.ActorCount == 2 && len(n.Actors) >= 2:
+		return n.Actors[0] + " and " + n.Actors[1]
+	default:
+		return fmt.Sprintf("%d people", n.ActorCount)
+	}
+}
@@ -0,0 +1,291 @@
+package Notifications
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Erasure deletes the notifications and preferences of the subject. In the notifications of
+// others the subject's ID gives way to the pseudonym, and the excerpt is dropped since it may
+// quote the subject's comment.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "notifications", Table: "notifications",
+			Query: `DELETE FROM notifications WHERE recipient_id = $1`, Args: Erasure.BySubject},
+		Erasure.Step{Name: "notification_actors", Table: "notifications",
+			Query: `UPDATE notifications SET actor_ids = array_replace(actor_ids, $1, $2), excerpt = ''
+			WHERE $1 = ANY(actor_ids)`, Args: Erasure.ByPseudonym},
+		Erasure.Step{Name: "notification_preferences", Table: "notification_preferences",
+			Query: `DELETE FROM notification_preferences WHERE user_id = $1`, Args: Erasure.BySubject},
+	)
+}
+
+// maxActors is the number of actors kept per notification. Actors that dropped off the list
+// count again when they act again, so ActorCount of very busy notifications is approximate.
+const maxActors = 50
+
+// maxExcerptLength is the length of comment excerpts, in characters.
+const maxExcerptLength = 140
+
+// PostgresNotificationManager keeps notifications and preferences in PostgreSQL. A partial
+// unique index allows one unread notification per recipient, kind and image, into which new
+// events are merged.
+type PostgresNotificationManager struct {
+	DB *sql.DB
+}
+
+// Initialize connects to the database (if needed) and ensures the notification tables exist.
+func (p *PostgresNotificationManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id BIGSERIAL PRIMARY KEY,
+		recipient_id VARCHAR(100) NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		target_id VARCHAR(100) NOT NULL,
+		actor_ids TEXT[] NOT NULL,
+		actor_count INT NOT NULL,
+		excerpt TEXT NOT NULL DEFAULT '',
+		read_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (recipient_id, kind, target_id) WHERE read_at IS NULL;
+	CREATE INDEX IF NOT EXISTS notifications_recipient_idx ON notifications (recipient_id, updated_at, id);
+	CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id VARCHAR(100) NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (user_id, kind)
+	);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating notification tables: %w", err)
+	}
+	return nil
+}
+
+// Notify records an event for its recipient. An unread notification of the same kind and
+// image gains the actor, unless the actor is already on it, and moves to the top again.
+func (p *PostgresNotificationManager) Notify(ctx context.Context, event Event) error {
+	if !isKind(event.Kind) {
+		return fmt.Errorf("%w: %s", ErrUnknownKind, event.Kind)
+	}
+	if event.RecipientID == "" || event.ActorID == event.RecipientID {
+		return nil
+	}
+	_, err := p.DB.ExecContext(ctx, `
+		INSERT INTO notifications (recipient_id, kind, target_id, actor_ids, actor_count, excerpt)
+		SELECT $1, $2, $3, ARRAY[$4::text], 1, $5
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notification_preferences WHERE user_id = $1 AND kind = $2 AND NOT enabled
+		)
+		ON CONFLICT (recipient_id, kind, target_id) WHERE read_at IS NULL DO UPDATE SET
+			actor_ids = CASE WHEN $4 = ANY(notifications.actor_ids) THEN notifications.actor_ids
+				ELSE (ARRAY[$4::text] || notifications.actor_ids)[1:$6] END,
+			actor_count = notifications.actor_count + CASE WHEN $4 = ANY(notifications.actor_ids) THEN 0 ELSE 1 END,
+			excerpt = COALESCE(NULLIF(EXCLUDED.excerpt, ''), notifications.excerpt),
+			updated_at = NOW()
+	`, event.RecipientID, event.Kind, event.TargetID, event.ActorID, excerpt(event.Excerpt), maxActors)
+	if err != nil {
+		return fmt.Errorf("error recording %s notification: %w", event.Kind, err)
+	}
+	return nil
+}
+
+// excerpt shortens a comment to maxExcerptLength characters.
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= maxExcerptLength {
+		return text
+	}
+	return string([]rune(text)[:maxExcerptLength-1]) + "…"
+}
+
+// ListNotifications returns a page of the notifications of the recipient, most recently
+// updated first.
+func (p *PostgresNotificationManager) ListNotifications(ctx context.Context, recipientID string, query Query) (*models.NotificationPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidNotificationQuery, MaxPageSize)
+	}
+	conditions := []string{"recipient_id = $1"}
+	args := []interface{}{recipientID}
+	if query.UnreadOnly {
+		conditions = append(conditions, "read_at IS NULL")
+	}
+	if query.Cursor != "" {
+		updatedAt, id, err := decodeNotificationCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, updatedAt, id)
+		conditions = append(conditions, fmt.Sprintf("(updated_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit+1)
+
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT id, kind, target_id, actor_ids, actor_count, excerpt, read_at IS NOT NULL, created_at, updated_at
+		FROM notifications
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notifications: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.NotificationPage{Notifications: []models.Notification{}}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.TargetID, pq.Array(&n.Actors), &n.ActorCount, &n.Excerpt,
+			&n.Read, &n.CreatedAt, &n.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning notification: %w", err)
+		}
+		n.Message = Message(n)
+		page.Notifications = append(page.Notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// One row more than the limit was fetched to know whether another page follows.
+	if len(page.Notifications) > limit {
+		page.Notifications = page.Notifications[:limit]
+		last := page.Notifications[limit-1]
+		page.NextCursor = encodeNotificationCursor(last.UpdatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// MarkRead marks notifications of the recipient as read; an empty ids marks all of them.
+func (p *PostgresNotificationManager) MarkRead(ctx context.Context, recipientID string, ids []int64) (int, error) {
+	query := `UPDATE notifications SET read_at = NOW() WHERE recipient_id = $1 AND read_at IS NULL`
+	args := []interface{}{recipientID}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($2)`
+		args = append(args, pq.Array(ids))
+	}
+	result, err := p.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error marking notifications read: %w", err)
+	}
+	updated, _ := result.RowsAffected()
+	return int(updated), nil
+}
+
+// UnreadCount returns the number of unread notifications of the recipient.
+func (p *PostgresNotificationManager) UnreadCount(ctx context.Context, recipientID string) (int, error) {
+	var count int
+	err := p.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM notifications WHERE recipient_id = $1 AND read_at IS NULL
+	`, recipientID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// GetPreferences returns whether the user receives each kind of notification; every kind is
+// on until turned off.
+func (p *PostgresNotificationManager) GetPreferences(ctx context.Context, userID string) (map[string]bool, error) {
+	preferences := map[string]bool{}
+	for _, kind := range Kinds {
+		preferences[kind] = true
+	}
+	rows, err := p.DB.QueryContext(ctx, `SELECT kind, enabled FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification preferences: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, fmt.Errorf("error scanning notification preference: %w", err)
+		}
+		if isKind(kind) {
+			preferences[kind] = enabled
+		}
+	}
+	return preferences, rows.Err()
+}
+
+// SetPreferences changes the given kinds and returns the resulting preferences.
+func (p *PostgresNotificationManager) SetPreferences(ctx context.Context, userID string, preferences map[string]bool) (map[string]bool, error) {
+	for kind := range preferences {
+		if !isKind(kind) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+		}
+	}
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for kind, enabled := range preferences {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO notification_preferences (user_id, kind, enabled) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, kind) DO UPDATE SET enabled = EXCLUDED.enabled
+		`, userID, kind, enabled)
+		if err != nil {
+			return nil, fmt.Errorf("error saving notification preference: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p.GetPreferences(ctx, userID)
+}
+
+// encodeNotificationCursor and decodeNotificationCursor turn the position of the last
+// notification of a page into an opaque cursor and back.
+func encodeNotificationCursor(updatedAt time.Time, id int64) string {
+	raw := strconv.FormatInt(updatedAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeNotificationCursor(cursor string) (time.Time, int64, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidNotificationQuery)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	notificationID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, unixNano).UTC(), notificationID, nil
+}
@@ -0,0 +1,204 @@
+package SearchIndexers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// imageIndexMapping is applied when the index is created so that text fields are analysed
+// and tags/IDs can be filtered on exactly.
+const imageIndexMapping = `{
+	"mappings": {
+		"properties": {
+			"image_id":    {"type": "keyword"},
+			"title":       {"type": "text"},
+			"description": {"type": "text"},
+			"tags":        {"type": "keyword"},
+			"owner_id":    {"type": "keyword"},
+			"is_private":  {"type": "boolean"},
+			"likes":       {"type": "integer"},
+			"dislikes":    {"type": "integer"},
+			"views":       {"type": "integer"},
+			"comments":    {"type": "integer"},
+			"indexed_at":  {"type": "date"}
+		}
+	}
+}`
+
+// ElasticsearchIndexer talks to an Elasticsearch or OpenSearch cluster over its REST API.
+type ElasticsearchIndexer struct {
+	BaseURL    string
+	IndexName  string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// bulkResponse is the subset of the _bulk response needed to detect per-item failures.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error,omitempty"`
+	} `json:"items"`
+}
+
+// Initialize creates the index with its mapping if it does not exist yet.
+func (e *ElasticsearchIndexer) Initialize() error {
+	resp, err := e.do(http.MethodHead, "/"+e.IndexName, "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to check search index: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status checking search index: %d", resp.StatusCode)
+	}
+
+	resp, err = e.do(http.MethodPut, "/"+e.IndexName, "application/json", strings.NewReader(imageIndexMapping))
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	log.Printf("Search index %s created", e.IndexName)
+	return nil
+}
+
+// IndexImage creates or replaces the document of a single image.
+func (e *ElasticsearchIndexer) IndexImage(doc ImageDocument) error {
+	return e.BulkIndex([]ImageDocument{doc})
+}
+
+// DeleteImage removes the document of a single image.
+func (e *ElasticsearchIndexer) DeleteImage(imageID string) error {
+	var body bytes.Buffer
+	if err := writeBulkAction(&body, "delete", e.IndexName, imageID); err != nil {
+		return err
+	}
+	return e.bulk(&body)
+}
+
+// BulkIndex creates or replaces many documents using the _bulk API.
+func (e *ElasticsearchIndexer) BulkIndex(docs []ImageDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	for _, doc := range docs {
+		if err := writeBulkAction(&body, "index", e.IndexName, doc.ImageID); err != nil {
+			return err
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document %s: %w", doc.ImageID, err)
+		}
+		body.Write(source)
+		body.WriteByte('\n')
+	}
+	return e.bulk(&body)
+}
+
+// DeleteIndexedBefore removes documents that were not refreshed since the given time.
+func (e *ElasticsearchIndexer) DeleteIndexedBefore(cutoff time.Time) error {
+	query, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"range": map[string]interface{}{
+				"indexed_at": map[string]string{"lt": cutoff.UTC().Format(time.RFC3339Nano)},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := e.do(http.MethodPost, "/"+e.IndexName+"/_delete_by_query?refresh=true", "application/json", bytes.NewReader(query))
+	if err != nil {
+		return fmt.Errorf("failed to delete stale documents: %w", err)
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+// bulk sends an NDJSON body to the _bulk endpoint and reports item level failures.
+func (e *ElasticsearchIndexer) bulk(body io.Reader) error {
+	resp, err := e.do(http.MethodPost, "/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		return fmt.Errorf("bulk request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return fmt.Errorf("bulk request failed: %w", err)
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for action, status := range item {
+			// A delete of a document that was never indexed is not a failure.
+			if action == "delete" && status.Status == http.StatusNotFound {
+				continue
+			}
+			if status.Status >= 300 {
+				return fmt.Errorf("bulk %s of %s failed with status %d: %s", action, status.ID, status.Status, string(status.Error))
+			}
+		}
+	}
+	return nil
+}
+
+// do executes a request against the cluster, adding basic auth when configured.
+func (e *ElasticsearchIndexer) do(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(e.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if e.Username != "" {
+		req.SetBasicAuth(e.Username, e.Password)
+	}
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// writeBulkAction writes a single action line of a _bulk request.
+func writeBulkAction(w *bytes.Buffer, action, index, id string) error {
+	line, err := json.Marshal(map[string]map[string]string{
+		action: {"_index": index, "_id": id},
+	})
+	if err != nil {
+		return err
+	}
+	w.Write(line)
+	w.WriteByte('\n')
+	return nil
+}
+
+// checkStatus turns a non-2xx response into an error containing the response body.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
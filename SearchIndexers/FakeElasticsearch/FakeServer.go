@@ -0,0 +1,203 @@
+// Package FakeElasticsearch provides an in-process HTTP server implementing the small subset of
+// the Elasticsearch REST API used by the search indexer, so indexing can be exercised in tests
+// and local runs without a live cluster.
+package FakeElasticsearch
+
+import (
+	"GOLA/utils"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake Elasticsearch cluster backed by in-memory maps.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	indices map[string]map[string]json.RawMessage
+}
+
+// NewServer starts a fake cluster. Callers must Close it when done.
+func NewServer() *Server {
+	s := &Server{indices: make(map[string]map[string]json.RawMessage)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.route))
+	return s
+}
+
+// Document returns the stored source of a document and whether it exists.
+func (s *Server) Document(index, id string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.indices[index][id]
+	return doc, ok
+}
+
+// Count returns the number of documents in an index.
+func (s *Server) Count(index string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.indices[index])
+}
+
+// IndexExists reports whether an index has been created.
+func (s *Server) IndexExists(index string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.indices[index]
+	return ok
+}
+
+// route dispatches a request based on its method and path segments.
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "_bulk" && r.Method == http.MethodPost:
+		s.handleBulk(w, r)
+	case len(parts) == 1 && r.Method == http.MethodHead:
+		if s.IndexExists(parts[0]) {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		s.handleCreateIndex(w, parts[0])
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.indices, parts[0])
+		s.mu.Unlock()
+		utils.WriteJSON(w, http.StatusOK, map[string]bool{"acknowledged": true})
+	case len(parts) == 2 && parts[1] == "_count":
+		utils.WriteJSON(w, http.StatusOK, map[string]int{"count": s.Count(parts[0])})
+	case len(parts) == 2 && parts[1] == "_refresh":
+		utils.WriteJSON(w, http.StatusOK, map[string]interface{}{})
+	case len(parts) == 2 && parts[1] == "_delete_by_query" && r.Method == http.MethodPost:
+		s.handleDeleteByQuery(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodGet:
+		doc, ok := s.Document(parts[0], parts[2])
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusNotFound
+		}
+		utils.WriteJSON(w, status, map[string]interface{}{"_index": parts[0], "_id": parts[2], "found": ok, "_source": doc})
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported request %s %s", r.Method, r.URL.Path)})
+	}
+}
+
+func (s *Server) handleCreateIndex(w http.ResponseWriter, index string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.indices[index]; ok {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]string{"type": "resource_already_exists_exception"},
+		})
+		return
+	}
+	s.indices[index] = make(map[string]json.RawMessage)
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true, "index": index})
+}
+
+// handleBulk applies index and delete actions from an NDJSON body.
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10<<20)
+
+	var items []map[string]interface{}
+	hasErrors := false
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &action); err != nil || len(action) != 1 {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "malformed action line"})
+			return
+		}
+		for name, meta := range action {
+			status := http.StatusOK
+			switch name {
+			case "index":
+				if !scanner.Scan() {
+					utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "missing document line"})
+					return
+				}
+				docs := s.indices[meta.Index]
+				if docs == nil {
+					// Elasticsearch auto-creates indices on first write.
+					docs = make(map[string]json.RawMessage)
+					s.indices[meta.Index] = docs
+				}
+				if _, exists := docs[meta.ID]; !exists {
+					status = http.StatusCreated
+				}
+				docs[meta.ID] = json.RawMessage(append([]byte(nil), scanner.Bytes()...))
+			case "delete":
+				if _, exists := s.indices[meta.Index][meta.ID]; exists {
+					delete(s.indices[meta.Index], meta.ID)
+				} else {
+					status = http.StatusNotFound
+					hasErrors = true
+				}
+			default:
+				utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported bulk action " + name})
+				return
+			}
+			items = append(items, map[string]interface{}{
+				name: map[string]interface{}{"_index": meta.Index, "_id": meta.ID, "status": status},
+			})
+		}
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"errors": hasErrors, "items": items})
+}
+
+// handleDeleteByQuery supports a single "range" query with an "lt" bound on a date field.
+func (s *Server) handleDeleteByQuery(w http.ResponseWriter, r *http.Request, index string) {
+	var body struct {
+		Query struct {
+			Range map[string]struct {
+				LT string `json:"lt"`
+			} `json:"range"`
+		} `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Query.Range) != 1 {
+		utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "only single range queries are supported"})
+		return
+	}
+
+	deleted := 0
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for field, bound := range body.Query.Range {
+		cutoff, err := time.Parse(time.RFC3339Nano, bound.LT)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "range bound must be an RFC3339 date"})
+			return
+		}
+		for id, raw := range s.indices[index] {
+			var doc map[string]interface{}
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				continue
+			}
+			value, _ := doc[field].(string)
+			ts, err := time.Parse(time.RFC3339Nano, value)
+			if err == nil && ts.Before(cutoff) {
+				delete(s.indices[index], id)
+				deleted++
+			}
+		}
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int{"deleted": deleted})
+}
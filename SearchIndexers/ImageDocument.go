@@ -0,0 +1,45 @@
+package SearchIndexers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImageDocument is the representation of an image stored in the search index.
+type ImageDocument struct {
+	ImageID     string    `json:"image_id"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	OwnerID     string    `json:"owner_id,omitempty"`
+	IsPrivate   bool      `json:"is_private"`
+	Likes       int       `json:"likes"`
+	Dislikes    int       `json:"dislikes"`
+	Views       int       `json:"views"`
+	Comments    int       `json:"comments"`
+	IndexedAt   time.Time `json:"indexed_at"`
+}
+
+// BuildImageDocument converts the metadata map of an image into a search document.
+func BuildImageDocument(imageID string, metadata map[string]string) ImageDocument {
+	doc := ImageDocument{
+		ImageID:     imageID,
+		Title:       metadata["title"],
+		Description: metadata["description"],
+		OwnerID:     metadata["owner_id"],
+		IsPrivate:   metadata["is_private"] == "true",
+		IndexedAt:   time.Now().UTC(),
+	}
+	// Tags are stored as a comma separated list in the metadata map.
+	for _, tag := range strings.Split(metadata["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			doc.Tags = append(doc.Tags, strings.ToLower(tag))
+		}
+	}
+	doc.Likes, _ = strconv.Atoi(metadata["likes"])
+	doc.Dislikes, _ = strconv.Atoi(metadata["dislikes"])
+	doc.Views, _ = strconv.Atoi(metadata["views"])
+	doc.Comments, _ = strconv.Atoi(metadata["comments"])
+	return doc
+}
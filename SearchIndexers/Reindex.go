@@ -0,0 +1,55 @@
+package SearchIndexers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"GOLA/ImageManagers/Metadata"
+)
+
+// FullReindex rebuilds the search index from the metadata store. Every image is re-indexed
+// in batches of batchSize, and documents that were not touched by this run (images that no
+// longer exist) are removed afterwards.
+func FullReindex(indexer SearchIndexer, metadataManager Metadata.ImageMetadataManager, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	started := time.Now().UTC()
+
+	all, err := metadataManager.ListImageMetadata()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list image metadata: %w", err)
+	}
+
+	// Index in a stable order so that a failed run can be compared with the logs.
+	imageIDs := make([]string, 0, len(all))
+	for imageID := range all {
+		imageIDs = append(imageIDs, imageID)
+	}
+	sort.Strings(imageIDs)
+
+	indexed := 0
+	batch := make([]ImageDocument, 0, batchSize)
+	for _, imageID := range imageIDs {
+		batch = append(batch, BuildImageDocument(imageID, all[imageID]))
+		if len(batch) == batchSize {
+			if err := indexer.BulkIndex(batch); err != nil {
+				return indexed, fmt.Errorf("failed to index batch: %w", err)
+			}
+			indexed += len(batch)
+			batch = batch[:0]
+			log.Printf("Reindexed %d/%d images", indexed, len(imageIDs))
+		}
+	}
+	if err := indexer.BulkIndex(batch); err != nil {
+		return indexed, fmt.Errorf("failed to index batch: %w", err)
+	}
+	indexed += len(batch)
+
+	if err := indexer.DeleteIndexedBefore(started); err != nil {
+		return indexed, fmt.Errorf("failed to remove stale documents: %w", err)
+	}
+	return indexed, nil
+}
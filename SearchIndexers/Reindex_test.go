@@ -0,0 +1,73 @@
+package SearchIndexers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/SearchIndexers/FakeElasticsearch"
+)
+
+// listMetadata is a metadata store that only lists a fixed set of images.
+type listMetadata struct {
+	Metadata.ImageMetadataManager
+	images map[string]map[string]string
+}
+
+func (m listMetadata) ListImageMetadata() (map[string]map[string]string, error) {
+	return m.images, nil
+}
+
+func newTestIndexer(t *testing.T, server *FakeElasticsearch.Server) *ElasticsearchIndexer {
+	t.Helper()
+	indexer := &ElasticsearchIndexer{BaseURL: server.URL, IndexName: "images", HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	if err := indexer.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return indexer
+}
+
+func TestFullReindexIndexesImagesAndRemovesStaleDocuments(t *testing.T) {
+	server := FakeElasticsearch.NewServer()
+	defer server.Close()
+	indexer := newTestIndexer(t, server)
+
+	stale := BuildImageDocument("gone.jpg", map[string]string{})
+	stale.IndexedAt = time.Now().UTC().Add(-time.Hour)
+	if err := indexer.IndexImage(stale); err != nil {
+		t.Fatalf("IndexImage: %v", err)
+	}
+
+	store := listMetadata{images: map[string]map[string]string{
+		"a.jpg": {"title": "Sunset", "tags": "Beach, sea", "owner_id": "alice"},
+		"b.jpg": {"title": "Forest", "is_private": "true", "owner_id": "bob"},
+		"c.jpg": {},
+	}}
+	indexed, err := FullReindex(indexer, store, 2)
+	if err != nil {
+		t.Fatalf("FullReindex: %v", err)
+	}
+	if indexed != 3 {
+		t.Errorf("indexed %d images, want 3", indexed)
+	}
+	if count := server.Count("images"); count != 3 {
+		t.Errorf("index holds %d documents, want 3", count)
+	}
+	if _, ok := server.Document("images", "gone.jpg"); ok {
+		t.Error("stale document survived the reindex")
+	}
+
+	raw, ok := server.Document("images", "a.jpg")
+	if !ok {
+		t.Fatal("a.jpg was not indexed")
+	}
+	var doc ImageDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("decoding document: %v", err)
+	}
+	if doc.Title != "Sunset" || len(doc.Tags) != 2 || doc.Tags[0] != "beach" || doc.OwnerID != "alice" {
+		t.Errorf("unexpected document %+v", doc)
+	}
+}
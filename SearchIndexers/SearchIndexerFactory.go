@@ -0,0 +1,48 @@
+package SearchIndexers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// SearchIndexer keeps a search index in sync with the image metadata store.
+type SearchIndexer interface {
+	Initialize() error
+	// IndexImage creates or replaces the document of a single image.
+	IndexImage(doc ImageDocument) error
+	// DeleteImage removes the document of a single image. Missing documents are not an error.
+	DeleteImage(imageID string) error
+	// BulkIndex creates or replaces many documents in a single request.
+	BulkIndex(docs []ImageDocument) error
+	// DeleteIndexedBefore removes every document whose IndexedAt is older than the given time.
+	DeleteIndexedBefore(cutoff time.Time) error
+}
+
+// GetSearchIndexer returns an instance of the requested search indexer.
+func GetSearchIndexer(indexerType string) (SearchIndexer, error) {
+	switch indexerType {
+	case "elasticsearch", "opensearch":
+		// Read configuration from environment variables.
+		baseURL := os.Getenv("SEARCH_URL")       // e.g. "http://localhost:9200"
+		indexName := os.Getenv("SEARCH_INDEX")   // e.g. "images"
+		username := os.Getenv("SEARCH_USERNAME") // optional basic auth
+		password := os.Getenv("SEARCH_PASSWORD") // optional basic auth
+		if baseURL == "" {
+			return nil, fmt.Errorf("SEARCH_URL is required for search indexer %s", indexerType)
+		}
+		if indexName == "" {
+			indexName = "images"
+		}
+		return &ElasticsearchIndexer{
+			BaseURL:    baseURL,
+			IndexName:  indexName,
+			Username:   username,
+			Password:   password,
+			HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported search indexer type: %s", indexerType)
+	}
+}
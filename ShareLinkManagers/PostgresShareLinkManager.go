@@ -0,0 +1,242 @@
+package ShareLinkManagers
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	"GOLA/commons"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Erasure deletes the links of the subject and every link to their deleted images.
+func init() {
+	Erasure.Register(Erasure.Step{Name: "share_links", Table: "share_links",
+		Query: `DELETE FROM share_links WHERE owner_id = $1 OR image_id = ANY($2)`, Args: Erasure.BySubjectAndImages})
+}
+
+// shareLinkColumns lists the stored columns of a share link in scan order.
+const shareLinkColumns = `id, image_id, owner_id, expires_at, max_views, view_count,
+	COALESCE(password_hash, ''), revoked_at, created_at`
+
+// PostgresShareLinkManager manages share links using PostgreSQL.
+type PostgresShareLinkManager struct {
+	DB     *sql.DB
+	Secret []byte
+}
+
+// Initialize connects to the database (if needed), loads the signing secret and ensures
+// the share_links table exists.
+func (p *PostgresShareLinkManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+
+	if len(p.Secret) == 0 {
+		p.Secret = []byte(os.Getenv("SHARE_LINK_SECRET"))
+	}
+	if len(p.Secret) == 0 {
+		// Without a configured secret links still work, but only until the next restart.
+		log.Println("SHARE_LINK_SECRET not set, share links will not survive a restart")
+		p.Secret = make([]byte, 32)
+		if _, err := rand.Read(p.Secret); err != nil {
+			return err
+		}
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS share_links (
+		id VARCHAR(64) PRIMARY KEY,
+		image_id TEXT NOT NULL,
+		owner_id VARCHAR(100) NOT NULL,
+		expires_at TIMESTAMP,
+		max_views INT,
+		view_count INT NOT NULL DEFAULT 0,
+		password_hash TEXT,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS share_links_image_idx ON share_links (image_id, owner_id);
+	`
+	_, err := p.DB.Exec(query)
+	return err
+}
+
+// CreateShareLink stores a new link and returns it together with its token.
+func (p *PostgresShareLinkManager) CreateShareLink(ownerID string, input *commons.ShareLinkInputModel) (*models.ShareLink, string, error) {
+	link := &models.ShareLink{
+		ImageID:   input.ImageID,
+		OwnerID:   ownerID,
+		MaxViews:  input.MaxViews,
+		CreatedAt: time.Now(),
+	}
+	if link.MaxViews != nil && *link.MaxViews <= 0 {
+		return nil, "", ErrInvalidShareLink
+	}
+	switch {
+	case input.ExpiresAt != nil:
+		if !input.ExpiresAt.After(link.CreatedAt) {
+			return nil, "", ErrInvalidShareLink
+		}
+		link.ExpiresAt = input.ExpiresAt
+	case input.ExpiresInSeconds < 0:
+		return nil, "", ErrInvalidShareLink
+	case input.ExpiresInSeconds > 0:
+		expiresAt := link.CreatedAt.Add(time.Duration(input.ExpiresInSeconds) * time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+	if input.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, "", fmt.Errorf("error hashing share link password: %w", err)
+		}
+		link.PasswordHash = string(hash)
+		link.HasPassword = true
+	}
+
+	id, err := newLinkID()
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating share link id: %w", err)
+	}
+	link.ID = id
+
+	query := `
+		INSERT INTO share_links (id, image_id, owner_id, expires_at, max_views, password_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+	`
+	if _, err := p.DB.Exec(query, link.ID, link.ImageID, link.OwnerID, link.ExpiresAt, link.MaxViews,
+		link.PasswordHash, link.CreatedAt); err != nil {
+		return nil, "", fmt.Errorf("error creating share link: %w", err)
+	}
+	return link, signLinkID(p.Secret, link.ID), nil
+}
+
+// ListShareLinks returns the links created by ownerID for an image, newest first.
+func (p *PostgresShareLinkManager) ListShareLinks(ownerID, imageID string) ([]models.ShareLink, error) {
+	rows, err := p.DB.Query(`SELECT `+shareLinkColumns+` FROM share_links
+		WHERE owner_id = $1 AND image_id = $2
+		ORDER BY created_at DESC`, ownerID, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying share links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.ShareLink{}
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning share link row: %w", err)
+		}
+		links = append(links, *link)
+	}
+	return links, rows.Err()
+}
+
+// RevokeShareLink marks a link of ownerID as revoked.
+func (p *PostgresShareLinkManager) RevokeShareLink(ownerID, linkID string) error {
+	result, err := p.DB.Exec(`UPDATE share_links SET revoked_at = NOW()
+		WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL`, linkID, ownerID)
+	if err != nil {
+		return fmt.Errorf("error revoking share link: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrShareLinkNotFound
+	}
+	return nil
+}
+
+// VerifyShareLink validates a token and password without consuming a view of the link.
+func (p *PostgresShareLinkManager) VerifyShareLink(token, password string) (*models.ShareLink, error) {
+	linkID, ok := verifyToken(p.Secret, token)
+	if !ok {
+		return nil, ErrShareLinkNotFound
+	}
+
+	link, err := scanShareLink(p.DB.QueryRow(`SELECT `+shareLinkColumns+` FROM share_links WHERE id = $1`, linkID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching share link: %w", err)
+	}
+	if link.RevokedAt != nil {
+		return nil, ErrShareLinkNotFound
+	}
+	if link.ExpiresAt != nil && !time.Now().Before(*link.ExpiresAt) {
+		return nil, ErrShareLinkExpired
+	}
+	if link.MaxViews != nil && link.ViewCount >= *link.MaxViews {
+		return nil, ErrShareLinkExhausted
+	}
+	if link.HasPassword {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			return nil, ErrSharePasswordInvalid
+		}
+	}
+	return link, nil
+}
+
+// ConsumeShareLinkView records one view of the link. The view is consumed atomically, so
+// concurrent requests cannot exceed max_views.
+func (p *PostgresShareLinkManager) ConsumeShareLinkView(link *models.ShareLink) error {
+	err := p.DB.QueryRow(`
+		UPDATE share_links SET view_count = view_count + 1
+		WHERE id = $1 AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (max_views IS NULL OR view_count < max_views)
+		RETURNING view_count
+	`, link.ID).Scan(&link.ViewCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrShareLinkExhausted
+	}
+	if err != nil {
+		return fmt.Errorf("error recording share link view: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShareLink(row rowScanner) (*models.ShareLink, error) {
+	var link models.ShareLink
+	var expiresAt, revokedAt sql.NullTime
+	var maxViews sql.NullInt64
+	if err := row.Scan(&link.ID, &link.ImageID, &link.OwnerID, &expiresAt, &maxViews, &link.ViewCount,
+		&link.PasswordHash, &revokedAt, &link.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		link.RevokedAt = &revokedAt.Time
+	}
+	if maxViews.Valid {
+		views := int(maxViews.Int64)
+		link.MaxViews = &views
+	}
+	link.HasPassword = link.PasswordHash != ""
+	return &link, nil
+}
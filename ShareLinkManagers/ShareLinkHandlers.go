@@ -0,0 +1,192 @@
+package ShareLinkManagers
+
+import (
+	"GOLA/Deserializers"
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/ImageManagers/RawStore"
+	"GOLA/UserEventManagers"
+	"GOLA/commons"
+	"GOLA/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ShareLinkPathPrefix is the path under which share links are served without authentication.
+const ShareLinkPathPrefix = "/s/"
+
+// ShareViewerPrefix starts the user IDs under which views through share links are recorded.
+const ShareViewerPrefix = "share:"
+
+// ShareLinkHandlers exposes share links over HTTP.
+type ShareLinkHandlers struct {
+	ShareLinks           ShareLinkManager
+	ImageMetadataManager Metadata.ImageMetadataManager
+	ImageStoreManager    RawStore.ImageStoreManager
+	EventManager         UserEventManagers.EventManager
+	// Publisher, when set, publishes the views instead, to be recorded later.
+	Publisher UserEventManagers.EventPublisher
+}
+
+// shareLinkResponse is returned when a link is created; the token is never shown again.
+type shareLinkResponse struct {
+	Link  interface{} `json:"link"`
+	Token string      `json:"token"`
+	Path  string      `json:"path"`
+}
+
+// HandleShareLinks creates (POST), lists (GET ?image_id=) or revokes (DELETE ?id=) the share
+// links of the caller's images. It expects the JWT middleware to run first.
+func (h *ShareLinkHandlers) HandleShareLinks(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		input, err := Deserializers.DeserializeShareLinkInput(bodyBytes)
+		if err != nil || input.ImageID == "" {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		// Only the owner may share an image.
+		if _, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, input.ImageID, true); !ok {
+			return
+		}
+		link, token, err := h.ShareLinks.CreateShareLink(clientID, input)
+		if err != nil {
+			writeShareLinkError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusCreated, shareLinkResponse{Link: link, Token: token, Path: ShareLinkPathPrefix + token})
+	case http.MethodGet:
+		imageID := r.URL.Query().Get("image_id")
+		if imageID == "" {
+			http.Error(w, "Image ID is required", http.StatusBadRequest)
+			return
+		}
+		if _, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, imageID, true); !ok {
+			return
+		}
+		links, err := h.ShareLinks.ListShareLinks(clientID, imageID)
+		if err != nil {
+			writeShareLinkError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, links)
+	case http.MethodDelete:
+		linkID := r.URL.Query().Get("id")
+		if linkID == "" {
+			http.Error(w, "Share link ID is required", http.StatusBadRequest)
+			return
+		}
+		if err := h.ShareLinks.RevokeShareLink(clientID, linkID); err != nil {
+			writeShareLinkError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleOpenShareLink serves the image behind /s/{token} to unauthenticated clients.
+// Password protected links take the password from the X-Share-Password header, or from the
+// "password" field of a form POST. It is never read from the URL, which ends up in access logs,
+// proxies and browser history.
+func (h *ShareLinkHandlers) HandleOpenShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, ShareLinkPathPrefix)
+	if token == "" {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+	password := r.Header.Get("X-Share-Password")
+	if password == "" && r.Method == http.MethodPost {
+		password = r.PostFormValue("password")
+	}
+
+	link, err := h.ShareLinks.VerifyShareLink(token, password)
+	if err != nil {
+		writeShareLinkError(w, err)
+		return
+	}
+
+	// A link dies with its image, or when the image changes hands.
+	meta, err := h.ImageMetadataManager.GetImageMetadata(link.ImageID)
+	if err != nil || !Metadata.IsImageOwner(meta, link.OwnerID) {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+	imageBytes, err := h.ImageStoreManager.FetchImage(link.ImageID)
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	// Only a view that is served counts against max_views.
+	if err := h.ShareLinks.ConsumeShareLinkView(link); err != nil {
+		writeShareLinkError(w, err)
+		return
+	}
+
+	// Attribute the view to the share link, since the viewer is anonymous.
+	view := &commons.EventInputModel{UserID: anonymousViewerID(link.ID, r), TargetID: link.ImageID}
+	switch {
+	case h.Publisher != nil:
+		if _, err := h.Publisher.PublishEvent(r.Context(), UserEventManagers.EventTypeView, view); err != nil {
+			log.Printf("Error publishing share link view: %v", err)
+		}
+	case h.EventManager != nil:
+		h.EventManager.AddView(r.Context(), view)
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(imageBytes))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(imageBytes)
+}
+
+// anonymousViewerID identifies an anonymous viewer of a share link by the link and a hash of
+// the client address, so views of different people are told apart without storing addresses.
+func anonymousViewerID(linkID string, r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	sum := sha256.Sum256([]byte(linkID + "|" + host))
+	return ShareViewerPrefix + linkID + ":" + hex.EncodeToString(sum[:8])
+}
+
+// writeShareLinkError maps share link errors onto HTTP status codes.
+func writeShareLinkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrShareLinkNotFound):
+		http.Error(w, "Share link not found", http.StatusNotFound)
+	case errors.Is(err, ErrShareLinkExpired), errors.Is(err, ErrShareLinkExhausted):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, ErrSharePasswordRequired):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrSharePasswordInvalid):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrInvalidShareLink):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Share link operation failed: %v", err)
+		http.Error(w, "Share link operation failed", http.StatusInternalServerError)
+	}
+}
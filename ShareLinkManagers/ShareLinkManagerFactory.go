@@ -0,0 +1,49 @@
+package ShareLinkManagers
+
+import (
+	"GOLA/commons"
+	"GOLA/commons/models"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrShareLinkNotFound is returned for unknown, forged or revoked tokens.
+	ErrShareLinkNotFound = errors.New("share link not found")
+	// ErrShareLinkExpired is returned once the expiry time of a link has passed.
+	ErrShareLinkExpired = errors.New("share link has expired")
+	// ErrShareLinkExhausted is returned once a link has been viewed its maximum number of times.
+	ErrShareLinkExhausted = errors.New("share link has reached its view limit")
+	// ErrSharePasswordRequired is returned when a password protected link is opened without one.
+	ErrSharePasswordRequired = errors.New("share link requires a password")
+	// ErrSharePasswordInvalid is returned when the supplied password does not match.
+	ErrSharePasswordInvalid = errors.New("share link password is incorrect")
+	// ErrInvalidShareLink is returned when the requested restrictions are not valid.
+	ErrInvalidShareLink = errors.New("invalid share link restrictions")
+)
+
+// ShareLinkManager defines the required methods for managing share links of images.
+type ShareLinkManager interface {
+	Initialize() error
+	// CreateShareLink creates a link for an image and returns it with its signed token.
+	// The token is only available at creation time.
+	CreateShareLink(ownerID string, input *commons.ShareLinkInputModel) (*models.ShareLink, string, error)
+	// ListShareLinks returns the links created by ownerID for an image.
+	ListShareLinks(ownerID, imageID string) ([]models.ShareLink, error)
+	// RevokeShareLink disables a link so its token stops working.
+	RevokeShareLink(ownerID, linkID string) error
+	// VerifyShareLink verifies a token and password and returns the link if it can be opened.
+	VerifyShareLink(token, password string) (*models.ShareLink, error)
+	// ConsumeShareLinkView records one view of a verified link, failing once it is used up.
+	ConsumeShareLinkView(link *models.ShareLink) error
+}
+
+// GetShareLinkManager returns an instance of the requested share link manager.
+func GetShareLinkManager(storageType string) (ShareLinkManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresShareLinkManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported share link storage type: %s", storageType)
+	}
+}
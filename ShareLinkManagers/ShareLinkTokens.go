@@ -0,0 +1,39 @@
+package ShareLinkManagers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// Share link tokens have the form "<link id>.<signature>", where the signature is an
+// HMAC-SHA256 of the link ID. Forged tokens are rejected without touching the database;
+// revocation is handled by the stored link.
+
+// newLinkID returns a random, URL safe link identifier.
+func newLinkID() (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// signLinkID returns the token for a link ID.
+func signLinkID(secret []byte, linkID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(linkID))
+	return linkID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyToken checks the signature of a token and returns its link ID.
+func verifyToken(secret []byte, token string) (string, bool) {
+	linkID, _, found := strings.Cut(token, ".")
+	if !found || linkID == "" {
+		return "", false
+	}
+	expected := signLinkID(secret, linkID)
+	return linkID, hmac.Equal([]byte(expected), []byte(token))
+}
@@ -0,0 +1,233 @@
+package StatsRollups
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	"GOLA/commons"
+	dbCommons "GOLA/commons/db"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Erasure deletes the rollups of the subject as an owner and those of their deleted images.
+func init() {
+	Erasure.Register(Erasure.Step{Name: "engagement_rollups", Table: "engagement_rollups",
+		Query: `DELETE FROM engagement_rollups
+			WHERE (scope = 'owner' AND scope_id = $1) OR (scope = 'image' AND scope_id = ANY($2))`,
+		Args: Erasure.BySubjectAndImages})
+}
+
+// rolledUpEvents are the event types counted by the rollups.
+var rolledUpEvents = []string{"user-like", "user-dislike", "user-view", "user-comment"}
+
+// rollupChunks bounds the range of events aggregated by one statement, so catching up on a
+// long history does not hold a single huge query.
+var rollupChunks = map[string]time.Duration{
+	Hourly: 24 * time.Hour,
+	Daily:  31 * 24 * time.Hour,
+}
+
+// PostgresRollupManager keeps engagement_rollups up to date from user_events. Each bucket is
+// aggregated once it has closed; rollup_state remembers how far every granularity has got.
+type PostgresRollupManager struct {
+	DB *sql.DB
+	// Lag delays the rollup of a closed bucket, so events still buffered by the event manager
+	// when the bucket closed are counted.
+	Lag time.Duration
+}
+
+// Initialize connects to the database (if needed) and ensures the rollup tables exist.
+func (p *PostgresRollupManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+	if p.Lag == 0 {
+		p.Lag = 5 * time.Minute
+		if value := os.Getenv("ROLLUP_LAG"); value != "" {
+			lag, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid ROLLUP_LAG: %w", err)
+			}
+			p.Lag = lag
+		}
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS engagement_rollups (
+		granularity VARCHAR(10) NOT NULL,
+		bucket_start TIMESTAMP NOT NULL,
+		scope VARCHAR(10) NOT NULL,
+		scope_id VARCHAR(100) NOT NULL,
+		likes INT NOT NULL DEFAULT 0,
+		dislikes INT NOT NULL DEFAULT 0,
+		views INT NOT NULL DEFAULT 0,
+		comments INT NOT NULL DEFAULT 0,
+		unique_users INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (granularity, scope, scope_id, bucket_start)
+	);
+	CREATE TABLE IF NOT EXISTS rollup_state (
+		granularity VARCHAR(10) PRIMARY KEY,
+		rolled_until TIMESTAMP NOT NULL
+	);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating rollup tables: %w", err)
+	}
+	return nil
+}
+
+// Rollup aggregates every closed bucket of every granularity that was not rolled up yet.
+func (p *PostgresRollupManager) Rollup(ctx context.Context) error {
+	for _, granularity := range []string{Hourly, Daily} {
+		if err := p.rollup(ctx, granularity); err != nil {
+			return fmt.Errorf("error rolling up %s buckets: %w", granularity, err)
+		}
+	}
+	return nil
+}
+
+func (p *PostgresRollupManager) rollup(ctx context.Context, granularity string) error {
+	var from, until sql.NullTime
+	err := p.DB.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(
+				(SELECT rolled_until FROM rollup_state WHERE granularity = $1),
+				(SELECT date_trunc($1, MIN(created_at)) FROM user_events)
+			),
+			date_trunc($1, NOW()::timestamp - make_interval(secs => $2))
+	`, granularity, p.Lag.Seconds()).Scan(&from, &until)
+	if err != nil {
+		return err
+	}
+	if !from.Valid {
+		// No events yet.
+		return nil
+	}
+
+	var buckets int
+	for start := from.Time; start.Before(until.Time); {
+		end := start.Add(rollupChunks[granularity])
+		if end.After(until.Time) {
+			end = until.Time
+		}
+		n, err := p.rollupRange(ctx, granularity, start, end)
+		if err != nil {
+			return err
+		}
+		buckets += n
+		start = end
+	}
+	if buckets > 0 {
+		log.Printf("Rolled up %d engagement rows per %s up to %s", buckets, granularity, until.Time.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// rollupRange aggregates the events of [start, end) per image and per image owner and records
+// end as rolled up, in one transaction. Rows of a bucket are replaced, so reruns are harmless.
+func (p *PostgresRollupManager) rollupRange(ctx context.Context, granularity string, start, end time.Time) (int, error) {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		WITH events AS (
+			SELECT e.event_type, e.user_id, e.target_id, date_trunc($1, e.created_at) AS bucket_start,
+				m.metadata->>'owner_id' AS owner_id
+			FROM user_events e
+			LEFT JOIN image_metadata m ON m.image_id = e.target_id
+			WHERE e.created_at >= $2 AND e.created_at < $3 AND e.event_type = ANY($4)
+		)
+		INSERT INTO engagement_rollups
+			(granularity, bucket_start, scope, scope_id, likes, dislikes, views, comments, unique_users)
+		SELECT $1, bucket_start, scope, scope_id,
+			COUNT(*) FILTER (WHERE event_type = 'user-like'),
+			COUNT(*) FILTER (WHERE event_type = 'user-dislike'),
+			COUNT(*) FILTER (WHERE event_type = 'user-view'),
+			COUNT(*) FILTER (WHERE event_type = 'user-comment'),
+			COUNT(DISTINCT user_id)
+		FROM (
+			SELECT 'image' AS scope, target_id AS scope_id, bucket_start, event_type, user_id FROM events
+			UNION ALL
+			SELECT 'owner', owner_id, bucket_start, event_type, user_id FROM events WHERE owner_id IS NOT NULL
+		) scoped
+		GROUP BY bucket_start, scope, scope_id
+		ON CONFLICT (granularity, scope, scope_id, bucket_start) DO UPDATE SET
+			likes = EXCLUDED.likes,
+			dislikes = EXCLUDED.dislikes,
+			views = EXCLUDED.views,
+			comments = EXCLUDED.comments,
+			unique_users = EXCLUDED.unique_users
+	`, granularity, start, end, pq.Array(rolledUpEvents))
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rollup_state (granularity, rolled_until) VALUES ($1, $2)
+		ON CONFLICT (granularity) DO UPDATE SET rolled_until = EXCLUDED.rolled_until
+	`, granularity, end)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+// GetSeries returns the rolled up buckets of the range, with zeroes for buckets without events
+// and for buckets that were not rolled up yet.
+func (p *PostgresRollupManager) GetSeries(ctx context.Context, query SeriesQuery) (*commons.EngagementSeries, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	length, _ := step(query.Granularity)
+
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT b.bucket_start,
+			COALESCE(r.likes, 0), COALESCE(r.dislikes, 0), COALESCE(r.views, 0),
+			COALESCE(r.comments, 0), COALESCE(r.unique_users, 0)
+		FROM generate_series($4::timestamp, $5::timestamp - make_interval(secs => $6), make_interval(secs => $6)) AS b(bucket_start)
+		LEFT JOIN engagement_rollups r
+			ON r.granularity = $1 AND r.scope = $2 AND r.scope_id = $3 AND r.bucket_start = b.bucket_start
+		ORDER BY b.bucket_start
+	`, query.Granularity, query.Scope, query.ID, query.From, query.To, length.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error querying engagement series: %w", err)
+	}
+	defer rows.Close()
+
+	series := &commons.EngagementSeries{
+		Scope:       query.Scope,
+		ID:          query.ID,
+		Granularity: query.Granularity,
+		From:        query.From,
+		To:          query.To,
+		Buckets:     []commons.EngagementBucket{},
+	}
+	for rows.Next() {
+		var b commons.EngagementBucket
+		if err := rows.Scan(&b.Start, &b.Likes, &b.Dislikes, &b.Views, &b.Comments, &b.UniqueUsers); err != nil {
+			return nil, fmt.Errorf("error scanning engagement bucket: %w", err)
+		}
+		b.Start = b.Start.UTC()
+		series.Buckets = append(series.Buckets, b)
+	}
+	return series, rows.Err()
+}
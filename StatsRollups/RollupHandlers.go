@@ -0,0 +1,88 @@
+package StatsRollups
+
+import (
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/utils"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultRanges is the range of a series request that gives no from parameter.
+var defaultRanges = map[string]time.Duration{
+	Hourly: 24 * time.Hour,
+	Daily:  30 * 24 * time.Hour,
+}
+
+// RollupHandlers exposes the engagement series over HTTP. Every handler expects the JWT
+// middleware to have put the caller's client ID into the request context.
+type RollupHandlers struct {
+	Rollups              RollupManager
+	ImageMetadataManager Metadata.ImageMetadataManager
+}
+
+// HandleHistory returns the engagement series of the image given by ?target_id=, or of the
+// portfolio given by ?owner_id= (the caller's own by default). ?granularity= is hour or day
+// (default hour) and ?from= and ?to= are RFC 3339 times (default: the last day of hours or the
+// last 30 days). Portfolios are only visible to their owner and to admins.
+func (h *RollupHandlers) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	query := SeriesQuery{Granularity: params.Get("granularity")}
+	if query.Granularity == "" {
+		query.Granularity = Hourly
+	}
+	if targetID := params.Get("target_id"); targetID != "" {
+		if _, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, targetID, false); !ok {
+			return
+		}
+		query.Scope, query.ID = ScopeImage, targetID
+	} else {
+		ownerID := params.Get("owner_id")
+		if ownerID == "" {
+			ownerID = clientID
+		}
+		if ownerID != clientID && !utils.IsAdmin(clientID) {
+			http.Error(w, "Only the owner can see the history of a portfolio", http.StatusForbidden)
+			return
+		}
+		query.Scope, query.ID = ScopeOwner, ownerID
+	}
+
+	query.To = time.Now()
+	if value := params.Get("to"); value != "" {
+		if query.To, err = time.Parse(time.RFC3339, value); err != nil {
+			http.Error(w, "Invalid to time, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+	}
+	query.From = query.To.Add(-defaultRanges[query.Granularity])
+	if value := params.Get("from"); value != "" {
+		if query.From, err = time.Parse(time.RFC3339, value); err != nil {
+			http.Error(w, "Invalid from time, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+	}
+
+	series, err := h.Rollups.GetSeries(r.Context(), query)
+	if errors.Is(err, ErrInvalidSeriesQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching engagement series: %v", err)
+		http.Error(w, "Failed to fetch engagement history", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, series)
+}
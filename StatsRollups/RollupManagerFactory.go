@@ -0,0 +1,94 @@
+package StatsRollups
+
+import (
+	"GOLA/commons"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Granularities of the rollup buckets.
+const (
+	Hourly = "hour"
+	Daily  = "day"
+)
+
+// Scopes of an engagement series: a single image, or every image of an owner.
+const (
+	ScopeImage = "image"
+	ScopeOwner = "owner"
+)
+
+var (
+	// ErrInvalidSeriesQuery is returned for unknown scopes or granularities and for ranges that
+	// are empty or too long.
+	ErrInvalidSeriesQuery = errors.New("invalid series query")
+)
+
+// MaxBuckets limits the number of buckets returned by one series query.
+const MaxBuckets = 1000
+
+// SeriesQuery selects the engagement series of an image or a portfolio.
+type SeriesQuery struct {
+	Scope       string
+	ID          string
+	Granularity string
+	From        time.Time
+	To          time.Time
+}
+
+// RollupManager aggregates user events into time buckets and serves them as series.
+type RollupManager interface {
+	Initialize() error
+	// Rollup aggregates the events of every bucket that closed since the last run. It is
+	// idempotent and meant to run periodically.
+	Rollup(ctx context.Context) error
+	// GetSeries returns one bucket per hour or day of the range, including empty ones.
+	GetSeries(ctx context.Context, query SeriesQuery) (*commons.EngagementSeries, error)
+}
+
+// GetRollupManager returns an instance of the requested rollup manager.
+func GetRollupManager(storageType string) (RollupManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresRollupManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported rollup storage type: %s", storageType)
+	}
+}
+
+// step returns the length of a bucket.
+func step(granularity string) (time.Duration, error) {
+	switch granularity {
+	case Hourly:
+		return time.Hour, nil
+	case Daily:
+		return 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown granularity %q", ErrInvalidSeriesQuery, granularity)
+	}
+}
+
+// Validate checks the query and aligns its range to whole buckets.
+func (q *SeriesQuery) Validate() error {
+	if q.Scope != ScopeImage && q.Scope != ScopeOwner {
+		return fmt.Errorf("%w: unknown scope %q", ErrInvalidSeriesQuery, q.Scope)
+	}
+	if q.ID == "" {
+		return fmt.Errorf("%w: missing %s id", ErrInvalidSeriesQuery, q.Scope)
+	}
+	length, err := step(q.Granularity)
+	if err != nil {
+		return err
+	}
+	q.From = q.From.UTC().Truncate(length)
+	q.To = q.To.UTC().Truncate(length)
+	if !q.To.After(q.From) {
+		return fmt.Errorf("%w: the range is empty", ErrInvalidSeriesQuery)
+	}
+	if q.To.Sub(q.From)/length > MaxBuckets {
+		return fmt.Errorf("%w: the range spans more than %d buckets", ErrInvalidSeriesQuery, MaxBuckets)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package Trending
+
+import (
+	"GOLA/commons"
+	dbCommons "GOLA/commons/db"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// refreshBatchSize bounds the number of events taken in by one statement.
+const refreshBatchSize = 10000
+
+// PostgresTrendingManager ranks images from user_events. Refresh folds the events not taken in
+// yet into trending_points, the weighted points of every image per five minutes of the last
+// week. The score of an image in a window is the sum of its points in the window, each divided
+// by (age in hours + 2) ^ gravity. Rankings are kept in Redis.
+type PostgresTrendingManager struct {
+	DB      *sql.DB
+	Weights map[string]float64
+	Gravity float64
+	// Size is the number of images kept per ranking.
+	Size int
+	// Overlap is how far before the newest event taken in the next refresh looks again, so
+	// events inserted late, e.g. by a flush worker behind another one, are still taken in.
+	Overlap time.Duration
+}
+
+// Initialize connects to the database (if needed), reads the TRENDING_* settings and ensures
+// the trending tables exist.
+func (p *PostgresTrendingManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+	if p.Weights == nil {
+		weights, err := ParseWeights(os.Getenv("TRENDING_WEIGHTS"))
+		if err != nil {
+			return fmt.Errorf("invalid TRENDING_WEIGHTS: %w", err)
+		}
+		p.Weights = weights
+	}
+	if p.Gravity == 0 {
+		p.Gravity = DefaultGravity
+		if value := os.Getenv("TRENDING_GRAVITY"); value != "" {
+			gravity, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid TRENDING_GRAVITY: %w", err)
+			}
+			p.Gravity = gravity
+		}
+	}
+	if p.Size == 0 {
+		p.Size = 1000
+		if value := os.Getenv("TRENDING_SIZE"); value != "" {
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("invalid TRENDING_SIZE: %q", value)
+			}
+			p.Size = size
+		}
+	}
+	if p.Overlap == 0 {
+		p.Overlap = time.Hour
+		if value := os.Getenv("TRENDING_OVERLAP"); value != "" {
+			overlap, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid TRENDING_OVERLAP: %w", err)
+			}
+			p.Overlap = overlap
+		}
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS trending_points (
+		target_id VARCHAR(100) NOT NULL,
+		bucket_start TIMESTAMP NOT NULL,
+		points DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (target_id, bucket_start)
+	);
+	CREATE INDEX IF NOT EXISTS trending_points_bucket_idx ON trending_points (bucket_start);
+	CREATE TABLE IF NOT EXISTS trending_state (
+		id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+		taken_until TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS trending_taken_events (
+		event_id BIGINT PRIMARY KEY,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS trending_taken_events_created_idx ON trending_taken_events (created_at);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating trending tables: %w", err)
+	}
+	return nil
+}
+
+// Refresh takes in new events and rewrites the cached ranking of every window and tag.
+func (p *PostgresTrendingManager) Refresh(ctx context.Context) error {
+	taken, err := p.takeInEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("error taking in events: %w", err)
+	}
+	_, err = p.DB.ExecContext(ctx, `DELETE FROM trending_points WHERE bucket_start < NOW()::timestamp - make_interval(secs => $1)`,
+		Windows["week"].Seconds())
+	if err != nil {
+		return fmt.Errorf("error pruning trending points: %w", err)
+	}
+
+	for window := range Windows {
+		ranked, err := p.score(ctx, window, "", 0)
+		if err != nil {
+			return err
+		}
+		rankings := map[string][]commons.TrendingImage{"": ranked.all}
+		for tag, images := range ranked.byTag {
+			rankings[tag] = images
+		}
+		for tag, images := range rankings {
+			if len(images) > p.Size {
+				rankings[tag] = images[:p.Size]
+			}
+		}
+		if err := cacheRankings(ctx, window, rankings); err != nil {
+			return fmt.Errorf("error caching %s rankings: %w", window, err)
+		}
+	}
+	log.Printf("Refreshed trending rankings with %d new events", taken)
+	return nil
+}
+
+// takeInEvents adds the points of the events not taken in yet, batch by batch.
+//
+// Events are followed by created_at: every refresh looks at the events from Overlap before the
+// newest one taken in, and trending_taken_events remembers the IDs taken in within that range,
+// so an event inserted after newer ones is taken in exactly once.
+func (p *PostgresTrendingManager) takeInEvents(ctx context.Context) (int, error) {
+	eventTypes := make([]string, 0, len(p.Weights))
+	weights := make([]float64, 0, len(p.Weights))
+	for eventType, weight := range p.Weights {
+		eventTypes = append(eventTypes, eventType)
+		weights = append(weights, weight)
+	}
+
+	var total int
+	for {
+		var taken int
+		err := p.DB.QueryRowContext(ctx, `
+			WITH state AS (
+				SELECT COALESCE((SELECT taken_until FROM trending_state),
+					NOW()::timestamp - make_interval(secs => $4)) - make_interval(secs => $5) AS since
+			), batch AS (
+				SELECT e.id, e.event_type, e.target_id, e.created_at
+				FROM user_events e, state
+				WHERE e.created_at >= state.since
+					AND NOT EXISTS (SELECT 1 FROM trending_taken_events t WHERE t.event_id = e.id)
+				ORDER BY e.created_at, e.id
+				LIMIT $1
+			), points AS (
+				INSERT INTO trending_points (target_id, bucket_start, points)
+				SELECT b.target_id,
+					date_trunc('hour', b.created_at) + floor(EXTRACT(MINUTE FROM b.created_at) / 5) * INTERVAL '5 minutes',
+					SUM(w.weight)
+				FROM batch b
+				JOIN unnest($2::text[], $3::float8[]) AS w(event_type, weight) ON w.event_type = b.event_type
+				WHERE b.created_at >= NOW()::timestamp - make_interval(secs => $4)
+				GROUP BY 1, 2
+				ON CONFLICT (target_id, bucket_start) DO UPDATE SET points = trending_points.points + EXCLUDED.points
+			), remembered AS (
+				INSERT INTO trending_taken_events (event_id, created_at)
+				SELECT id, created_at FROM batch
+			), advanced AS (
+				INSERT INTO trending_state (id, taken_until)
+				SELECT TRUE, MAX(created_at) FROM batch HAVING COUNT(*) > 0
+				ON CONFLICT (id) DO UPDATE SET taken_until = GREATEST(trending_state.taken_until, EXCLUDED.taken_until)
+			)
+			SELECT COUNT(*) FROM batch
+		`, refreshBatchSize, pq.Array(eventTypes), pq.Array(weights), Windows["week"].Seconds(), p.Overlap.Seconds()).Scan(&taken)
+		if err != nil {
+			return total, err
+		}
+		total += taken
+		if taken < refreshBatchSize {
+			break
+		}
+	}
+
+	// IDs older than the overlap are never looked at again.
+	_, err := p.DB.ExecContext(ctx, `
+		DELETE FROM trending_taken_events
+		WHERE created_at < (SELECT taken_until FROM trending_state) - make_interval(secs => $1)
+	`, p.Overlap.Seconds())
+	if err != nil {
+		return total, fmt.Errorf("error pruning taken events: %w", err)
+	}
+	return total, nil
+}
+
+type rankedImages struct {
+	all   []commons.TrendingImage
+	byTag map[string][]commons.TrendingImage
+}
+
+// score ranks the public images with points in the window, best first. When tag is set only
+// images with the tag are ranked; limit, when positive, bounds the number of images returned.
+func (p *PostgresTrendingManager) score(ctx context.Context, window, tag string, limit int) (*rankedImages, error) {
+	length, ok := Windows[window]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWindow, window)
+	}
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT m.image_id, COALESCE(m.metadata->>'tags', ''),
+			SUM(t.points / POWER(EXTRACT(EPOCH FROM NOW()::timestamp - t.bucket_start) / 3600 + 2, $2)) AS score
+		FROM trending_points t
+		JOIN image_metadata m ON m.image_id = t.target_id
+		WHERE t.bucket_start >= NOW()::timestamp - make_interval(secs => $1)
+			AND COALESCE(m.metadata->>'is_private', '') <> 'true'
+			AND ($3 = '' OR $3 = ANY(regexp_split_to_array(lower(trim(COALESCE(m.metadata->>'tags', ''))), '\s*,\s*')))
+		GROUP BY m.image_id
+		HAVING SUM(t.points) > 0
+		ORDER BY score DESC, m.image_id
+		LIMIT NULLIF($4, 0)
+	`, length.Seconds(), p.Gravity, tag, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error scoring %s trending images: %w", window, err)
+	}
+	defer rows.Close()
+
+	// Rows come best first, so the tag lists are built in order too.
+	ranked := &rankedImages{all: []commons.TrendingImage{}, byTag: map[string][]commons.TrendingImage{}}
+	for rows.Next() {
+		var image commons.TrendingImage
+		var tags string
+		if err := rows.Scan(&image.ImageID, &tags, &image.Score); err != nil {
+			return nil, fmt.Errorf("error scanning trending image: %w", err)
+		}
+		ranked.all = append(ranked.all, image)
+		for _, t := range imageTags(tags) {
+			ranked.byTag[t] = append(ranked.byTag[t], image)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ranked, nil
+}
+
+// GetTrending serves the ranking from Redis. Until a refresh has cached the window, or when
+// Redis is unavailable, the ranking is computed from Postgres.
+func (p *PostgresTrendingManager) GetTrending(ctx context.Context, window, tag string, offset, limit int) (*commons.TrendingPage, error) {
+	if _, ok := Windows[window]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWindow, window)
+	}
+	page := &commons.TrendingPage{Window: window, Tag: tag}
+
+	images, cached, err := cachedRanking(ctx, window, tag, offset, limit)
+	if err != nil {
+		log.Printf("Error reading cached %s ranking, computing it: %v", window, err)
+	}
+	if err == nil && cached {
+		page.Images = images
+		return page, nil
+	}
+
+	ranked, err := p.score(ctx, window, tag, offset+limit)
+	if err != nil {
+		return nil, err
+	}
+	page.Images = []commons.TrendingImage{}
+	if offset < len(ranked.all) {
+		page.Images = ranked.all[offset:]
+	}
+	return page, nil
+}
@@ -0,0 +1,81 @@
+package Trending
+
+import (
+	redisCache "GOLA/caches/Redis"
+	"GOLA/commons"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rankings are cached in sorted sets: trending:<window> for every public image and
+// trending:<window>:tag:<tag> per tag. trending:<window>:refreshed marks a cached window, so
+// a missing tag ranking reads as empty instead of as not cached.
+const trendingKeyPrefix = "trending:"
+
+// trendingCacheTTL keeps rankings of tags that stopped trending, or of a stopped job, from
+// being served forever.
+var trendingCacheTTL = time.Hour
+
+func rankingKey(window, tag string) string {
+	if tag == "" {
+		return trendingKeyPrefix + window
+	}
+	return trendingKeyPrefix + window + ":tag:" + tag
+}
+
+// cacheRankings replaces the cached rankings of the window. Each ranking is built under a
+// temporary key and renamed into place, so readers never see a half written ranking.
+func cacheRankings(ctx context.Context, window string, rankings map[string][]commons.TrendingImage) error {
+	client := redisCache.RedisClient
+	if client == nil {
+		return nil
+	}
+	pipe := client.Pipeline()
+	for tag, images := range rankings {
+		key := rankingKey(window, tag)
+		if len(images) == 0 {
+			pipe.Del(ctx, key)
+			continue
+		}
+		members := make([]redis.Z, len(images))
+		for i, image := range images {
+			members[i] = redis.Z{Score: image.Score, Member: image.ImageID}
+		}
+		tmp := key + ":building"
+		pipe.Del(ctx, tmp)
+		pipe.ZAdd(ctx, tmp, members...)
+		pipe.Expire(ctx, tmp, trendingCacheTTL)
+		pipe.Rename(ctx, tmp, key)
+	}
+	pipe.Set(ctx, trendingKeyPrefix+window+":refreshed", time.Now().Unix(), trendingCacheTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// cachedRanking returns a page of a cached ranking and whether the window is cached at all.
+func cachedRanking(ctx context.Context, window, tag string, offset, limit int) ([]commons.TrendingImage, bool, error) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return nil, false, nil
+	}
+	err := client.Get(ctx, trendingKeyPrefix+window+":refreshed").Err()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	members, err := client.ZRevRangeWithScores(ctx, rankingKey(window, tag), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	images := make([]commons.TrendingImage, len(members))
+	for i, member := range members {
+		images[i] = commons.TrendingImage{ImageID: member.Member.(string), Score: member.Score}
+	}
+	return images, true, nil
+}
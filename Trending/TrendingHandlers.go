@@ -0,0 +1,69 @@
+package Trending
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Page sizes of the trending feed.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// TrendingHandlers exposes the trending feed over HTTP.
+type TrendingHandlers struct {
+	Trending TrendingManager
+}
+
+// HandleTrending returns a page of trending public images. ?window= is hour, day (default) or
+// week, ?tag= limits the feed to a tag, and ?offset= and ?limit= page through it.
+func (h *TrendingHandlers) HandleTrending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	window := params.Get("window")
+	if window == "" {
+		window = "day"
+	}
+	tag := strings.ToLower(strings.TrimSpace(params.Get("tag")))
+
+	offset, limit := 0, DefaultPageSize
+	if value := params.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if value := params.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > MaxPageSize {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	page, err := h.Trending.GetTrending(r.Context(), window, tag, offset, limit)
+	if errors.Is(err, ErrUnknownWindow) {
+		http.Error(w, "Window must be hour, day or week", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching trending images: %v", err)
+		http.Error(w, "Failed to fetch trending images", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package Trending
+
+import (
+	"GOLA/commons"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownWindow is returned for trending windows other than hour, day and week.
+	ErrUnknownWindow = errors.New("unknown trending window")
+)
+
+// Windows are the trending windows and how far back each of them looks.
+var Windows = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// DefaultWeights are the points an event contributes to the score of its target. Retractions
+// take back the points of the reaction they retract.
+var DefaultWeights = map[string]float64{
+	"user-view":      1,
+	"user-like":      4,
+	"user-dislike":   -2,
+	"user-comment":   6,
+	"user-unlike":    -4,
+	"user-undislike": 2,
+}
+
+// DefaultGravity is how fast points lose weight with age, as in the Hacker News ranking.
+const DefaultGravity = 1.8
+
+// TrendingManager ranks images by their recent engagement.
+type TrendingManager interface {
+	Initialize() error
+	// Refresh takes in the events recorded since the last refresh and recomputes the rankings.
+	// It is meant to run periodically.
+	Refresh(ctx context.Context) error
+	// GetTrending returns a page of the ranking of a window, optionally limited to a tag.
+	GetTrending(ctx context.Context, window, tag string, offset, limit int) (*commons.TrendingPage, error)
+}
+
+// GetTrendingManager returns an instance of the requested trending manager.
+func GetTrendingManager(storageType string) (TrendingManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresTrendingManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported trending storage type: %s", storageType)
+	}
+}
+
+// ParseWeights parses a list of event weights such as "user-like=4,user-view=1". Event types
+// that are not listed keep their default weight.
+func ParseWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64, len(DefaultWeights))
+	for eventType, weight := range DefaultWeights {
+		weights[eventType] = weight
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		eventType, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid event weight %q", pair)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight of %s: %w", eventType, err)
+		}
+		weights[strings.TrimSpace(eventType)] = weight
+	}
+	return weights, nil
+}
+
+// imageTags splits the comma separated tags of an image's metadata, like the search indexer.
+func imageTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"context"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkEventManager returns the PostgreSQL event manager when DB_HOST is set, and the
+// memory store otherwise.
+func benchmarkEventManager(b *testing.B) EventManager {
+	b.Helper()
+	var manager EventManager = &MemoryEventManager{}
+	if os.Getenv("DB_HOST") != "" {
+		manager = &DatabaseEventManager{}
+	}
+	manager.Initialize()
+	b.Cleanup(func() {
+		if err := manager.Close(); err != nil {
+			b.Errorf("closing the event manager: %v", err)
+		}
+	})
+	return manager
+}
+
+// BenchmarkAddEventViews records views from many goroutines at once, which is how the view
+// endpoint is driven under load. Run with -cpu to vary the concurrency.
+func BenchmarkAddEventViews(b *testing.B) {
+	b.Run("unique viewers", func(b *testing.B) {
+		manager := benchmarkEventManager(b)
+		var next int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				userID := "bench-viewer-" + strconv.FormatInt(atomic.AddInt64(&next, 1), 10)
+				event := &commons.EventInputModel{UserID: userID, TargetID: "bench-image"}
+				if _, err := manager.AddEvent(context.Background(), EventTypeView, event); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+		b.StopTimer()
+		if err := manager.SaveEvents(); err != nil {
+			b.Fatal(err)
+		}
+	})
+
+	// Repeated views of one user fall within the de-duplication window after the first.
+	b.Run("repeated views", func(b *testing.B) {
+		manager := benchmarkEventManager(b)
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				event := &commons.EventInputModel{UserID: "bench-viewer", TargetID: "bench-image"}
+				if _, err := manager.AddEvent(context.Background(), EventTypeView, event); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,369 @@
+package UserEventManagers
+
+import (
+	"GOLA/Deserializers"
+	"GOLA/ImageManagers/Metadata"
+	"GOLA/LiveUpdates"
+	"GOLA/Mentions"
+	"GOLA/commons"
+	"GOLA/commons/models"
+	"GOLA/utils"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// CommentHandlers exposes a CommentManager over HTTP. Every handler expects the JWT middleware
+// to have put the caller's client ID into the request context.
+//
+// Comments follow the privacy of their image. The owner of the image and admins moderate its
+// comments: they see hidden comments, may hide or restore any comment and may delete it.
+type CommentHandlers struct {
+	Comments             CommentManager
+	ImageMetadataManager Metadata.ImageMetadataManager
+	// EventManager, when set, records a user-comment event for every new comment.
+	EventManager EventManager
+	// Publisher, when set, publishes the user-comment events instead, to be recorded later.
+	Publisher EventPublisher
+	// Live, when set, pushes new comments to the viewers of the image.
+	Live LiveUpdates.LiveFeed
+	// Moderator, when set, screens new and edited comments. Comments it hides are neither
+	// recorded as events nor pushed to viewers.
+	Moderator CommentModerator
+	// Mentions, when set, indexes the @mentions and #hashtags of comments.
+	Mentions Mentions.MentionManager
+}
+
+// HandleCreateComment posts a comment or, with "parent_id", a reply.
+func (h *CommentHandlers) HandleCreateComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+	input, err := Deserializers.DeserializeCommentInput(bodyBytes)
+	if err != nil || input.TargetID == "" {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+	if _, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, input.TargetID, false); !ok {
+		return
+	}
+
+	var screening CommentScreening
+	if h.Moderator != nil {
+		if screening, err = h.Moderator.ScreenComment(r.Context(), clientID, input.TargetID, input.Content); err != nil {
+			writeCommentError(w, err)
+			return
+		}
+	}
+
+	comment, err := h.Comments.CreateComment(r.Context(), clientID, input)
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	if h.Moderator != nil {
+		if comment, err = h.Moderator.ApplyScreening(r.Context(), comment, screening); err != nil {
+			writeCommentError(w, err)
+			return
+		}
+	}
+	// Hidden comments are indexed too; listings leave them out until they are restored.
+	h.indexMentions(r, comment)
+	if comment.State == models.CommentHidden {
+		utils.WriteJSON(w, http.StatusCreated, comment)
+		return
+	}
+	event := &commons.EventInputModel{UserID: clientID, TargetID: comment.TargetID, Comment: comment.Content}
+	switch {
+	case h.Publisher != nil:
+		if _, err := h.Publisher.PublishEvent(r.Context(), EventTypeComment, event); err != nil {
+			log.Printf("Error publishing comment event: %v", err)
+		}
+	case h.EventManager != nil:
+		h.EventManager.AddComment(r.Context(), event)
+	}
+	if h.Live != nil && comment.State == models.CommentVisible {
+		if err := h.Live.Publish(r.Context(), comment.TargetID, LiveUpdates.EventComment, comment); err != nil {
+			log.Printf("Error pushing comment %d: %v", comment.ID, err)
+		}
+	}
+	utils.WriteJSON(w, http.StatusCreated, comment)
+}
+
+// GetCommentsHandler returns a page of the comments of ?target_id=, or of the replies to
+// ?parent_id=. ?cursor= continues from a previous page, ?limit= sets the page size and
+// ?replies= the number of replies nested under each top-level comment (default 3).
+func (h *CommentHandlers) GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	targetID := params.Get("target_id")
+	if targetID == "" {
+		http.Error(w, "target_id is required", http.StatusBadRequest)
+		return
+	}
+	meta, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, targetID, false)
+	if !ok {
+		return
+	}
+
+	query := CommentQuery{
+		TargetID:  targetID,
+		Cursor:    params.Get("cursor"),
+		Replies:   3,
+		ViewerID:  clientID,
+		Moderator: isCommentModerator(meta, clientID),
+	}
+	for name, dest := range map[string]*int{"limit": &query.Limit, "replies": &query.Replies} {
+		if value := params.Get(name); value != "" {
+			if *dest, err = strconv.Atoi(value); err != nil || *dest < 0 {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if value := params.Get("parent_id"); value != "" {
+		if query.ParentID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, "Invalid parent_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.Comments.ListComments(r.Context(), query)
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandleComment fetches (GET ?id=), edits (PUT, author only) or deletes (DELETE ?id=, author
+// or moderator) a comment.
+func (h *CommentHandlers) HandleComment(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		comment, _, ok := h.authorize(w, r, r.URL.Query().Get("id"), clientID)
+		if !ok {
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, comment)
+	case http.MethodPut:
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		input, err := Deserializers.DeserializeCommentUpdateInput(bodyBytes)
+		if err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		current, _, ok := h.authorize(w, r, strconv.FormatInt(input.ID, 10), clientID)
+		if !ok {
+			return
+		}
+		// Only the author's changes to the text are screened again.
+		screen := h.Moderator != nil && current.AuthorID == clientID && input.Content != current.Content
+		var screening CommentScreening
+		if screen {
+			if screening, err = h.Moderator.ScreenComment(r.Context(), clientID, current.TargetID, input.Content); err != nil {
+				writeCommentError(w, err)
+				return
+			}
+		}
+		comment, err := h.Comments.EditComment(r.Context(), input.ID, clientID, input.Content)
+		if err != nil {
+			writeCommentError(w, err)
+			return
+		}
+		if screen {
+			if comment, err = h.Moderator.ApplyScreening(r.Context(), comment, screening); err != nil {
+				writeCommentError(w, err)
+				return
+			}
+		}
+		h.indexMentions(r, comment)
+		utils.WriteJSON(w, http.StatusOK, comment)
+	case http.MethodDelete:
+		comment, moderator, ok := h.authorize(w, r, r.URL.Query().Get("id"), clientID)
+		if !ok {
+			return
+		}
+		if comment.AuthorID != clientID && !moderator {
+			writeCommentError(w, ErrCommentForbidden)
+			return
+		}
+		if err := h.Comments.DeleteComment(r.Context(), comment.ID); err != nil {
+			writeCommentError(w, err)
+			return
+		}
+		if h.Mentions != nil {
+			if err := h.Mentions.RemoveSource(r.Context(), Mentions.KindComment, strconv.FormatInt(comment.ID, 10)); err != nil {
+				log.Printf("Error removing mentions of comment %d: %v", comment.ID, err)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleModerateComment changes the moderation state of a comment. Anyone who can see a
+// comment may flag it; only moderators may hide it or make it visible again.
+func (h *CommentHandlers) HandleModerateComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusBadRequest)
+		return
+	}
+	input, err := Deserializers.DeserializeCommentUpdateInput(bodyBytes)
+	if err != nil || input.State == "" {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+	current, moderator, ok := h.authorize(w, r, strconv.FormatInt(input.ID, 10), clientID)
+	if !ok {
+		return
+	}
+	if !moderator {
+		// Flagging twice is harmless; anything else needs a moderator.
+		if input.State != models.CommentFlagged || current.State == models.CommentHidden {
+			writeCommentError(w, ErrCommentForbidden)
+			return
+		}
+	}
+	comment, err := h.Comments.SetCommentState(r.Context(), input.ID, input.State)
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, comment)
+}
+
+// HandleCommentHistory returns the previous versions of the comment given by ?id= to its
+// author and to moderators.
+func (h *CommentHandlers) HandleCommentHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	comment, moderator, ok := h.authorize(w, r, r.URL.Query().Get("id"), clientID)
+	if !ok {
+		return
+	}
+	if comment.AuthorID != clientID && !moderator {
+		writeCommentError(w, ErrCommentForbidden)
+		return
+	}
+	history, err := h.Comments.GetCommentHistory(r.Context(), comment.ID)
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, history)
+}
+
+// indexMentions indexes the mentions and hashtags of a new or edited comment. The index is
+// derived from the comments, so failures are logged rather than failing the request.
+func (h *CommentHandlers) indexMentions(r *http.Request, comment *models.Comment) {
+	if h.Mentions == nil {
+		return
+	}
+	source := Mentions.Source{
+		Kind:     Mentions.KindComment,
+		ID:       strconv.FormatInt(comment.ID, 10),
+		ImageID:  comment.TargetID,
+		AuthorID: comment.AuthorID,
+	}
+	if err := h.Mentions.IndexText(r.Context(), source, comment.Content); err != nil {
+		log.Printf("Error indexing mentions of comment %d: %v", comment.ID, err)
+	}
+}
+
+// authorize loads a comment and checks the caller may see it: they must be able to see its
+// image, and hidden comments are only shown to their author and to moderators. It also
+// reports whether the caller moderates the comment.
+func (h *CommentHandlers) authorize(w http.ResponseWriter, r *http.Request, id, clientID string) (*models.Comment, bool, bool) {
+	commentID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || commentID <= 0 {
+		http.Error(w, "Comment ID is required", http.StatusBadRequest)
+		return nil, false, false
+	}
+	comment, err := h.Comments.GetComment(r.Context(), commentID)
+	if err != nil {
+		writeCommentError(w, err)
+		return nil, false, false
+	}
+	meta, ok := Metadata.AuthorizeImageRequest(w, r, h.ImageMetadataManager, comment.TargetID, false)
+	if !ok {
+		return nil, false, false
+	}
+	moderator := isCommentModerator(meta, clientID)
+	if comment.State == models.CommentHidden && comment.AuthorID != clientID && !moderator {
+		writeCommentError(w, ErrCommentNotFound)
+		return nil, false, false
+	}
+	return comment, moderator, true
+}
+
+// isCommentModerator reports whether the client moderates the comments of an image.
+func isCommentModerator(meta map[string]string, clientID string) bool {
+	return Metadata.IsImageOwner(meta, clientID) || utils.IsAdmin(clientID)
+}
+
+// writeCommentError maps comment manager errors onto HTTP status codes.
+func writeCommentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+	case errors.Is(err, ErrCommentForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrInvalidComment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrCommentRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		log.Printf("Comment operation failed: %v", err)
+		http.Error(w, "Comment operation failed", http.StatusInternalServerError)
+	}
+}
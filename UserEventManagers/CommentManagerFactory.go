@@ -0,0 +1,89 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MaxCommentLength is the maximum length of a comment, in characters.
+const MaxCommentLength = 5000
+
+var (
+	// ErrCommentNotFound is returned when a comment does not exist or has been deleted.
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrInvalidComment is returned for empty or oversized comments, replies to comments of
+	// another target, unknown moderation states and malformed cursors.
+	ErrInvalidComment = errors.New("invalid comment")
+	// ErrCommentForbidden is returned when the caller may not change a comment.
+	ErrCommentForbidden = errors.New("not allowed to change this comment")
+	// ErrCommentRateLimited is returned when the author comments faster than moderation allows.
+	ErrCommentRateLimited = errors.New("commenting too fast, try again later")
+)
+
+// CommentQuery selects a page of comments.
+type CommentQuery struct {
+	TargetID string
+	// ParentID lists the replies to a comment instead of the top-level comments of the target.
+	ParentID int64
+	// Cursor is the NextCursor of the previous page; empty for the first page.
+	Cursor string
+	Limit  int
+	// Replies is the number of replies included per top-level comment; 0 includes none.
+	Replies int
+	// ViewerID sees their own hidden comments; Moderator sees every hidden comment.
+	ViewerID  string
+	Moderator bool
+}
+
+// CommentManager manages threaded comments on images.
+type CommentManager interface {
+	Initialize() error
+
+	// CreateComment posts a comment, or a reply when input.ParentID is set.
+	CreateComment(ctx context.Context, authorID string, input *commons.CommentInputModel) (*models.Comment, error)
+	GetComment(ctx context.Context, commentID int64) (*models.Comment, error)
+	// EditComment replaces the content of a comment, keeping the previous content as a
+	// revision. Only the author may edit.
+	EditComment(ctx context.Context, commentID int64, editorID, content string) (*models.Comment, error)
+	// DeleteComment removes the content of a comment. Comments with replies remain in their
+	// thread as deleted placeholders.
+	DeleteComment(ctx context.Context, commentID int64) error
+	// SetCommentState changes the moderation state of a comment.
+	SetCommentState(ctx context.Context, commentID int64, state string) (*models.Comment, error)
+	// ListComments returns a page of comments, oldest first, with their replies nested.
+	ListComments(ctx context.Context, query CommentQuery) (*models.CommentPage, error)
+	// GetCommentHistory returns the previous versions of a comment, oldest first.
+	GetCommentHistory(ctx context.Context, commentID int64) ([]models.CommentRevision, error)
+}
+
+// CommentScreening is the outcome of screening a comment: the state it should be stored in
+// (empty or visible when nothing objected) and why.
+type CommentScreening struct {
+	State   string
+	Reasons []string
+}
+
+// CommentModerator screens comments as they are written. Comments are screened before they are
+// stored, so that refused comments are never stored, and the screening is applied once the
+// comment has an ID.
+type CommentModerator interface {
+	// ScreenComment screens the content of a new or edited comment. Errors wrapping
+	// ErrCommentRateLimited refuse the comment.
+	ScreenComment(ctx context.Context, authorID, targetID, content string) (CommentScreening, error)
+	// ApplyScreening sets the state of a stored comment and queues it for review, returning the
+	// comment as it is now.
+	ApplyScreening(ctx context.Context, comment *models.Comment, screening CommentScreening) (*models.Comment, error)
+}
+
+// GetCommentManager returns an instance of the requested comment manager.
+func GetCommentManager(storageType string) (CommentManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresCommentManager{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported comment storage type: %s", storageType)
+	}
+}
@@ -0,0 +1,405 @@
+// Package Conformance is the behaviour every EventManager must show, whatever stores its
+// events: how reactions replace each other, how views are de-duplicated, which events are
+// rejected, and how events are listed, paginated and exported. go test runs it against the
+// memory manager, and against PostgreSQL when DB_HOST is set; scripts/EventManagerConformance
+// runs it against any configured store.
+package Conformance
+
+import (
+	userEventsManager "GOLA/UserEventManagers"
+	"GOLA/commons"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Case is one check of the suite.
+type Case struct {
+	Name string
+	Run  func(ctx context.Context, s *Suite) error
+}
+
+// Result is the outcome of a case; Err is nil when it passed.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Suite runs the cases against an initialized manager. The manager must de-duplicate views
+// (VIEW_DEDUP_WINDOW greater than zero) and use the default event types; its store may hold
+// other events, since every run works on users and targets of its own.
+type Suite struct {
+	Manager userEventsManager.EventManager
+	// Prefix makes the IDs of a run unique; NewSuite derives it from the current time.
+	Prefix string
+}
+
+// NewSuite returns a suite for the manager.
+func NewSuite(manager userEventsManager.EventManager) *Suite {
+	return &Suite{Manager: manager, Prefix: fmt.Sprintf("conformance-%d", time.Now().UnixNano())}
+}
+
+// Run runs every case in order and returns their results.
+func (s *Suite) Run(ctx context.Context) []Result {
+	var results []Result
+	for _, c := range Cases() {
+		results = append(results, Result{Name: c.Name, Err: c.Run(ctx, s)})
+	}
+	return results
+}
+
+// Cases returns the cases of the suite.
+func Cases() []Case {
+	return []Case{
+		{"reactions replace each other within their group", testReactions},
+		{"repeated views count once", testViews},
+		{"comments follow the rules of their type", testComments},
+		{"invalid events are rejected", testInvalidEvents},
+		{"stats of an unknown target are zero", testEmptyStats},
+		{"events are listed in order across pages", testListing},
+		{"invalid filters are rejected", testInvalidFilters},
+		{"exports return every selected event", testExport},
+		{"concurrent reactions are all counted", testConcurrentReactions},
+	}
+}
+
+func (s *Suite) id(kind, name string) string {
+	return s.Prefix + "-" + kind + "-" + name
+}
+
+func (s *Suite) input(user, target string) *commons.EventInputModel {
+	return &commons.EventInputModel{UserID: s.id("user", user), TargetID: s.id("target", target)}
+}
+
+// expectCounts compares the counts of the target with want; types missing from want must be 0.
+func (s *Suite) expectCounts(ctx context.Context, target string, want map[string]int) error {
+	stats, err := s.Manager.GetStats(ctx, s.id("target", target))
+	if err != nil {
+		return fmt.Errorf("GetStats: %w", err)
+	}
+	for eventType, count := range stats.Counts {
+		if eventType == userEventsManager.EventTypeComment {
+			// The database manager counts the comments of the comment store.
+			continue
+		}
+		if count != want[eventType] {
+			return fmt.Errorf("%s count is %d, want %d", eventType, count, want[eventType])
+		}
+	}
+	return nil
+}
+
+// eventTypes lists the events of the target, oldest first, and returns their types.
+func (s *Suite) eventTypes(ctx context.Context, target string) ([]string, error) {
+	if err := s.Manager.SaveEvents(); err != nil {
+		return nil, fmt.Errorf("SaveEvents: %w", err)
+	}
+	page, err := s.Manager.ListEvents(ctx, commons.EventFilter{TargetID: s.id("target", target), Ascending: true})
+	if err != nil {
+		return nil, fmt.Errorf("ListEvents: %w", err)
+	}
+	var types []string
+	for _, ev := range page.Events {
+		types = append(types, ev.EventType)
+	}
+	return types, nil
+}
+
+func testReactions(ctx context.Context, s *Suite) error {
+	m := s.Manager
+	if m.AddLike(ctx, s.input("a", "reactions")) == nil {
+		return errors.New("AddLike failed")
+	}
+	// Repeating the current reaction changes nothing.
+	if m.AddLike(ctx, s.input("a", "reactions")) == nil {
+		return errors.New("repeated AddLike failed")
+	}
+	if err := s.expectCounts(ctx, "reactions", map[string]int{userEventsManager.EventTypeLike: 1}); err != nil {
+		return err
+	}
+	if m.AddDislike(ctx, s.input("a", "reactions")) == nil {
+		return errors.New("AddDislike failed")
+	}
+	if err := s.expectCounts(ctx, "reactions", map[string]int{userEventsManager.EventTypeDislike: 1}); err != nil {
+		return fmt.Errorf("after dislike: %w", err)
+	}
+	reactions, err := m.GetReactions(ctx, s.id("user", "a"), s.id("target", "reactions"))
+	if err != nil {
+		return fmt.Errorf("GetReactions: %w", err)
+	}
+	if strings.Join(reactions, ",") != userEventsManager.EventTypeDislike {
+		return fmt.Errorf("reactions are %v, want only the dislike", reactions)
+	}
+
+	if removed, err := m.RemoveLike(ctx, s.input("a", "reactions")); err != nil || removed {
+		return fmt.Errorf("RemoveLike of a replaced like = %v, %v; want false", removed, err)
+	}
+	if removed, err := m.RemoveDislike(ctx, s.input("a", "reactions")); err != nil || !removed {
+		return fmt.Errorf("RemoveDislike = %v, %v; want true", removed, err)
+	}
+	if err := s.expectCounts(ctx, "reactions", nil); err != nil {
+		return fmt.Errorf("after removal: %w", err)
+	}
+
+	// Every change of the reaction is in the history, but not the repeated like.
+	types, err := s.eventTypes(ctx, "reactions")
+	if err != nil {
+		return err
+	}
+	want := []string{userEventsManager.EventTypeLike, userEventsManager.EventTypeDislike, userEventsManager.EventTypeUndislike}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		return fmt.Errorf("history is %v, want %v", types, want)
+	}
+	return nil
+}
+
+func testViews(ctx context.Context, s *Suite) error {
+	for _, user := range []string{"a", "a", "b"} {
+		if s.Manager.AddView(ctx, s.input(user, "views")) == nil {
+			return errors.New("AddView failed")
+		}
+		// Without Redis the database manager de-duplicates against stored views only.
+		if err := s.Manager.SaveEvents(); err != nil {
+			return fmt.Errorf("SaveEvents: %w", err)
+		}
+	}
+	stats, err := s.Manager.GetStats(ctx, s.id("target", "views"))
+	if err != nil {
+		return fmt.Errorf("GetStats: %w", err)
+	}
+	if stats.Views != 2 || stats.UniqueViews != 2 {
+		return fmt.Errorf("views = %d, unique = %d; want 2 and 2", stats.Views, stats.UniqueViews)
+	}
+	return nil
+}
+
+func testComments(ctx context.Context, s *Suite) error {
+	m := s.Manager
+	input := s.input("a", "comments")
+	if _, err := m.AddEvent(ctx, userEventsManager.EventTypeComment, input); !errors.Is(err, userEventsManager.ErrInvalidEvent) {
+		return fmt.Errorf("empty comment: error %v, want ErrInvalidEvent", err)
+	}
+	input.Comment = strings.Repeat("x", userEventsManager.MaxCommentLength+1)
+	if _, err := m.AddEvent(ctx, userEventsManager.EventTypeComment, input); !errors.Is(err, userEventsManager.ErrInvalidEvent) {
+		return fmt.Errorf("oversized comment: error %v, want ErrInvalidEvent", err)
+	}
+	input.Comment = "nice shot"
+	if _, err := m.AddEvent(ctx, userEventsManager.EventTypeLike, input); !errors.Is(err, userEventsManager.ErrInvalidEvent) {
+		return fmt.Errorf("comment on a like: error %v, want ErrInvalidEvent", err)
+	}
+
+	ev := m.AddComment(ctx, input)
+	if ev == nil {
+		return errors.New("AddComment failed")
+	}
+	// Comments are written directly, so they have their ID right away.
+	if ev.ID == 0 || ev.Comment != input.Comment || ev.EventType != userEventsManager.EventTypeComment {
+		return fmt.Errorf("AddComment returned %+v", *ev)
+	}
+	page, err := m.ListEvents(ctx, commons.EventFilter{TargetID: input.TargetID})
+	if err != nil {
+		return fmt.Errorf("ListEvents: %w", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].ID != ev.ID || page.Events[0].Comment != input.Comment {
+		return fmt.Errorf("listed %+v, want only the comment", page.Events)
+	}
+	return nil
+}
+
+func testInvalidEvents(ctx context.Context, s *Suite) error {
+	m := s.Manager
+	if _, err := m.AddEvent(ctx, "no-such-type", s.input("a", "invalid")); !errors.Is(err, userEventsManager.ErrUnknownEventType) {
+		return fmt.Errorf("unknown type: error %v, want ErrUnknownEventType", err)
+	}
+	if _, err := m.AddEvent(ctx, userEventsManager.EventTypeView, &commons.EventInputModel{UserID: s.id("user", "a")}); !errors.Is(err, userEventsManager.ErrInvalidEvent) {
+		return fmt.Errorf("missing target: error %v, want ErrInvalidEvent", err)
+	}
+	if _, err := m.RemoveEvent(ctx, userEventsManager.EventTypeView, s.input("a", "invalid")); !errors.Is(err, userEventsManager.ErrEventNotRemovable) {
+		return fmt.Errorf("removing a view: error %v, want ErrEventNotRemovable", err)
+	}
+	if m.AddLike(ctx, &commons.EventInputModel{TargetID: s.id("target", "invalid")}) != nil {
+		return errors.New("AddLike without a user succeeded")
+	}
+	types, err := s.eventTypes(ctx, "invalid")
+	if err != nil {
+		return err
+	}
+	if len(types) != 0 {
+		return fmt.Errorf("rejected events were recorded: %v", types)
+	}
+	return nil
+}
+
+func testEmptyStats(ctx context.Context, s *Suite) error {
+	stats, err := s.Manager.GetStats(ctx, s.id("target", "empty"))
+	if err != nil {
+		return fmt.Errorf("GetStats: %w", err)
+	}
+	for _, d := range s.Manager.EventTypes() {
+		count, ok := stats.Counts[d.Name]
+		if !ok || count != 0 {
+			return fmt.Errorf("%s count is %d (present: %v), want 0", d.Name, count, ok)
+		}
+	}
+	if stats.UniqueViews != 0 {
+		return fmt.Errorf("unique views are %d, want 0", stats.UniqueViews)
+	}
+	return nil
+}
+
+// recordViews records one view per user on the target, in order, and returns the users.
+func (s *Suite) recordViews(ctx context.Context, target string, n int) ([]string, error) {
+	var users []string
+	for i := 0; i < n; i++ {
+		user := fmt.Sprintf("viewer%d", i)
+		if s.Manager.AddView(ctx, s.input(user, target)) == nil {
+			return nil, errors.New("AddView failed")
+		}
+		users = append(users, s.id("user", user))
+		// Distinct creation times make the expected order unambiguous.
+		time.Sleep(time.Millisecond)
+	}
+	return users, s.Manager.SaveEvents()
+}
+
+// listAll walks every page of the filter and returns the users of the listed events.
+func (s *Suite) listAll(ctx context.Context, filter commons.EventFilter) ([]string, error) {
+	var users []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			return nil, errors.New("pagination does not end")
+		}
+		page, err := s.Manager.ListEvents(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("ListEvents: %w", err)
+		}
+		if len(page.Events) > filter.Limit {
+			return nil, fmt.Errorf("page of %d events, limit %d", len(page.Events), filter.Limit)
+		}
+		for _, ev := range page.Events {
+			users = append(users, ev.UserID)
+		}
+		if page.NextCursor == "" {
+			return users, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func testListing(ctx context.Context, s *Suite) error {
+	users, err := s.recordViews(ctx, "listing", 5)
+	if err != nil {
+		return err
+	}
+	filter := commons.EventFilter{TargetID: s.id("target", "listing"), Limit: 2, Ascending: true}
+	listed, err := s.listAll(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if strings.Join(listed, ",") != strings.Join(users, ",") {
+		return fmt.Errorf("oldest first: listed %v, want %v", listed, users)
+	}
+
+	filter.Ascending = false
+	if listed, err = s.listAll(ctx, filter); err != nil {
+		return err
+	}
+	for i := range users {
+		if i >= len(listed) || listed[i] != users[len(users)-1-i] {
+			return fmt.Errorf("newest first: listed %v, want the reverse of %v", listed, users)
+		}
+	}
+
+	// Filters combine: one user's views of the target, and no views in an empty time range.
+	filter = commons.EventFilter{TargetID: s.id("target", "listing"), UserID: users[2], Limit: 10}
+	if listed, err = s.listAll(ctx, filter); err != nil {
+		return err
+	}
+	if len(listed) != 1 || listed[0] != users[2] {
+		return fmt.Errorf("by user: listed %v, want [%s]", listed, users[2])
+	}
+	filter = commons.EventFilter{TargetID: s.id("target", "listing"), To: time.Now().Add(-time.Hour), Limit: 10}
+	if listed, err = s.listAll(ctx, filter); err != nil {
+		return err
+	}
+	if len(listed) != 0 {
+		return fmt.Errorf("before the events: listed %v, want none", listed)
+	}
+	return nil
+}
+
+func testInvalidFilters(ctx context.Context, s *Suite) error {
+	filters := map[string]commons.EventFilter{
+		"oversized limit":  {Limit: userEventsManager.MaxEventPageSize + 1},
+		"malformed cursor": {Cursor: "not a cursor"},
+	}
+	for name, filter := range filters {
+		if _, err := s.Manager.ListEvents(ctx, filter); !errors.Is(err, userEventsManager.ErrInvalidEventFilter) {
+			return fmt.Errorf("%s: error %v, want ErrInvalidEventFilter", name, err)
+		}
+	}
+	return nil
+}
+
+func testExport(ctx context.Context, s *Suite) error {
+	users, err := s.recordViews(ctx, "export", 3)
+	if err != nil {
+		return err
+	}
+	// The limit of the filter does not apply to exports.
+	filter := commons.EventFilter{TargetID: s.id("target", "export"), Ascending: true, Limit: 1}
+	var exported []string
+	count, err := s.Manager.ExportEvents(ctx, filter, func(ev commons.Event) error {
+		exported = append(exported, ev.UserID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ExportEvents: %w", err)
+	}
+	if count != len(users) || strings.Join(exported, ",") != strings.Join(users, ",") {
+		return fmt.Errorf("exported %d events %v, want %v", count, exported, users)
+	}
+
+	// An error of the callback stops the export.
+	stop := errors.New("stop")
+	count, err = s.Manager.ExportEvents(ctx, filter, func(commons.Event) error { return stop })
+	if !errors.Is(err, stop) || count != 0 {
+		return fmt.Errorf("failing callback: %d events, error %v; want 0 and the callback's error", count, err)
+	}
+	return nil
+}
+
+func testConcurrentReactions(ctx context.Context, s *Suite) error {
+	const users = 20
+	var wg sync.WaitGroup
+	var failed sync.Map
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := s.input(fmt.Sprintf("user%d", i), "concurrent")
+			var ev *commons.Event
+			if i%2 == 0 {
+				ev = s.Manager.AddLike(ctx, input)
+			} else {
+				ev = s.Manager.AddDislike(ctx, input)
+			}
+			if ev == nil {
+				failed.Store(i, true)
+			}
+		}(i)
+	}
+	wg.Wait()
+	var failures int
+	failed.Range(func(any, any) bool { failures++; return true })
+	if failures > 0 {
+		return fmt.Errorf("%d reactions failed", failures)
+	}
+	return s.expectCounts(ctx, "concurrent", map[string]int{
+		userEventsManager.EventTypeLike:    users / 2,
+		userEventsManager.EventTypeDislike: users / 2,
+	})
+}
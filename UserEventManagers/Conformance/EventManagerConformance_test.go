@@ -0,0 +1,53 @@
+package Conformance_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	userEventsManager "GOLA/UserEventManagers"
+	"GOLA/UserEventManagers/Conformance"
+)
+
+// TestMemoryEventManager runs the suite against the memory store.
+func TestMemoryEventManager(t *testing.T) {
+	runSuite(t, "memory")
+}
+
+// TestDatabaseEventManager runs the suite against PostgreSQL when the DB_* settings point at a
+// database; the suite writes events, so use a scratch one.
+func TestDatabaseEventManager(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST not set")
+	}
+	runSuite(t, "postgresDb")
+}
+
+func runSuite(t *testing.T, store string) {
+	// The suite checks that repeated views count once, with the default event types.
+	t.Setenv("VIEW_DEDUP_WINDOW", "30m")
+	t.Setenv("EVENT_TYPES_CONFIG", "")
+
+	manager, err := userEventsManager.GetEventManager(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.Initialize()
+	t.Cleanup(func() {
+		if err := manager.Close(); err != nil {
+			t.Errorf("closing the event manager: %v", err)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	suite := Conformance.NewSuite(manager)
+	for _, c := range Conformance.Cases() {
+		t.Run(c.Name, func(t *testing.T) {
+			if err := c.Run(ctx, suite); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
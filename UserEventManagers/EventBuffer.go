@@ -0,0 +1,229 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// errBufferClosed is returned when events are added after the buffer has been closed.
+var errBufferClosed = errors.New("event buffer is closed")
+
+// flushTimeout bounds how long SaveEvents waits for the flushers, which do not take flush
+// requests while they retry a failing write.
+const flushTimeout = 30 * time.Second
+
+// eventBuffer is a bounded, in-memory write-behind buffer. Events are flushed in batches
+// when batchSize events are pending or flushInterval has elapsed, whichever comes first.
+// Several flushers drain the queue in parallel, each writing its own batches. When the
+// buffer is full, add blocks until a flusher has made room, so a slow database slows
+// writers down instead of losing events.
+type eventBuffer struct {
+	queue         chan *commons.Event
+	batchSize     int
+	flushInterval time.Duration
+	flush         func([]*commons.Event) error
+
+	// flushRequests has one channel per flusher, so flushNow reaches every pending batch.
+	flushRequests []chan chan error
+	stop          chan struct{}
+	stopped       chan struct{}
+	running       sync.WaitGroup
+	startOnce     sync.Once
+	closeOnce     sync.Once
+
+	// closeMu guards closed; add holds it for reading so Close cannot race a send.
+	closeMu sync.RWMutex
+	closed  bool
+
+	// shutdownErr collects the errors of the final flushes, which close returns.
+	shutdownMu  sync.Mutex
+	shutdownErr error
+}
+
+func newEventBuffer(capacity, batchSize, workers int, flushInterval time.Duration, flush func([]*commons.Event) error) *eventBuffer {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	b := &eventBuffer{
+		queue:         make(chan *commons.Event, capacity),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		flush:         flush,
+		flushRequests: make([]chan chan error, workers),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	for i := range b.flushRequests {
+		b.flushRequests[i] = make(chan chan error)
+	}
+	return b
+}
+
+// start launches the background flushers. It is safe to call more than once.
+func (b *eventBuffer) start() {
+	b.startOnce.Do(func() {
+		b.running.Add(len(b.flushRequests))
+		for _, requests := range b.flushRequests {
+			go b.run(requests)
+		}
+		go func() {
+			b.running.Wait()
+			close(b.stopped)
+		}()
+	})
+}
+
+// add queues an event, blocking while the buffer is full until a flusher makes room or
+// ctx is done.
+func (b *eventBuffer) add(ctx context.Context, event *commons.Event) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+	if b.closed {
+		return errBufferClosed
+	}
+	select {
+	case b.queue <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flushNow writes every event queued so far and waits for the result, or until ctx is done.
+// The replies are buffered, so a flusher that answers after ctx is done does not block.
+func (b *eventBuffer) flushNow(ctx context.Context) error {
+	replies := make([]chan error, 0, len(b.flushRequests))
+	for _, requests := range b.flushRequests {
+		reply := make(chan error, 1)
+		select {
+		case requests <- reply:
+			replies = append(replies, reply)
+		case <-b.stopped:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("flushing events: %w", ctx.Err())
+		}
+	}
+	var errs []error
+	for _, reply := range replies {
+		select {
+		case err := <-reply:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("flushing events: %w", ctx.Err())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// close stops accepting events, flushes what is pending and stops the flusher. It returns the
+// error of the final flush, in which case the events it could not write are lost.
+func (b *eventBuffer) close() error {
+	b.closeOnce.Do(func() {
+		b.closeMu.Lock()
+		b.closed = true
+		b.closeMu.Unlock()
+		b.start() // make sure there is a flusher to drain the queue
+		close(b.stop)
+	})
+	<-b.stopped
+	b.shutdownMu.Lock()
+	defer b.shutdownMu.Unlock()
+	return b.shutdownErr
+}
+
+// run is the loop of a single flusher.
+func (b *eventBuffer) run(flushRequests chan chan error) {
+	defer b.running.Done()
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*commons.Event, 0, b.batchSize)
+	for {
+		select {
+		case event := <-b.queue:
+			batch = append(batch, event)
+			if len(batch) >= b.batchSize {
+				batch = b.writeWithRetry(batch)
+			}
+		case <-ticker.C:
+			batch = b.writeWithRetry(batch)
+		case reply := <-flushRequests:
+			var err error
+			batch, err = b.write(b.drain(batch))
+			reply <- err
+		case <-b.stop:
+			if remaining, err := b.write(b.drain(batch)); err != nil {
+				log.Printf("Error flushing events on shutdown, %d events lost: %v", len(remaining), err)
+				b.shutdownMu.Lock()
+				b.shutdownErr = errors.Join(b.shutdownErr, fmt.Errorf("%d events lost: %w", len(remaining), err))
+				b.shutdownMu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// drain moves every queued event into the batch without blocking.
+func (b *eventBuffer) drain(batch []*commons.Event) []*commons.Event {
+	for {
+		select {
+		case event := <-b.queue:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+}
+
+// write flushes a batch in chunks of at most batchSize events and returns the events that
+// could not be written, so a retry never writes a chunk twice.
+func (b *eventBuffer) write(batch []*commons.Event) ([]*commons.Event, error) {
+	for len(batch) > 0 {
+		n := len(batch)
+		if n > b.batchSize {
+			n = b.batchSize
+		}
+		if err := b.flush(batch[:n]); err != nil {
+			return batch, err
+		}
+		batch = batch[n:]
+	}
+	return batch, nil
+}
+
+// writeWithRetry flushes a batch, retrying with exponential backoff until it succeeds or the
+// buffer is stopped. While it retries, the queue fills up and writers are held back.
+func (b *eventBuffer) writeWithRetry(batch []*commons.Event) []*commons.Event {
+	backoff := 100 * time.Millisecond
+	for len(batch) > 0 {
+		var err error
+		if batch, err = b.write(batch); err == nil {
+			break
+		}
+		log.Printf("Error flushing %d events, retrying in %s: %v", len(batch), backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-b.stop:
+			// Keep the batch; the shutdown path makes a final attempt.
+			return batch
+		}
+		if backoff < 10*time.Second {
+			backoff *= 2
+		}
+	}
+	return batch
+}
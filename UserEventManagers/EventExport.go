@@ -0,0 +1,265 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"GOLA/utils"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats.
+const (
+	ExportNDJSON  = "ndjson"
+	ExportCSV     = "csv"
+	ExportParquet = "parquet"
+)
+
+var (
+	// ErrUnknownExportFormat is returned for export formats other than ndjson, csv and parquet.
+	ErrUnknownExportFormat = errors.New("unknown export format")
+)
+
+// exportFetchSize is the number of rows fetched from the server-side cursor at a time.
+const exportFetchSize = 1000
+
+// parquetRowGroupSize is the number of rows buffered before a Parquet row group is written.
+const parquetRowGroupSize = 50000
+
+// ExportEvents streams every event selected by the filter to fn, ignoring the filter's limit.
+// Rows are read through a server-side cursor, so exports of any size run in constant memory.
+// Buffered events are flushed first so they are exported too. It returns the number of events
+// passed to fn.
+func (dem *DatabaseEventManager) ExportEvents(ctx context.Context, filter commons.EventFilter, fn func(commons.Event) error) (int, error) {
+	clause, args, err := eventFilterClause(filter)
+	if err != nil {
+		return 0, err
+	}
+	if err := dem.SaveEvents(); err != nil {
+		log.Printf("Error flushing events before export: %v", err)
+	}
+
+	// Cursors only live inside a transaction.
+	tx, err := dem.Db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return 0, fmt.Errorf("error starting export transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DECLARE event_export NO SCROLL CURSOR FOR SELECT "+eventColumns+" FROM user_events"+clause, args...); err != nil {
+		return 0, fmt.Errorf("error opening export cursor: %w", err)
+	}
+
+	var exported int
+	for {
+		rows, err := tx.QueryContext(ctx, "FETCH "+strconv.Itoa(exportFetchSize)+" FROM event_export")
+		if err != nil {
+			return exported, fmt.Errorf("error fetching events: %w", err)
+		}
+		fetched := 0
+		for rows.Next() {
+			var ev commons.Event
+			if err := rows.Scan(&ev.ID, &ev.EventType, &ev.UserID, &ev.TargetID, &ev.Comment, &ev.CreatedAt); err != nil {
+				rows.Close()
+				return exported, fmt.Errorf("error scanning event: %w", err)
+			}
+			fetched++
+			if err := fn(ev); err != nil {
+				rows.Close()
+				return exported, err
+			}
+			exported++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return exported, err
+		}
+		if fetched < exportFetchSize {
+			return exported, nil
+		}
+	}
+}
+
+// EventWriter writes exported events in one of the export formats. Close must be called to
+// complete the output.
+type EventWriter interface {
+	Write(ev commons.Event) error
+	Close() error
+}
+
+// NewEventWriter returns a writer of the given format.
+func NewEventWriter(format string, w io.Writer) (EventWriter, error) {
+	switch format {
+	case ExportNDJSON:
+		return &ndjsonEventWriter{encoder: json.NewEncoder(w)}, nil
+	case ExportCSV:
+		return &csvEventWriter{writer: csv.NewWriter(w)}, nil
+	case ExportParquet:
+		return &parquetEventWriter{writer: parquet.NewGenericWriter[parquetEvent](w, parquet.Compression(&parquet.Snappy))}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExportFormat, format)
+	}
+}
+
+// ExportContentType returns the media type and file extension of an export format.
+func ExportContentType(format string) (string, string) {
+	switch format {
+	case ExportCSV:
+		return "text/csv", "csv"
+	case ExportParquet:
+		return "application/vnd.apache.parquet", "parquet"
+	default:
+		return "application/x-ndjson", "ndjson"
+	}
+}
+
+type ndjsonEventWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonEventWriter) Write(ev commons.Event) error { return w.encoder.Encode(ev) }
+
+func (w *ndjsonEventWriter) Close() error { return nil }
+
+var csvHeader = []string{"id", "event_type", "user_id", "target_id", "comment", "created_at"}
+
+type csvEventWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvEventWriter) Write(ev commons.Event) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.writer.Write([]string{
+		strconv.Itoa(ev.ID), ev.EventType, ev.UserID, ev.TargetID, ev.Comment,
+		ev.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (w *csvEventWriter) Close() error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// parquetEvent is the Parquet schema of an exported event.
+type parquetEvent struct {
+	ID        int64     `parquet:"id"`
+	EventType string    `parquet:"event_type,dict"`
+	UserID    string    `parquet:"user_id"`
+	TargetID  string    `parquet:"target_id"`
+	Comment   string    `parquet:"comment"`
+	CreatedAt time.Time `parquet:"created_at,timestamp(microsecond)"`
+}
+
+type parquetEventWriter struct {
+	writer   *parquet.GenericWriter[parquetEvent]
+	buffered int
+}
+
+func (w *parquetEventWriter) Write(ev commons.Event) error {
+	_, err := w.writer.Write([]parquetEvent{{
+		ID:        int64(ev.ID),
+		EventType: ev.EventType,
+		UserID:    ev.UserID,
+		TargetID:  ev.TargetID,
+		Comment:   ev.Comment,
+		CreatedAt: ev.CreatedAt.UTC(),
+	}})
+	if err != nil {
+		return err
+	}
+	// Row groups are held in memory until flushed; bound them.
+	if w.buffered++; w.buffered >= parquetRowGroupSize {
+		w.buffered = 0
+		return w.writer.Flush()
+	}
+	return nil
+}
+
+func (w *parquetEventWriter) Close() error { return w.writer.Close() }
+
+// ExportEventsHandler is an HTTP handler that streams the events selected by the query (see
+// ParseEventFilter; the default order is oldest first) to admins. ?format= is ndjson (default),
+// csv or parquet. An error after the response has started is reported in the X-Export-Error
+// trailer, since the status can no longer change.
+func (dem *DatabaseEventManager) ExportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	exportEventsHandler(dem, w, r)
+}
+
+// exportEventsHandler implements ExportEventsHandler for any EventManager.
+func exportEventsHandler(manager EventManager, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	if !utils.IsAdmin(clientID) {
+		http.Error(w, "Only admins can export events", http.StatusForbidden)
+		return
+	}
+
+	filter, err := ParseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("order") == "" {
+		filter.Ascending = true
+	}
+	// Exports continue from a cursor, but always run to the end.
+	filter.Limit = 0
+	if filter.Cursor != "" {
+		if _, _, err := decodeEventCursor(filter.Cursor); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportNDJSON
+	}
+	writer, err := NewEventWriter(format, w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType, extension := ExportContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="events.`+extension+`"`)
+	w.Header().Set("Trailer", "X-Export-Error")
+
+	exported, err := manager.ExportEvents(r.Context(), filter, writer.Write)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Printf("Event export failed after %d events: %v", exported, err)
+		w.Header().Set("X-Export-Error", err.Error())
+		return
+	}
+	log.Printf("Exported %d events as %s", exported, format)
+}
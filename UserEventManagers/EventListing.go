@@ -0,0 +1,236 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"GOLA/utils"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Page sizes of event listings.
+const (
+	DefaultEventPageSize = 100
+	MaxEventPageSize     = 1000
+)
+
+var (
+	// ErrInvalidEventFilter is returned for malformed filters and cursors.
+	ErrInvalidEventFilter = errors.New("invalid event filter")
+)
+
+// eventColumns are the columns scanned by scanEvent.
+const eventColumns = `id, event_type, user_id, target_id, COALESCE(comment, ''), created_at`
+
+// eventFilterClause returns the WHERE and ORDER BY clauses selecting the events of the filter,
+// including the position of its cursor, with their arguments.
+func eventFilterClause(filter commons.EventFilter) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = "+arg(filter.TargetID))
+	}
+	if len(filter.EventTypes) > 0 {
+		conditions = append(conditions, "event_type = ANY("+arg(pq.Array(filter.EventTypes))+")")
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.To))
+	}
+	order, after := "DESC", "<"
+	if filter.Ascending {
+		order, after = "ASC", ">"
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeEventCursor(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) %s (%s, %s)", after, arg(createdAt), arg(id)))
+	}
+
+	var clause strings.Builder
+	if len(conditions) > 0 {
+		clause.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+	clause.WriteString(fmt.Sprintf(" ORDER BY created_at %s, id %s", order, order))
+	return clause.String(), args, nil
+}
+
+// ListEvents returns a page of the events selected by the filter. Events still waiting in the
+// write-behind buffer are not listed until they are flushed.
+func (dem *DatabaseEventManager) ListEvents(ctx context.Context, filter commons.EventFilter) (*commons.EventPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultEventPageSize
+	}
+	if limit > MaxEventPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidEventFilter, MaxEventPageSize)
+	}
+	clause, args, err := eventFilterClause(filter)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, limit+1)
+
+	ctx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+	rows, err := dem.Db.QueryContext(ctx,
+		"SELECT "+eventColumns+" FROM user_events"+clause+" LIMIT $"+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying events: %w", err)
+	}
+	defer rows.Close()
+
+	page := &commons.EventPage{Events: []commons.Event{}}
+	for rows.Next() {
+		var ev commons.Event
+		if err := rows.Scan(&ev.ID, &ev.EventType, &ev.UserID, &ev.TargetID, &ev.Comment, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning event: %w", err)
+		}
+		page.Events = append(page.Events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// One row more than the limit was fetched to know whether another page follows.
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		last := page.Events[limit-1]
+		page.NextCursor = encodeEventCursor(last.CreatedAt, int64(last.ID))
+	}
+	return page, nil
+}
+
+// ParseEventFilter reads an event filter from the query of a request: user_id, target_id,
+// event_type (repeated or comma separated), from and to (RFC 3339), order (asc or desc),
+// cursor and limit.
+func ParseEventFilter(r *http.Request) (commons.EventFilter, error) {
+	params := r.URL.Query()
+	filter := commons.EventFilter{
+		UserID:   params.Get("user_id"),
+		TargetID: params.Get("target_id"),
+		Cursor:   params.Get("cursor"),
+	}
+	for _, value := range params["event_type"] {
+		for _, eventType := range strings.Split(value, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				filter.EventTypes = append(filter.EventTypes, eventType)
+			}
+		}
+	}
+	for name, field := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%w: %s must be an RFC 3339 time", ErrInvalidEventFilter, name)
+			}
+			*field = t
+		}
+	}
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, fmt.Errorf("%w: order must be asc or desc", ErrInvalidEventFilter)
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("%w: invalid limit", ErrInvalidEventFilter)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// ListEventsHandler is an HTTP handler that lists events for admins and analytics clients;
+// see ParseEventFilter for the query parameters.
+func (dem *DatabaseEventManager) ListEventsHandler(w http.ResponseWriter, r *http.Request) {
+	listEventsHandler(dem, w, r)
+}
+
+// listEventsHandler implements ListEventsHandler for any EventManager.
+func listEventsHandler(manager EventManager, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	if !utils.IsAdmin(clientID) {
+		http.Error(w, "Only admins can list events", http.StatusForbidden)
+		return
+	}
+
+	filter, err := ParseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := manager.ListEvents(r.Context(), filter)
+	if errors.Is(err, ErrInvalidEventFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing events: %v", err)
+		http.Error(w, "Failed to list events", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// encodeEventCursor and decodeEventCursor turn the position of the last event of a page into
+// an opaque cursor and back.
+func encodeEventCursor(createdAt time.Time, id int64) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeEventCursor(cursor string) (time.Time, int64, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidEventFilter)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	eventID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, unixNano).UTC(), eventID, nil
+}
@@ -2,31 +2,59 @@ package UserEventManagers
 
 import (
 	"GOLA/commons"
+	"context"
 	"fmt"
 	"net/http"
 )
 
-// EventManager interface for handling user events.
+// EventManager interface for handling user events. Implementations must be safe for
+// concurrent use; methods taking a context stop waiting once it is done.
 type EventManager interface {
 	Initialize()
+	// SaveEvents persists every buffered event before returning.
 	SaveEvents() error
-	AddLike(event *commons.EventInputModel) (addedEvent *commons.Event)
-	AddDislike(event *commons.EventInputModel) (addedEvent *commons.Event)
-	AddView(event *commons.EventInputModel) (addedEvent *commons.Event)
-	AddComment(event *commons.EventInputModel) (addedEvent *commons.Event)
-	ListEvents(w http.ResponseWriter, r *http.Request)
+	// Close persists buffered events and stops background work; call it on shutdown.
+	Close() error
+	AddLike(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event)
+	AddDislike(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event)
+	// RemoveLike and RemoveDislike retract the user's reaction and report whether there was one.
+	RemoveLike(ctx context.Context, event *commons.EventInputModel) (bool, error)
+	RemoveDislike(ctx context.Context, event *commons.EventInputModel) (bool, error)
+	// GetReactions returns the user's current reactions to a target.
+	GetReactions(ctx context.Context, userID, targetID string) ([]string, error)
 
-	// GetStats fetches the aggregated stats (likes, dislikes, views, comments) for a given target.
-	GetStats(targetID string) (*commons.EventStats, error)
+	// AddEvent validates and records an event of any registered type. Errors wrap
+	// ErrUnknownEventType or ErrInvalidEvent when the input is at fault.
+	AddEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (*commons.Event, error)
+	// RemoveEvent retracts a reaction of any registered type and reports whether there was one.
+	RemoveEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (bool, error)
+	// EventTypes returns the registered event types.
+	EventTypes() []EventTypeDefinition
+	AddView(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event)
+	AddComment(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event)
+	// ListEvents returns a page of the events selected by the filter; errors wrap
+	// ErrInvalidEventFilter when the filter is at fault.
+	ListEvents(ctx context.Context, filter commons.EventFilter) (*commons.EventPage, error)
+	// ListEventsHandler is an HTTP handler that lists events for admins.
+	ListEventsHandler(w http.ResponseWriter, r *http.Request)
+	// ExportEvents streams every event selected by the filter to fn and returns how many it
+	// passed on.
+	ExportEvents(ctx context.Context, filter commons.EventFilter, fn func(commons.Event) error) (int, error)
+	// ExportEventsHandler is an HTTP handler that streams events to admins as a file.
+	ExportEventsHandler(w http.ResponseWriter, r *http.Request)
+
+	// GetStats fetches the counts of every registered event type for a given target.
+	GetStats(ctx context.Context, targetID string) (*commons.EventStats, error)
 	// GetStatsHandler is an HTTP handler that writes the stats for a given target.
 	GetStatsHandler(w http.ResponseWriter, r *http.Request)
 
-	// GetComments fetches all comment events for a given target.
-	GetComments(targetID string) ([]commons.Event, error)
-	// GetCommentsHandler is an HTTP handler that writes the comments for a given target.
-	GetCommentsHandler(w http.ResponseWriter, r *http.Request)
-
+	// LazySave starts the background persistence of buffered events.
 	LazySave()
+	// ReconcileStats repairs the cached stats from the event store; run it periodically.
+	ReconcileStats(ctx context.Context) error
+	// ApplyRetention moves events that outlived the retention of their type into the archive;
+	// run it periodically.
+	ApplyRetention(ctx context.Context, archive EventArchive) error
 }
 
 // Factory method to create the appropriate EventManager.
@@ -34,6 +62,8 @@ func GetEventManager(sourceType string) (EventManager, error) {
 	switch sourceType {
 	case "postgresDb":
 		return &DatabaseEventManager{}, nil
+	case "memory":
+		return &MemoryEventManager{}, nil
 	default:
 		return nil, fmt.Errorf("unsupported event manager type: %s", sourceType)
 	}
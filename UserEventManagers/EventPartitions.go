@@ -0,0 +1,179 @@
+package UserEventManagers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// user_events is partitioned by month of created_at, so the events of a month live in their
+// own table (user_events_y2024m01 and so on). Retention deletes then only touch the months
+// they expire, and months left empty are dropped. A default partition catches events outside
+// every monthly partition.
+
+// partitionMonthsAhead is the number of months beyond the current one that get a partition
+// before any event needs it.
+const partitionMonthsAhead = 3
+
+// partitionLayout is the name of the partition of a month, as a time layout.
+const partitionLayout = "user_events_y2006m01"
+
+const eventColumnsDDL = `
+		event_type VARCHAR(50) NOT NULL,
+		user_id VARCHAR(100) NOT NULL,
+		target_id VARCHAR(100) NOT NULL,
+		comment TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (id, created_at)
+	) PARTITION BY RANGE (created_at)`
+
+// eventsTablePartitioned reports whether user_events is partitioned. A missing table counts as
+// partitioned, since Initialize creates it that way.
+func eventsTablePartitioned(ctx context.Context, db *sql.DB) (bool, error) {
+	var kind string
+	err := db.QueryRowContext(ctx, `SELECT relkind FROM pg_class WHERE oid = to_regclass('user_events')`).Scan(&kind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return kind != "r", nil
+}
+
+// PartitionEventsTable turns an unpartitioned user_events table, as created before events were
+// partitioned, into a partitioned one. The rows are copied in one transaction that locks the
+// table, so run it with scripts/PartitionEvents when the service can afford the pause.
+func PartitionEventsTable(db *sql.DB) error {
+	partitioned, err := eventsTablePartitioned(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	if partitioned {
+		log.Println("user_events is already partitioned")
+		return nil
+	}
+	log.Println("Partitioning user_events by month")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The old table gives up its name, its constraint and index names and its id sequence.
+	steps := []string{
+		`LOCK TABLE user_events IN ACCESS EXCLUSIVE MODE`,
+		`ALTER TABLE user_events RENAME TO user_events_unpartitioned`,
+		`ALTER TABLE user_events_unpartitioned RENAME CONSTRAINT user_events_pkey TO user_events_unpartitioned_pkey`,
+		`DROP INDEX IF EXISTS user_events_target_idx, user_events_user_idx, user_events_created_idx`,
+		`ALTER SEQUENCE user_events_id_seq OWNED BY NONE`,
+		`CREATE TABLE user_events (
+		id INTEGER NOT NULL DEFAULT nextval('user_events_id_seq'),` + eventColumnsDDL,
+		`ALTER SEQUENCE user_events_id_seq OWNED BY user_events.id`,
+		`CREATE TABLE user_events_default PARTITION OF user_events DEFAULT`,
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			return fmt.Errorf("error partitioning user_events: %w", err)
+		}
+	}
+
+	var oldest sql.NullTime
+	if err := tx.QueryRow(`SELECT MIN(created_at) FROM user_events_unpartitioned`).Scan(&oldest); err != nil {
+		return err
+	}
+	if oldest.Valid {
+		if err := createMonthlyPartitions(context.Background(), tx, oldest.Time, time.Now()); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec(`
+		INSERT INTO user_events (id, event_type, user_id, target_id, comment, created_at)
+		SELECT id, event_type, user_id, target_id, comment, created_at FROM user_events_unpartitioned
+	`)
+	if err != nil {
+		return fmt.Errorf("error copying events into partitions: %w", err)
+	}
+	if _, err := tx.Exec(`DROP TABLE user_events_unpartitioned`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	copied, _ := result.RowsAffected()
+	log.Printf("Partitioned user_events: %d events copied", copied)
+	return nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// createMonthlyPartitions makes sure every month from the month of from up to partitionMonthsAhead
+// months after the month of until has a partition.
+func createMonthlyPartitions(ctx context.Context, db execer, from, until time.Time) error {
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(until.Year(), until.Month()+partitionMonthsAhead, 1, 0, 0, 0, 0, time.UTC)
+	for ; !month.After(last); month = month.AddDate(0, 1, 0) {
+		query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF user_events FOR VALUES FROM ('%s') TO ('%s')`,
+			month.Format(partitionLayout), month.Format("2006-01-02"), month.AddDate(0, 1, 0).Format("2006-01-02"))
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("error creating partition %s: %w", month.Format(partitionLayout), err)
+		}
+	}
+	return nil
+}
+
+// dropEmptyPartitions drops the partitions of past months that hold no events anymore.
+func (dem *DatabaseEventManager) dropEmptyPartitions(ctx context.Context) (int, error) {
+	rows, err := dem.Db.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'user_events'::regclass
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		partitions = append(partitions, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var dropped int
+	for _, name := range partitions {
+		month, err := time.Parse(partitionLayout, name)
+		if err != nil || !month.Before(currentMonth) {
+			// The default partition, or a month that can still receive events.
+			continue
+		}
+		var empty bool
+		if err := dem.Db.QueryRowContext(ctx, `SELECT NOT EXISTS (SELECT 1 FROM `+name+`)`).Scan(&empty); err != nil {
+			return dropped, err
+		}
+		if !empty {
+			continue
+		}
+		if _, err := dem.Db.ExecContext(ctx, `DROP TABLE `+name); err != nil {
+			return dropped, fmt.Errorf("error dropping partition %s: %w", name, err)
+		}
+		dropped++
+	}
+	return dropped, nil
+}
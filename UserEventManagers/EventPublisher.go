@@ -0,0 +1,39 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"context"
+	"fmt"
+)
+
+// EventPublisher records user events asynchronously: events are handed to a queue, such as
+// Kafka, from which a consumer applies them to the EventManager. The returned ID identifies
+// the published event; applying it again under the same ID has no effect.
+type EventPublisher interface {
+	// PublishEvent queues an event of any registered type, as AddEvent records it.
+	PublishEvent(ctx context.Context, eventType string, input *commons.EventInputModel) (string, error)
+	// PublishRemoval queues the retraction of a reaction, as RemoveEvent applies it.
+	PublishRemoval(ctx context.Context, eventType string, input *commons.EventInputModel) (string, error)
+}
+
+// CheckEvent validates an event against the types registered with the manager, so that it
+// can be rejected before it is published. With removal set, it checks the retraction of a
+// reaction instead. Errors wrap ErrUnknownEventType, ErrInvalidEvent or ErrEventNotRemovable.
+func CheckEvent(manager EventManager, eventType string, input *commons.EventInputModel, removal bool) error {
+	for _, d := range manager.EventTypes() {
+		if d.Name != eventType {
+			continue
+		}
+		if !removal {
+			return d.Validate(input)
+		}
+		if !d.Reaction {
+			return fmt.Errorf("%w: %s", ErrEventNotRemovable, eventType)
+		}
+		if input.UserID == "" || input.TargetID == "" {
+			return fmt.Errorf("%w: user and target are required", ErrInvalidEvent)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+}
@@ -0,0 +1,162 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// archiveBatchSize is the number of events per archive file.
+const archiveBatchSize = 50000
+
+// archivePrefix is where archive files are stored, followed by <event type>/<first event>.
+const archivePrefix = "archives/user_events/"
+
+// EventArchive stores archived events. The image store satisfies it, so archives live next to
+// the images.
+type EventArchive interface {
+	UploadImage(key string, data []byte) error
+}
+
+// ApplyRetention archives and deletes the events that outlived the retention of their type,
+// drops the monthly partitions this leaves empty and creates the partitions of the coming
+// months. Each batch of expired events is written to the archive as gzipped NDJSON before it
+// is deleted; a batch whose delete fails is archived again under the same key on the next run.
+// Counters keep counting deleted events through archived_event_counts, and unique viewers
+// keep counting the viewers of deleted views through archived_viewers.
+func (dem *DatabaseEventManager) ApplyRetention(ctx context.Context, archive EventArchive) error {
+	// Until scripts/PartitionEvents has run, events are deleted from the unpartitioned table.
+	partitioned, err := eventsTablePartitioned(ctx, dem.Db)
+	if err != nil {
+		return err
+	}
+	if partitioned {
+		if err := createMonthlyPartitions(ctx, dem.Db, time.Now(), time.Now()); err != nil {
+			return err
+		}
+	}
+	for _, d := range dem.Types.All() {
+		if d.RetentionDays == 0 {
+			continue
+		}
+		types := []string{d.Name}
+		if d.RemovalEvent != "" {
+			types = append(types, d.RemovalEvent)
+		}
+		cutoff := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -d.RetentionDays)
+		archived, err := dem.archiveEvents(ctx, archive, d.Name, types, cutoff)
+		if err != nil {
+			return fmt.Errorf("error archiving %s events: %w", d.Name, err)
+		}
+		if archived > 0 {
+			log.Printf("Archived %d %s events older than %s", archived, d.Name, cutoff.Format("2006-01-02"))
+		}
+	}
+	if !partitioned {
+		return nil
+	}
+	dropped, err := dem.dropEmptyPartitions(ctx)
+	if err != nil {
+		return fmt.Errorf("error dropping empty partitions: %w", err)
+	}
+	if dropped > 0 {
+		log.Printf("Dropped %d empty event partitions", dropped)
+	}
+	return nil
+}
+
+// archiveEvents moves the events of the given types created before cutoff into the archive,
+// oldest first, and returns how many it moved.
+func (dem *DatabaseEventManager) archiveEvents(ctx context.Context, archive EventArchive, name string, types []string, cutoff time.Time) (int, error) {
+	var total int
+	for {
+		events, err := dem.expiredEvents(ctx, types, cutoff)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			return total, nil
+		}
+
+		if err := archiveBatch(archive, name, events); err != nil {
+			return total, err
+		}
+
+		// The batch is every expired event up to the last one archived.
+		last := events[len(events)-1]
+		_, err = dem.Db.ExecContext(ctx, `
+			WITH deleted AS (
+				DELETE FROM user_events
+				WHERE event_type = ANY($1) AND created_at < $2 AND created_at <= $3 AND (created_at, id) <= ($3, $4)
+				RETURNING target_id, event_type, user_id
+			), viewers AS (
+				INSERT INTO archived_viewers (target_id, user_id)
+				SELECT DISTINCT target_id, user_id FROM deleted WHERE event_type = $5
+				ON CONFLICT (target_id, user_id) DO NOTHING
+			)
+			INSERT INTO archived_event_counts (target_id, event_type, count)
+			SELECT target_id, event_type, COUNT(*) FROM deleted GROUP BY target_id, event_type
+			ON CONFLICT (target_id, event_type) DO UPDATE SET count = archived_event_counts.count + EXCLUDED.count
+		`, pq.Array(types), cutoff, last.CreatedAt, last.ID, EventTypeView)
+		if err != nil {
+			return total, fmt.Errorf("error deleting archived events: %w", err)
+		}
+		total += len(events)
+		if len(events) < archiveBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// archiveBatch writes a batch of events, oldest first, to the archive as gzipped NDJSON under
+// a key derived from the event type and the first event.
+func archiveBatch(archive EventArchive, name string, events []commons.Event) error {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	writer, _ := NewEventWriter(ExportNDJSON, gz)
+	for _, ev := range events {
+		if err := writer.Write(ev); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	first := events[0]
+	key := fmt.Sprintf("%s%s/%s-%d.ndjson.gz", archivePrefix, name, first.CreatedAt.UTC().Format("20060102T150405"), first.ID)
+	if err := archive.UploadImage(key, compressed.Bytes()); err != nil {
+		return fmt.Errorf("error uploading %s: %w", key, err)
+	}
+	return nil
+}
+
+// expiredEvents returns the oldest batch of events of the given types created before cutoff.
+func (dem *DatabaseEventManager) expiredEvents(ctx context.Context, types []string, cutoff time.Time) ([]commons.Event, error) {
+	rows, err := dem.Db.QueryContext(ctx, `
+		SELECT `+eventColumns+`
+		FROM user_events
+		WHERE event_type = ANY($1) AND created_at < $2
+		ORDER BY created_at, id
+		LIMIT $3
+	`, pq.Array(types), cutoff, archiveBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("error querying expired events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []commons.Event
+	for rows.Next() {
+		var ev commons.Event
+		if err := rows.Scan(&ev.ID, &ev.EventType, &ev.UserID, &ev.TargetID, &ev.Comment, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
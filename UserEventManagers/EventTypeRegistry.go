@@ -0,0 +1,234 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnknownEventType is returned for event types that are not registered.
+	ErrUnknownEventType = errors.New("unknown event type")
+	// ErrInvalidEvent is returned when an event breaks the rules of its type.
+	ErrInvalidEvent = errors.New("invalid event")
+	// ErrEventNotRemovable is returned when removing an event of a type that is not a reaction.
+	ErrEventNotRemovable = errors.New("event type cannot be removed")
+)
+
+// Comment policies of an event type.
+const (
+	CommentForbidden = "forbidden"
+	CommentOptional  = "optional"
+	CommentRequired  = "required"
+)
+
+// Built-in event types. They are always registered; a configuration file may only add types.
+const (
+	EventTypeView    = "user-view"
+	EventTypeComment = "user-comment"
+)
+
+// EventTypeDefinition describes one kind of user event.
+//
+// Reactions (Reaction set) are per-(user, target) state that can be removed again. Reactions
+// sharing an ExclusiveGroup replace each other, as like and dislike do; a reaction without a
+// group can be combined with any other. Other types are plain counters: every call is recorded.
+type EventTypeDefinition struct {
+	Name  string `json:"name"`
+	Label string `json:"label,omitempty"`
+	// Reaction makes the type per-user state instead of a counter.
+	Reaction bool `json:"reaction,omitempty"`
+	// ExclusiveGroup groups mutually exclusive reactions.
+	ExclusiveGroup string `json:"exclusive_group,omitempty"`
+	// RemovalEvent is the event recorded when a reaction is removed; defaults to "<name>-removed".
+	RemovalEvent string `json:"removal_event,omitempty"`
+	// Comment is the comment policy: forbidden (default), optional or required.
+	Comment string `json:"comment,omitempty"`
+	// MaxCommentLength limits comments, in characters; 0 means no limit.
+	MaxCommentLength int `json:"max_comment_length,omitempty"`
+	// RetentionDays is how long events of the type are kept before they are archived; 0 keeps
+	// them forever. The removal events of a reaction follow the reaction's retention.
+	RetentionDays int `json:"retention_days,omitempty"`
+}
+
+// reactionGroup is the key under which a reaction is stored: its exclusive group, or the type
+// itself for reactions that combine with everything.
+func (d EventTypeDefinition) reactionGroup() string {
+	if d.ExclusiveGroup != "" {
+		return d.ExclusiveGroup
+	}
+	return d.Name
+}
+
+// Validate checks an event input against the rules of the type.
+func (d EventTypeDefinition) Validate(input *commons.EventInputModel) error {
+	if input.UserID == "" || input.TargetID == "" {
+		return fmt.Errorf("%w: user and target are required", ErrInvalidEvent)
+	}
+	switch d.Comment {
+	case CommentRequired:
+		if strings.TrimSpace(input.Comment) == "" {
+			return fmt.Errorf("%w: %s requires a comment", ErrInvalidEvent, d.Name)
+		}
+	case CommentOptional:
+	default:
+		if input.Comment != "" {
+			return fmt.Errorf("%w: %s does not take a comment", ErrInvalidEvent, d.Name)
+		}
+	}
+	if d.MaxCommentLength > 0 && utf8.RuneCountInString(input.Comment) > d.MaxCommentLength {
+		return fmt.Errorf("%w: comment longer than %d characters", ErrInvalidEvent, d.MaxCommentLength)
+	}
+	return nil
+}
+
+// DefaultEventTypes are the event types known without any configuration.
+func DefaultEventTypes() []EventTypeDefinition {
+	return []EventTypeDefinition{
+		{Name: EventTypeLike, Label: "Like", Reaction: true, ExclusiveGroup: "vote", RemovalEvent: EventTypeUnlike},
+		{Name: EventTypeDislike, Label: "Dislike", Reaction: true, ExclusiveGroup: "vote", RemovalEvent: EventTypeUndislike},
+		{Name: EventTypeView, Label: "View", RetentionDays: 90},
+		{Name: EventTypeComment, Label: "Comment", Comment: CommentRequired, MaxCommentLength: 5000},
+	}
+}
+
+// EventTypeRegistry holds the registered event types in registration order.
+type EventTypeRegistry struct {
+	types map[string]EventTypeDefinition
+	order []string
+}
+
+// NewEventTypeRegistry builds a registry from definitions, rejecting duplicates and
+// inconsistent rules.
+func NewEventTypeRegistry(definitions []EventTypeDefinition) (*EventTypeRegistry, error) {
+	registry := &EventTypeRegistry{types: map[string]EventTypeDefinition{}}
+	removals := map[string]bool{}
+	for _, d := range definitions {
+		if d.Name == "" || len(d.Name) > 50 {
+			return nil, fmt.Errorf("event type name must be 1-50 characters: %q", d.Name)
+		}
+		if _, exists := registry.types[d.Name]; exists {
+			return nil, fmt.Errorf("event type %q registered twice", d.Name)
+		}
+		switch d.Comment {
+		case "":
+			d.Comment = CommentForbidden
+		case CommentForbidden, CommentOptional, CommentRequired:
+		default:
+			return nil, fmt.Errorf("event type %q: unknown comment policy %q", d.Name, d.Comment)
+		}
+		if d.RetentionDays < 0 {
+			return nil, fmt.Errorf("event type %q: negative retention", d.Name)
+		}
+		if d.ExclusiveGroup != "" && !d.Reaction {
+			return nil, fmt.Errorf("event type %q: only reactions can have an exclusive group", d.Name)
+		}
+		if d.Reaction {
+			if d.RemovalEvent == "" {
+				d.RemovalEvent = d.Name + "-removed"
+			}
+			removals[d.RemovalEvent] = true
+		} else {
+			d.RemovalEvent = ""
+		}
+		registry.types[d.Name] = d
+		registry.order = append(registry.order, d.Name)
+	}
+	for removal := range removals {
+		if _, clash := registry.types[removal]; clash {
+			return nil, fmt.Errorf("removal event %q clashes with a registered event type", removal)
+		}
+	}
+	return registry, nil
+}
+
+// LoadEventTypeRegistry registers the default event types plus those of the JSON file at
+// path, a list of event type definitions. An empty path registers only the defaults.
+func LoadEventTypeRegistry(path string) (*EventTypeRegistry, error) {
+	definitions := DefaultEventTypes()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading event types config: %w", err)
+		}
+		var configured []EventTypeDefinition
+		if err := json.Unmarshal(data, &configured); err != nil {
+			return nil, fmt.Errorf("error parsing event types config: %w", err)
+		}
+		definitions = append(definitions, configured...)
+	}
+	return NewEventTypeRegistry(definitions)
+}
+
+// ParseRetention parses retention overrides such as "user-view=30d,user-like=forever" into
+// days per event type. Days may be given with or without the d suffix.
+func ParseRetention(value string) (map[string]int, error) {
+	overrides := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention %q", pair)
+		}
+		name, raw = strings.TrimSpace(name), strings.TrimSpace(raw)
+		if raw == "forever" {
+			overrides[name] = 0
+			continue
+		}
+		days, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid retention of %s: %q", name, raw)
+		}
+		overrides[name] = days
+	}
+	return overrides, nil
+}
+
+// SetRetention overrides the retention of registered event types, built-in ones included.
+func (r *EventTypeRegistry) SetRetention(overrides map[string]int) error {
+	for name, days := range overrides {
+		d, ok := r.types[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownEventType, name)
+		}
+		d.RetentionDays = days
+		r.types[name] = d
+	}
+	return nil
+}
+
+// Get returns the definition of an event type.
+func (r *EventTypeRegistry) Get(name string) (EventTypeDefinition, error) {
+	d, ok := r.types[name]
+	if !ok {
+		return EventTypeDefinition{}, fmt.Errorf("%w: %s", ErrUnknownEventType, name)
+	}
+	return d, nil
+}
+
+// All returns every registered event type in registration order.
+func (r *EventTypeRegistry) All() []EventTypeDefinition {
+	all := make([]EventTypeDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.types[name])
+	}
+	return all
+}
+
+// Names returns the names of the registered event types that match filter.
+func (r *EventTypeRegistry) Names(filter func(EventTypeDefinition) bool) []string {
+	var names []string
+	for _, name := range r.order {
+		if filter(r.types[name]) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,448 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryEventManager implements the EventManager interface in process memory, for tests and
+// local runs without PostgreSQL or Redis. It follows the semantics of DatabaseEventManager:
+// events are listed by creation time and ID, reactions replace each other within their group,
+// repeated views within the de-duplication window count once and retention archives expired
+// events while their counts are kept.
+//
+// Two differences remain: events get their ID right away, since nothing is buffered, and
+// comments are counted from the user-comment events, since there is no comment store to count
+// them from. Managers that read user_events directly, such as rollups and trending, see none
+// of its events. Everything is lost when the process exits.
+type MemoryEventManager struct {
+	// Types is the event type registry; Initialize loads it from EVENT_TYPES_CONFIG when nil.
+	Types *EventTypeRegistry
+	// viewDedupWindow is the time during which repeated views of a user are not recorded.
+	viewDedupWindow time.Duration
+
+	mu sync.RWMutex
+	// events are kept in the order they were recorded, which is also the order of their IDs.
+	events    []commons.Event
+	lastID    int
+	reactions map[memoryReactionKey]string
+	// lastViews holds the time of the last recorded view per target and user.
+	lastViews map[[2]string]time.Time
+	// archived counts the events retention removed, per target and event type.
+	archived map[string]map[string]int
+	// archivedViewers holds the viewers of the views retention removed, per target.
+	archivedViewers map[string]map[string]bool
+}
+
+type memoryReactionKey struct {
+	userID, targetID, group string
+}
+
+// Initialize reads the event types and the view de-duplication window from the environment,
+// like DatabaseEventManager does, and resets the stored events.
+func (mem *MemoryEventManager) Initialize() {
+	var err error
+	if mem.viewDedupWindow, err = viewDedupWindowFromEnv(); err != nil {
+		log.Fatalf("Invalid VIEW_DEDUP_WINDOW: %v", err)
+	}
+	if mem.Types, err = eventTypesFromEnv(mem.Types); err != nil {
+		log.Fatalf("Invalid event types configuration: %v", err)
+	}
+
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	mem.events = nil
+	mem.lastID = 0
+	mem.reactions = map[memoryReactionKey]string{}
+	mem.lastViews = map[[2]string]time.Time{}
+	mem.archived = map[string]map[string]int{}
+	mem.archivedViewers = map[string]map[string]bool{}
+	log.Println("User events are kept in memory")
+}
+
+// SaveEvents does nothing: events are stored as soon as they are added.
+func (mem *MemoryEventManager) SaveEvents() error { return nil }
+
+// Close does nothing; the events are discarded with the process.
+func (mem *MemoryEventManager) Close() error { return nil }
+
+// LazySave does nothing: there is no buffer to persist.
+func (mem *MemoryEventManager) LazySave() {}
+
+// ReconcileStats does nothing: stats are computed from the stored events on every read.
+func (mem *MemoryEventManager) ReconcileStats(ctx context.Context) error { return nil }
+
+// AddEvent records an event of any registered type after validating it against the rules of
+// the type. Reactions replace the user's previous reaction in their exclusive group; other
+// types are counted every time.
+func (mem *MemoryEventManager) AddEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (*commons.Event, error) {
+	d, err := mem.Types.Get(eventType)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Validate(event); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	now := memoryNow()
+	if d.Reaction {
+		key := memoryReactionKey{event.UserID, event.TargetID, d.reactionGroup()}
+		if mem.reactions[key] == d.Name {
+			// Already the current reaction.
+			return &commons.Event{EventType: d.Name, UserID: event.UserID, TargetID: event.TargetID, CreatedAt: now}, nil
+		}
+		mem.reactions[key] = d.Name
+	}
+	if d.Name == EventTypeView && mem.viewDedupWindow > 0 {
+		viewer := [2]string{event.TargetID, event.UserID}
+		if last, ok := mem.lastViews[viewer]; ok && now.Sub(last) < mem.viewDedupWindow {
+			// A repeated view within the window is accepted but not counted again.
+			return &commons.Event{EventType: d.Name, UserID: event.UserID, TargetID: event.TargetID, CreatedAt: now}, nil
+		}
+		mem.lastViews[viewer] = now
+	}
+	return mem.recordEvent(d.Name, event, now), nil
+}
+
+// recordEvent appends an event; the caller holds the lock.
+func (mem *MemoryEventManager) recordEvent(eventType string, event *commons.EventInputModel, now time.Time) *commons.Event {
+	mem.lastID++
+	ev := commons.Event{
+		ID:        mem.lastID,
+		EventType: eventType,
+		UserID:    event.UserID,
+		TargetID:  event.TargetID,
+		Comment:   event.Comment,
+		CreatedAt: now,
+	}
+	mem.events = append(mem.events, ev)
+	return &ev
+}
+
+// memoryNow returns the current time at the precision PostgreSQL stores, so cursors and
+// exports look the same for both managers.
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// addEvent is AddEvent for the fixed-type methods, which report failures by returning nil.
+func (mem *MemoryEventManager) addEvent(ctx context.Context, eventType string, event *commons.EventInputModel) *commons.Event {
+	ev, err := mem.AddEvent(ctx, eventType, event)
+	if err != nil {
+		log.Printf("Error adding %s event: %v", eventType, err)
+		return nil
+	}
+	return ev
+}
+
+// AddLike makes a like the user's reaction to the target, replacing a dislike.
+func (mem *MemoryEventManager) AddLike(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return mem.addEvent(ctx, EventTypeLike, event)
+}
+
+// AddDislike makes a dislike the user's reaction to the target, replacing a like.
+func (mem *MemoryEventManager) AddDislike(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return mem.addEvent(ctx, EventTypeDislike, event)
+}
+
+// AddView records a "user-view" event.
+func (mem *MemoryEventManager) AddView(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return mem.addEvent(ctx, EventTypeView, event)
+}
+
+// AddComment records a "user-comment" event, including the comment text.
+func (mem *MemoryEventManager) AddComment(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return mem.addEvent(ctx, EventTypeComment, event)
+}
+
+// EventTypes returns the registered event types.
+func (mem *MemoryEventManager) EventTypes() []EventTypeDefinition {
+	return mem.Types.All()
+}
+
+// RemoveEvent retracts the user's reaction of the given type to the target and records the
+// type's removal event. It reports whether there was such a reaction.
+func (mem *MemoryEventManager) RemoveEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (bool, error) {
+	d, err := mem.Types.Get(eventType)
+	if err != nil {
+		return false, err
+	}
+	if !d.Reaction {
+		return false, fmt.Errorf("%w: %s", ErrEventNotRemovable, eventType)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	key := memoryReactionKey{event.UserID, event.TargetID, d.reactionGroup()}
+	if mem.reactions[key] != d.Name {
+		return false, nil
+	}
+	delete(mem.reactions, key)
+	mem.recordEvent(d.RemovalEvent, event, memoryNow())
+	return true, nil
+}
+
+// RemoveLike retracts the user's like of the target, if any.
+func (mem *MemoryEventManager) RemoveLike(ctx context.Context, event *commons.EventInputModel) (bool, error) {
+	return mem.RemoveEvent(ctx, EventTypeLike, event)
+}
+
+// RemoveDislike retracts the user's dislike of the target, if any.
+func (mem *MemoryEventManager) RemoveDislike(ctx context.Context, event *commons.EventInputModel) (bool, error) {
+	return mem.RemoveEvent(ctx, EventTypeDislike, event)
+}
+
+// GetReactions returns the user's current reactions to the target.
+func (mem *MemoryEventManager) GetReactions(ctx context.Context, userID, targetID string) ([]string, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	reactions := []string{}
+	for key, reaction := range mem.reactions {
+		if key.userID == userID && key.targetID == targetID {
+			reactions = append(reactions, reaction)
+		}
+	}
+	sort.Strings(reactions)
+	return reactions, nil
+}
+
+// GetStats counts the events of every registered type for a target. Reactions are counted from
+// their current state and counters include the events that retention archived.
+func (mem *MemoryEventManager) GetStats(ctx context.Context, targetID string) (*commons.EventStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	counters := map[string]bool{}
+	for _, d := range mem.Types.All() {
+		counts[d.Name] = 0
+		counters[d.Name] = !d.Reaction
+	}
+
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	viewers := map[string]bool{}
+	for userID := range mem.archivedViewers[targetID] {
+		viewers[userID] = true
+	}
+	for _, ev := range mem.events {
+		if ev.TargetID != targetID {
+			continue
+		}
+		if counters[ev.EventType] {
+			counts[ev.EventType]++
+		}
+		if ev.EventType == EventTypeView {
+			viewers[ev.UserID] = true
+		}
+	}
+	for eventType, count := range mem.archived[targetID] {
+		if counters[eventType] {
+			counts[eventType] += count
+		}
+	}
+	for key, reaction := range mem.reactions {
+		// Reactions of types that are no longer registered are not reported.
+		if _, ok := counts[reaction]; ok && key.targetID == targetID {
+			counts[reaction]++
+		}
+	}
+
+	stats := commons.NewEventStats(counts)
+	stats.UniqueViews = len(viewers)
+	return stats, nil
+}
+
+// GetStatsHandler is an HTTP handler that returns event stats for a given target, together
+// with the caller's own reaction when the request is authenticated.
+func (mem *MemoryEventManager) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	statsHandler(mem, w, r)
+}
+
+// selectEvents returns the events selected by the filter in its order, starting after its
+// cursor; the filter's limit is not applied.
+func (mem *MemoryEventManager) selectEvents(filter commons.EventFilter) ([]commons.Event, error) {
+	var cursorTime time.Time
+	var cursorID int64
+	if filter.Cursor != "" {
+		var err error
+		if cursorTime, cursorID, err = decodeEventCursor(filter.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	types := map[string]bool{}
+	for _, eventType := range filter.EventTypes {
+		types[eventType] = true
+	}
+
+	mem.mu.RLock()
+	var selected []commons.Event
+	for _, ev := range mem.events {
+		switch {
+		case filter.UserID != "" && ev.UserID != filter.UserID,
+			filter.TargetID != "" && ev.TargetID != filter.TargetID,
+			len(types) > 0 && !types[ev.EventType],
+			!filter.From.IsZero() && ev.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !ev.CreatedAt.Before(filter.To):
+			continue
+		}
+		selected = append(selected, ev)
+	}
+	mem.mu.RUnlock()
+
+	// Newest first unless ascending; events created at the same time are ordered by ID.
+	before := func(a, b commons.Event) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if filter.Ascending {
+			return before(selected[i], selected[j])
+		}
+		return before(selected[j], selected[i])
+	})
+	if filter.Cursor != "" {
+		cursor := commons.Event{ID: int(cursorID), CreatedAt: cursorTime}
+		start := sort.Search(len(selected), func(i int) bool {
+			if filter.Ascending {
+				return before(cursor, selected[i])
+			}
+			return before(selected[i], cursor)
+		})
+		selected = selected[start:]
+	}
+	return selected, nil
+}
+
+// ListEvents returns a page of the events selected by the filter.
+func (mem *MemoryEventManager) ListEvents(ctx context.Context, filter commons.EventFilter) (*commons.EventPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultEventPageSize
+	}
+	if limit > MaxEventPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidEventFilter, MaxEventPageSize)
+	}
+	events, err := mem.selectEvents(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &commons.EventPage{Events: []commons.Event{}}
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[limit-1]
+		page.NextCursor = encodeEventCursor(last.CreatedAt, int64(last.ID))
+	}
+	page.Events = append(page.Events, events...)
+	return page, nil
+}
+
+// ListEventsHandler is an HTTP handler that lists events for admins; see ParseEventFilter for
+// the query parameters.
+func (mem *MemoryEventManager) ListEventsHandler(w http.ResponseWriter, r *http.Request) {
+	listEventsHandler(mem, w, r)
+}
+
+// ExportEvents passes every event selected by the filter to fn, ignoring the filter's limit,
+// and returns how many it passed on. fn runs without holding the lock, so it may add events.
+func (mem *MemoryEventManager) ExportEvents(ctx context.Context, filter commons.EventFilter, fn func(commons.Event) error) (int, error) {
+	events, err := mem.selectEvents(filter)
+	if err != nil {
+		return 0, err
+	}
+	for i, ev := range events {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := fn(ev); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+// ExportEventsHandler is an HTTP handler that streams events to admins as a file; see
+// DatabaseEventManager.ExportEventsHandler.
+func (mem *MemoryEventManager) ExportEventsHandler(w http.ResponseWriter, r *http.Request) {
+	exportEventsHandler(mem, w, r)
+}
+
+// ApplyRetention moves the events that outlived the retention of their type into the archive,
+// in the same batches and under the same keys as DatabaseEventManager, and keeps counting them.
+func (mem *MemoryEventManager) ApplyRetention(ctx context.Context, archive EventArchive) error {
+	for _, d := range mem.Types.All() {
+		if d.RetentionDays == 0 {
+			continue
+		}
+		types := []string{d.Name}
+		if d.RemovalEvent != "" {
+			types = append(types, d.RemovalEvent)
+		}
+		cutoff := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -d.RetentionDays)
+		expired, err := mem.selectEvents(commons.EventFilter{
+			EventTypes: types,
+			To:         cutoff,
+			Ascending:  true,
+		})
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(expired); start += archiveBatchSize {
+			end := min(start+archiveBatchSize, len(expired))
+			if err := archiveBatch(archive, d.Name, expired[start:end]); err != nil {
+				return fmt.Errorf("error archiving %s events: %w", d.Name, err)
+			}
+			mem.removeEvents(expired[start:end])
+		}
+		if len(expired) > 0 {
+			log.Printf("Archived %d %s events older than %s", len(expired), d.Name, cutoff.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+// removeEvents deletes archived events and adds them to the archived counts and viewers.
+func (mem *MemoryEventManager) removeEvents(events []commons.Event) {
+	removed := make(map[int]bool, len(events))
+	for _, ev := range events {
+		removed[ev.ID] = true
+	}
+
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	kept := mem.events[:0]
+	for _, ev := range mem.events {
+		if !removed[ev.ID] {
+			kept = append(kept, ev)
+			continue
+		}
+		if mem.archived[ev.TargetID] == nil {
+			mem.archived[ev.TargetID] = map[string]int{}
+		}
+		mem.archived[ev.TargetID][ev.EventType]++
+		if ev.EventType == EventTypeView {
+			if mem.archivedViewers[ev.TargetID] == nil {
+				mem.archivedViewers[ev.TargetID] = map[string]bool{}
+			}
+			mem.archivedViewers[ev.TargetID][ev.UserID] = true
+		}
+	}
+	mem.events = kept
+}
@@ -0,0 +1,520 @@
+package UserEventManagers
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	"GOLA/commons"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Erasure deletes the revisions of the subject's comments and empties the comments, which stay
+// in their threads under the pseudonym. Cached stats catch up at the next reconciliation.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "comment_revisions", Table: "comment_revisions",
+			Query: `DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM comments WHERE author_id = $1)`,
+			Args:  Erasure.BySubject},
+		Erasure.Step{Name: "comments", Table: "comments",
+			Query: `UPDATE comments SET author_id = $2, content = '', deleted_at = COALESCE(deleted_at, NOW()), updated_at = NOW()
+			WHERE author_id = $1`, Args: Erasure.ByPseudonym},
+	)
+}
+
+// commentColumns lists the columns of a comment in scan order. reply_count only counts the
+// replies everyone can see.
+const commentColumns = `c.id, c.target_id, c.parent_id, c.root_id, c.author_id, c.content, c.state,
+	c.deleted_at IS NOT NULL, c.edited_at, c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL AND r.state <> 'hidden')`
+
+// commentVisibility filters out hidden comments the viewer ($viewer) may not see, unless the
+// viewer is a moderator ($moderator), and deleted comments that no longer have replies.
+const commentVisibility = `(c.state <> 'hidden' OR c.author_id = $%d OR $%d)
+	AND (c.deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL))`
+
+// PostgresCommentManager manages threaded comments using PostgreSQL.
+type PostgresCommentManager struct {
+	DB *sql.DB
+	// QueryTimeout bounds every query; Initialize reads DB_QUERY_TIMEOUT when it is zero.
+	QueryTimeout time.Duration
+}
+
+// Initialize connects to the database (if needed) and ensures the comment tables exist.
+func (p *PostgresCommentManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+	if p.QueryTimeout <= 0 {
+		timeout, err := time.ParseDuration(os.Getenv("DB_QUERY_TIMEOUT"))
+		if err != nil || timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		p.QueryTimeout = timeout
+	}
+	return createCommentTables(p.DB)
+}
+
+// createCommentTables creates the comments and comment_revisions tables. When the comments
+// table is new, it is seeded with the comments recorded as user-comment events.
+func createCommentTables(db *sql.DB) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT to_regclass('comments') IS NOT NULL`).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking comments table: %w", err)
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS comments (
+		id BIGSERIAL PRIMARY KEY,
+		target_id VARCHAR(100) NOT NULL,
+		parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+		root_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+		author_id VARCHAR(100) NOT NULL,
+		content TEXT NOT NULL,
+		state VARCHAR(20) NOT NULL DEFAULT 'visible',
+		edited_at TIMESTAMP,
+		deleted_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS comments_target_idx ON comments (target_id, parent_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS comments_parent_idx ON comments (parent_id);
+	CREATE INDEX IF NOT EXISTS comments_root_idx ON comments (root_id, created_at, id);
+
+	CREATE TABLE IF NOT EXISTS comment_revisions (
+		comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+		revision INT NOT NULL,
+		content TEXT NOT NULL,
+		edited_by VARCHAR(100) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (comment_id, revision)
+	);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("error creating comment tables: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO comments (target_id, author_id, content, created_at, updated_at)
+		SELECT target_id, user_id, comment, created_at, created_at
+		FROM user_events
+		WHERE event_type = $1 AND comment IS NOT NULL AND comment <> ''
+		ORDER BY created_at, id
+	`, EventTypeComment)
+	if err != nil {
+		return fmt.Errorf("error seeding comments table: %w", err)
+	}
+	if seeded, _ := result.RowsAffected(); seeded > 0 {
+		log.Printf("Seeded %d comments from existing comment events", seeded)
+	}
+	return nil
+}
+
+func (p *PostgresCommentManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, p.QueryTimeout)
+}
+
+// validateCommentContent rejects empty and oversized comments.
+func validateCommentContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("%w: comment is empty", ErrInvalidComment)
+	}
+	if utf8.RuneCountInString(content) > MaxCommentLength {
+		return fmt.Errorf("%w: comment longer than %d characters", ErrInvalidComment, MaxCommentLength)
+	}
+	return nil
+}
+
+// CreateComment posts a comment, or a reply when input.ParentID is set.
+func (p *PostgresCommentManager) CreateComment(ctx context.Context, authorID string, input *commons.CommentInputModel) (*models.Comment, error) {
+	if err := validateCommentContent(input.Content); err != nil {
+		return nil, err
+	}
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var rootID *int64
+	if input.ParentID != nil {
+		var parentTarget string
+		var parentRoot sql.NullInt64
+		var parentDeleted bool
+		err := p.DB.QueryRowContext(ctx, `SELECT target_id, root_id, deleted_at IS NOT NULL FROM comments WHERE id = $1`,
+			*input.ParentID).Scan(&parentTarget, &parentRoot, &parentDeleted)
+		if errors.Is(err, sql.ErrNoRows) || parentDeleted {
+			return nil, ErrCommentNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching parent comment: %w", err)
+		}
+		if parentTarget != input.TargetID {
+			return nil, fmt.Errorf("%w: parent comment belongs to another image", ErrInvalidComment)
+		}
+		root := *input.ParentID
+		if parentRoot.Valid {
+			root = parentRoot.Int64
+		}
+		rootID = &root
+	}
+
+	var id int64
+	err := p.DB.QueryRowContext(ctx, `
+		INSERT INTO comments (target_id, parent_id, root_id, author_id, content)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, input.TargetID, input.ParentID, rootID, authorID, input.Content).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	adjustCachedCount(ctx, input.TargetID, EventTypeComment, 1)
+	return p.getComment(ctx, id)
+}
+
+// GetComment returns a comment that has not been deleted.
+func (p *PostgresCommentManager) GetComment(ctx context.Context, commentID int64) (*models.Comment, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.getComment(ctx, commentID)
+}
+
+func (p *PostgresCommentManager) getComment(ctx context.Context, commentID int64) (*models.Comment, error) {
+	comment, err := scanComment(p.DB.QueryRowContext(ctx, `SELECT `+commentColumns+` FROM comments c WHERE c.id = $1`, commentID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching comment: %w", err)
+	}
+	if comment.Deleted {
+		return nil, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// EditComment replaces the content of a comment and keeps the previous content as a revision.
+func (p *PostgresCommentManager) EditComment(ctx context.Context, commentID int64, editorID, content string) (*models.Comment, error) {
+	if err := validateCommentContent(content); err != nil {
+		return nil, err
+	}
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var authorID, previous string
+	var deleted bool
+	err = tx.QueryRowContext(ctx, `SELECT author_id, content, deleted_at IS NOT NULL FROM comments WHERE id = $1 FOR UPDATE`,
+		commentID).Scan(&authorID, &previous, &deleted)
+	if errors.Is(err, sql.ErrNoRows) || deleted {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching comment: %w", err)
+	}
+	if authorID != editorID {
+		return nil, ErrCommentForbidden
+	}
+
+	if content != previous {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO comment_revisions (comment_id, revision, content, edited_by)
+			SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3 FROM comment_revisions WHERE comment_id = $1
+		`, commentID, previous, editorID); err != nil {
+			return nil, fmt.Errorf("error recording comment revision: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE comments SET content = $2, edited_at = NOW(), updated_at = NOW() WHERE id = $1`,
+			commentID, content); err != nil {
+			return nil, fmt.Errorf("error editing comment: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing comment edit: %w", err)
+	}
+	return p.getComment(ctx, commentID)
+}
+
+// DeleteComment removes the content and the revisions of a comment.
+func (p *PostgresCommentManager) DeleteComment(ctx context.Context, commentID int64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var targetID, state string
+	err = tx.QueryRowContext(ctx, `UPDATE comments SET content = '', deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING target_id, state`, commentID).Scan(&targetID, &state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting comment: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comment_revisions WHERE comment_id = $1`, commentID); err != nil {
+		return fmt.Errorf("error deleting comment revisions: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if state != models.CommentHidden {
+		adjustCachedCount(ctx, targetID, EventTypeComment, -1)
+	}
+	return nil
+}
+
+// SetCommentState changes the moderation state of a comment.
+func (p *PostgresCommentManager) SetCommentState(ctx context.Context, commentID int64, state string) (*models.Comment, error) {
+	switch state {
+	case models.CommentVisible, models.CommentHidden, models.CommentFlagged:
+	default:
+		return nil, fmt.Errorf("%w: unknown state %q", ErrInvalidComment, state)
+	}
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var targetID, previous string
+	err := p.DB.QueryRowContext(ctx, `
+		UPDATE comments c SET state = $2, updated_at = NOW()
+		FROM (SELECT id, state FROM comments WHERE id = $1 FOR UPDATE) AS old
+		WHERE c.id = old.id AND c.deleted_at IS NULL
+		RETURNING c.target_id, old.state
+	`, commentID, state).Scan(&targetID, &previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error changing comment state: %w", err)
+	}
+	// Hidden comments are not counted.
+	switch {
+	case previous == models.CommentHidden && state != models.CommentHidden:
+		adjustCachedCount(ctx, targetID, EventTypeComment, 1)
+	case previous != models.CommentHidden && state == models.CommentHidden:
+		adjustCachedCount(ctx, targetID, EventTypeComment, -1)
+	}
+	return p.getComment(ctx, commentID)
+}
+
+// ListComments returns a page of top-level comments, or of the replies to query.ParentID,
+// oldest first. For top-level pages, up to query.Replies replies of every thread are nested
+// under the comments they answer.
+func (p *PostgresCommentManager) ListComments(ctx context.Context, query CommentQuery) (*models.CommentPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 20
+	} else if limit > 100 {
+		limit = 100
+	}
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{query.TargetID, query.ViewerID, query.Moderator}
+	var sqlQuery strings.Builder
+	sqlQuery.WriteString(`SELECT ` + commentColumns + ` FROM comments c WHERE c.target_id = $1 AND `)
+	fmt.Fprintf(&sqlQuery, commentVisibility, 2, 3)
+	if query.ParentID != 0 {
+		args = append(args, query.ParentID)
+		fmt.Fprintf(&sqlQuery, " AND c.parent_id = $%d", len(args))
+	} else {
+		sqlQuery.WriteString(" AND c.parent_id IS NULL")
+	}
+	if query.Cursor != "" {
+		createdAt, id, err := decodeCommentCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		fmt.Fprintf(&sqlQuery, " AND (c.created_at, c.id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit+1)
+	fmt.Fprintf(&sqlQuery, " ORDER BY c.created_at, c.id LIMIT $%d", len(args))
+
+	comments, err := p.queryComments(ctx, sqlQuery.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.CommentPage{Comments: comments}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		last := page.Comments[limit-1]
+		page.NextCursor = encodeCommentCursor(last.CreatedAt, last.ID)
+	}
+
+	if query.ParentID == 0 && query.Replies > 0 && len(page.Comments) > 0 {
+		if err := p.nestReplies(ctx, page.Comments, query); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// nestReplies loads the first replies of every thread and attaches them to their parents.
+func (p *PostgresCommentManager) nestReplies(ctx context.Context, roots []models.Comment, query CommentQuery) error {
+	replies := query.Replies
+	if replies > 100 {
+		replies = 100
+	}
+	rootIDs := make([]int64, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT id, target_id, parent_id, root_id, author_id, content, state, deleted, edited_at, created_at, updated_at, reply_count
+		FROM (
+			SELECT `+commentColumns+`,
+				ROW_NUMBER() OVER (PARTITION BY c.root_id ORDER BY c.created_at, c.id) AS position
+			FROM comments c
+			WHERE c.root_id = ANY($1) AND `+commentVisibility+`
+		) AS thread (id, target_id, parent_id, root_id, author_id, content, state, deleted, edited_at, created_at, updated_at, reply_count, position)
+		WHERE position <= $4
+		ORDER BY created_at, id
+	`, 2, 3)
+	all, err := p.queryComments(ctx, sqlQuery, pq.Array(rootIDs), query.ViewerID, query.Moderator, replies)
+	if err != nil {
+		return err
+	}
+
+	children := map[int64][]models.Comment{}
+	for _, reply := range all {
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+	var attach func(comment *models.Comment)
+	attach = func(comment *models.Comment) {
+		comment.Replies = children[comment.ID]
+		for i := range comment.Replies {
+			attach(&comment.Replies[i])
+		}
+	}
+	for i := range roots {
+		attach(&roots[i])
+	}
+	return nil
+}
+
+// GetCommentHistory returns the previous versions of a comment, oldest first.
+func (p *PostgresCommentManager) GetCommentHistory(ctx context.Context, commentID int64) ([]models.CommentRevision, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, `SELECT comment_id, revision, content, edited_by, created_at
+		FROM comment_revisions WHERE comment_id = $1 ORDER BY revision`, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying comment history: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.CommentRevision{}
+	for rows.Next() {
+		var revision models.CommentRevision
+		if err := rows.Scan(&revision.CommentID, &revision.Revision, &revision.Content, &revision.EditedBy,
+			&revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning comment revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (p *PostgresCommentManager) queryComments(ctx context.Context, query string, args ...interface{}) ([]models.Comment, error) {
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning comment row: %w", err)
+		}
+		comments = append(comments, *comment)
+	}
+	return comments, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	var comment models.Comment
+	var parentID, rootID sql.NullInt64
+	var editedAt sql.NullTime
+	if err := row.Scan(&comment.ID, &comment.TargetID, &parentID, &rootID, &comment.AuthorID, &comment.Content,
+		&comment.State, &comment.Deleted, &editedAt, &comment.CreatedAt, &comment.UpdatedAt, &comment.ReplyCount); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		comment.ParentID = &parentID.Int64
+	}
+	if rootID.Valid {
+		comment.RootID = &rootID.Int64
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	return &comment, nil
+}
+
+// encodeCommentCursor and decodeCommentCursor turn the position of the last comment of a page
+// into an opaque cursor and back.
+func encodeCommentCursor(createdAt time.Time, id int64) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCommentCursor(cursor string) (time.Time, int64, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidComment)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	commentID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, unixNano).UTC(), commentID, nil
+}
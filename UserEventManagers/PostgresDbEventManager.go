@@ -1,24 +1,40 @@
 package UserEventManagers
 
 import (
+	"GOLA/DataSubjects/Erasure"
 	"GOLA/DbQueryStrategies"
 	"GOLA/DbQueryStrategies/PostgresDb"
 	"GOLA/commons/db"
 
 	"GOLA/commons"
+	"GOLA/utils"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq" // Import the Postgres driver
+	"github.com/lib/pq" // Postgres driver and array support
 	"log"
 	"net/http"
 	"os"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 )
 
+// Erasure pseudonymises the events, archived viewers and reactions of the subject, which keep
+// counting in the stats of their targets, and drops the comments of their events.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "events", Table: "user_events",
+			Query: `UPDATE user_events SET user_id = $2, comment = NULL WHERE user_id = $1`, Args: Erasure.ByPseudonym},
+		Erasure.Step{Name: "archived_viewers", Table: "archived_viewers",
+			Query: `UPDATE archived_viewers SET user_id = $2 WHERE user_id = $1`, Args: Erasure.ByPseudonym},
+		Erasure.Step{Name: "reactions", Table: "user_reactions",
+			Query: `UPDATE user_reactions SET user_id = $2 WHERE user_id = $1`, Args: Erasure.ByPseudonym},
+	)
+}
+
 // SelectEventStrategy determines which DB strategy to use based on configuration.
 // Here we reuse the same strategies as tasks. Adjust if you have event‑specific strategies.
 func SelectEventStrategy(db *sql.DB, queryStrategy string) DbQueryStrategies.DatabaseQueryStrategy {
@@ -47,36 +63,26 @@ func SelectEventStrategy(db *sql.DB, queryStrategy string) DbQueryStrategies.Dat
 	}
 }
 
-// InitializeDB creates and returns a database connection using provided configuration.
+// InitializeDB creates and returns a database connection using provided configuration,
+// including its connection pool settings.
 func InitializeDB(config db.DBConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		config.Host,
-		config.Port,
-		config.User,
-		config.Password,
-		config.DbName,
-		config.SSLMode,
-	)
-
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	log.Println("Successfully connected to the database!")
-	return db, nil
+	return db.InitializeDB(config)
 }
 
 // DatabaseEventManager implements the EventManager interface using a PostgreSQL database.
+// It holds no locks of its own: *sql.DB is safe for concurrent use and its pool bounds the
+// number of parallel queries.
 type DatabaseEventManager struct {
 	Db       *sql.DB
 	strategy DbQueryStrategies.DatabaseQueryStrategy
-	mu       sync.Mutex
+	// Types is the event type registry; Initialize loads it from EVENT_TYPES_CONFIG when nil.
+	Types *EventTypeRegistry
+	// buffer batches events without a comment before they are written; nil writes synchronously.
+	buffer *eventBuffer
+	// queryTimeout bounds every query, on top of any deadline of the caller's context.
+	queryTimeout time.Duration
+	// viewDedupWindow is the time during which repeated views of a user are not recorded.
+	viewDedupWindow time.Duration
 }
 
 // Initialize loads environment variables, establishes the database connection,
@@ -87,36 +93,32 @@ func (dem *DatabaseEventManager) Initialize() {
 		log.Println("No .env file found, assuming environment variables are set")
 	}
 
-	// Read connection settings from environment variables.
-	host := os.Getenv("DB_HOST")
-	portStr := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	sslMode := os.Getenv("DB_SSLMODE")
-
-	port, err := strconv.Atoi(portStr)
+	// Read connection and pool settings from environment variables.
+	config, err := db.LoadDBConfigFromEnv()
 	if err != nil {
-		log.Fatalf("Invalid DB_PORT value: %v", err)
-	}
-
-	// Build DB configuration.
-	config := db.DBConfig{
-		Host:     host,
-		Port:     port,
-		User:     user,
-		Password: password,
-		DbName:   dbName,
-		SSLMode:  sslMode,
+		log.Fatalf("Invalid database configuration: %v", err)
 	}
 
 	// Initialize the database connection.
-	db, err := InitializeDB(config)
-	if err != nil || db == nil {
+	conn, err := InitializeDB(config)
+	if err != nil || conn == nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
-	dem.Db = db
-	log.Printf("Database connection initialized successfully to %s:%d", host, port)
+	dem.Db = conn
+	log.Printf("Database connection initialized successfully to %s:%d (max open connections %d)",
+		config.Host, config.Port, config.MaxOpenConns)
+
+	dem.queryTimeout, err = time.ParseDuration(os.Getenv("DB_QUERY_TIMEOUT"))
+	if err != nil || dem.queryTimeout <= 0 {
+		dem.queryTimeout = 5 * time.Second
+	}
+
+	if dem.viewDedupWindow, err = viewDedupWindowFromEnv(); err != nil {
+		log.Fatalf("Invalid VIEW_DEDUP_WINDOW: %v", err)
+	}
+	if dem.Types, err = eventTypesFromEnv(dem.Types); err != nil {
+		log.Fatalf("Invalid event types configuration: %v", err)
+	}
 
 	// Select the query strategy.
 	dbQueryStrategy := os.Getenv("DB_QUERY_STRATEGY")
@@ -125,173 +127,369 @@ func (dem *DatabaseEventManager) Initialize() {
 
 	// Create the events table if it does not exist.
 	dem.createEventsTable()
+	dem.createReactionsTable()
+	if err := createCommentTables(dem.Db); err != nil {
+		log.Fatalf("Failed to create comment tables: %v", err)
+	}
+
+	// Set up write-behind buffering of high volume events (EVENT_BUFFER_SIZE=0 disables it).
+	bufferSize := envInt("EVENT_BUFFER_SIZE", 10000)
+	if bufferSize > 0 {
+		batchSize := envInt("EVENT_FLUSH_BATCH_SIZE", 500)
+		workers := envInt("EVENT_FLUSH_WORKERS", 2)
+		flushInterval, err := time.ParseDuration(os.Getenv("EVENT_FLUSH_INTERVAL"))
+		if err != nil || flushInterval <= 0 {
+			flushInterval = 2 * time.Second
+		}
+		dem.buffer = newEventBuffer(bufferSize, batchSize, workers, flushInterval, dem.insertEvents)
+		log.Printf("Buffering events: capacity=%d batch=%d workers=%d interval=%s",
+			bufferSize, batchSize, workers, flushInterval)
+	}
+	dem.LazySave()
+}
+
+// envInt reads a non-negative integer environment variable, falling back to def.
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return def
+	}
+	return value
 }
 
-// createEventsTable creates the user_events table if it doesn't already exist.
+// viewDedupWindowFromEnv reads VIEW_DEDUP_WINDOW: repeated views of a user within the window
+// count once (0 disables it). It defaults to 30 minutes.
+func viewDedupWindowFromEnv() (time.Duration, error) {
+	value := os.Getenv("VIEW_DEDUP_WINDOW")
+	if value == "" {
+		return 30 * time.Minute, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// eventTypesFromEnv loads the registry from EVENT_TYPES_CONFIG unless one is given, then applies
+// the retention overrides of EVENT_RETENTION, e.g. "user-view=30d".
+func eventTypesFromEnv(types *EventTypeRegistry) (*EventTypeRegistry, error) {
+	if types == nil {
+		var err error
+		if types, err = LoadEventTypeRegistry(os.Getenv("EVENT_TYPES_CONFIG")); err != nil {
+			return nil, err
+		}
+	}
+	retention, err := ParseRetention(os.Getenv("EVENT_RETENTION"))
+	if err == nil {
+		err = types.SetRetention(retention)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_RETENTION: %w", err)
+	}
+	return types, nil
+}
+
+// withTimeout derives a context bounded by the configured query timeout.
+func (dem *DatabaseEventManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := dem.queryTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// createEventsTable creates the user_events table, partitioned by month, if it doesn't already
+// exist and makes sure the coming months have partitions. An older unpartitioned table is left
+// as it is: converting it locks the table while every event is copied, so that is left to
+// scripts/PartitionEvents.
 func (dem *DatabaseEventManager) createEventsTable() {
+	partitioned, err := eventsTablePartitioned(context.Background(), dem.Db)
+	if err != nil {
+		log.Fatalf("Failed to check events table: %v", err)
+	}
+	if !partitioned {
+		log.Println("user_events is not partitioned; run scripts/PartitionEvents to partition it by month")
+	}
 	query := `
 	CREATE TABLE IF NOT EXISTS user_events (
-		id SERIAL PRIMARY KEY,
+		id SERIAL,` + eventColumnsDDL + `;
+	CREATE INDEX IF NOT EXISTS user_events_target_idx ON user_events (target_id, event_type, user_id, created_at);
+	CREATE INDEX IF NOT EXISTS user_events_user_idx ON user_events (user_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS user_events_created_idx ON user_events (created_at, id);
+	CREATE TABLE IF NOT EXISTS archived_event_counts (
+		target_id VARCHAR(100) NOT NULL,
 		event_type VARCHAR(50) NOT NULL,
-		user_id VARCHAR(100) NOT NULL,
+		count BIGINT NOT NULL,
+		PRIMARY KEY (target_id, event_type)
+	);
+	CREATE TABLE IF NOT EXISTS archived_viewers (
 		target_id VARCHAR(100) NOT NULL,
-		comment TEXT,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		user_id VARCHAR(100) NOT NULL,
+		PRIMARY KEY (target_id, user_id)
 	);
 	`
 	if _, err := dem.Db.Exec(query); err != nil {
 		log.Fatalf("Failed to create events table: %v", err)
 	}
+	if !partitioned {
+		return
+	}
+	if _, err := dem.Db.Exec(`CREATE TABLE IF NOT EXISTS user_events_default PARTITION OF user_events DEFAULT`); err != nil {
+		log.Fatalf("Failed to create default events partition: %v", err)
+	}
+	// Events of months without a partition land in the default partition, so this is not fatal.
+	if err := createMonthlyPartitions(context.Background(), dem.Db, time.Now(), time.Now()); err != nil {
+		log.Printf("Failed to create events partitions: %v", err)
+	}
 }
 
-// SaveEvents flushes any buffered events if needed (here it is a no-op).
+// SaveEvents writes every buffered event to the database before returning. It gives up after
+// flushTimeout, for instance while the database is unreachable.
 func (dem *DatabaseEventManager) SaveEvents() error {
-	return nil
+	if dem.buffer == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return dem.buffer.flushNow(ctx)
 }
 
-// LazySave can be used for deferred/batch persistence. Currently, it is a no-op.
+// LazySave starts the background flusher that persists buffered events in batches.
+// Initialize calls it; calling it again has no effect.
 func (dem *DatabaseEventManager) LazySave() {
-	// Implement deferred save if necessary.
+	if dem.buffer != nil {
+		dem.buffer.start()
+	}
 }
 
-// AddLike records a "user-like" event into the database.
-func (dem *DatabaseEventManager) AddLike(event *commons.EventInputModel) (addedEvent *commons.Event) {
-	dem.mu.Lock()
-	defer dem.mu.Unlock()
-
-	query := `
-		INSERT INTO user_events (event_type, user_id, target_id, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at;
-	`
-	var id int
-	var createdAt time.Time
-	if err := dem.Db.QueryRow(query, "user-like", event.UserID, event.TargetID, time.Now()).Scan(&id, &createdAt); err != nil {
-		log.Printf("Error adding like event: %v", err)
+// Close flushes the buffered events and stops the background flusher.
+func (dem *DatabaseEventManager) Close() error {
+	if dem.buffer == nil {
 		return nil
 	}
-	return &commons.Event{
-		ID:        id,
-		EventType: "user-like",
-		UserID:    event.UserID,
-		TargetID:  event.TargetID,
-		CreatedAt: createdAt,
-	}
+	return dem.buffer.close()
 }
 
-// AddDislike records a "user-dislike" event.
-func (dem *DatabaseEventManager) AddDislike(event *commons.EventInputModel) (addedEvent *commons.Event) {
-	dem.mu.Lock()
-	defer dem.mu.Unlock()
+// AddEvent records an event of any registered type after validating it against the rules of
+// the type. Reactions replace the user's previous reaction in their exclusive group; other
+// types are counted every time.
+func (dem *DatabaseEventManager) AddEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (*commons.Event, error) {
+	d, err := dem.Types.Get(eventType)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Validate(event); err != nil {
+		return nil, err
+	}
+	if d.Reaction {
+		return dem.setReaction(ctx, d, event)
+	}
+	if d.Name == EventTypeView {
+		if !dem.shouldRecordView(ctx, event.UserID, event.TargetID) {
+			// A repeated view within the window is accepted but not counted again.
+			return &commons.Event{EventType: d.Name, UserID: event.UserID, TargetID: event.TargetID, CreatedAt: time.Now()}, nil
+		}
+		dem.addUniqueViewer(ctx, event.UserID, event.TargetID)
+	}
+	ev, err := dem.recordEvent(ctx, d.Name, event)
+	// Comments are counted by the comment manager, which knows about deletions and hiding.
+	if err == nil && d.Name != EventTypeComment {
+		adjustCachedCount(ctx, event.TargetID, d.Name, 1)
+	}
+	return ev, err
+}
 
-	query := `
-		INSERT INTO user_events (event_type, user_id, target_id, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at;
-	`
-	var id int
-	var createdAt time.Time
-	if err := dem.Db.QueryRow(query, "user-dislike", event.UserID, event.TargetID, time.Now()).Scan(&id, &createdAt); err != nil {
-		log.Printf("Error adding dislike event: %v", err)
+// addEvent is AddEvent for the fixed-type methods, which report failures by returning nil.
+func (dem *DatabaseEventManager) addEvent(ctx context.Context, eventType string, event *commons.EventInputModel) *commons.Event {
+	ev, err := dem.AddEvent(ctx, eventType, event)
+	if err != nil {
+		log.Printf("Error adding %s event: %v", eventType, err)
 		return nil
 	}
-	return &commons.Event{
-		ID:        id,
-		EventType: "user-dislike",
-		UserID:    event.UserID,
-		TargetID:  event.TargetID,
-		CreatedAt: createdAt,
-	}
+	return ev
+}
+
+// AddLike makes a like the user's reaction to the target, replacing a dislike.
+func (dem *DatabaseEventManager) AddLike(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return dem.addEvent(ctx, EventTypeLike, event)
+}
+
+// AddDislike makes a dislike the user's reaction to the target, replacing a like.
+func (dem *DatabaseEventManager) AddDislike(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return dem.addEvent(ctx, EventTypeDislike, event)
 }
 
 // AddView records a "user-view" event.
-func (dem *DatabaseEventManager) AddView(event *commons.EventInputModel) (addedEvent *commons.Event) {
-	dem.mu.Lock()
-	defer dem.mu.Unlock()
+func (dem *DatabaseEventManager) AddView(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return dem.addEvent(ctx, EventTypeView, event)
+}
 
-	query := `
-		INSERT INTO user_events (event_type, user_id, target_id, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at;
-	`
-	var id int
-	var createdAt time.Time
-	if err := dem.Db.QueryRow(query, "user-view", event.UserID, event.TargetID, time.Now()).Scan(&id, &createdAt); err != nil {
-		log.Printf("Error adding view event: %v", err)
-		return nil
-	}
-	return &commons.Event{
-		ID:        id,
-		EventType: "user-view",
+// AddComment records a "user-comment" event, including the comment text.
+func (dem *DatabaseEventManager) AddComment(ctx context.Context, event *commons.EventInputModel) (addedEvent *commons.Event) {
+	return dem.addEvent(ctx, EventTypeComment, event)
+}
+
+// EventTypes returns the registered event types.
+func (dem *DatabaseEventManager) EventTypes() []EventTypeDefinition {
+	return dem.Types.All()
+}
+
+// recordEvent appends an event to user_events. Events without a comment go through the
+// write-behind buffer, if enabled, and are returned without an ID since it is only assigned
+// once the batch is written; writers blocked on a full buffer give up when ctx ends. Events
+// with a comment are inserted directly so the caller gets their ID.
+func (dem *DatabaseEventManager) recordEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (*commons.Event, error) {
+	ev := &commons.Event{
+		EventType: eventType,
 		UserID:    event.UserID,
 		TargetID:  event.TargetID,
-		CreatedAt: createdAt,
+		Comment:   event.Comment,
+		CreatedAt: time.Now(),
+	}
+	if dem.buffer != nil && ev.Comment == "" {
+		if err := dem.buffer.add(ctx, ev); err != nil {
+			return nil, fmt.Errorf("error buffering %s event: %w", eventType, err)
+		}
+		return ev, nil
 	}
-}
-
-// AddComment records a "user-comment" event, including the comment text.
-func (dem *DatabaseEventManager) AddComment(event *commons.EventInputModel) (addedEvent *commons.Event) {
-	dem.mu.Lock()
-	defer dem.mu.Unlock()
 
+	ctx, cancel := dem.withTimeout(ctx)
+	defer cancel()
 	query := `
 		INSERT INTO user_events (event_type, user_id, target_id, comment, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
 		RETURNING id, created_at;
 	`
-	var id int
-	var createdAt time.Time
-	if err := dem.Db.QueryRow(query, "user-comment", event.UserID, event.TargetID, event.Comment, time.Now()).Scan(&id, &createdAt); err != nil {
-		log.Printf("Error adding comment event: %v", err)
+	if err := dem.Db.QueryRowContext(ctx, query, ev.EventType, ev.UserID, ev.TargetID, ev.Comment, ev.CreatedAt).Scan(&ev.ID, &ev.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error adding %s event: %w", eventType, err)
+	}
+	return ev, nil
+}
+
+// insertEvents writes a batch of events with a single multi-row INSERT. Batches run on the
+// flusher goroutines, detached from any request, so only the query timeout bounds them.
+func (dem *DatabaseEventManager) insertEvents(events []*commons.Event) error {
+	if len(events) == 0 {
 		return nil
 	}
-	return &commons.Event{
-		ID:        id,
-		EventType: "user-comment",
-		UserID:    event.UserID,
-		TargetID:  event.TargetID,
-		Comment:   event.Comment,
-		CreatedAt: createdAt,
+	ctx, cancel := dem.withTimeout(context.Background())
+	defer cancel()
+	var query strings.Builder
+	query.WriteString("INSERT INTO user_events (event_type, user_id, target_id, comment, created_at) VALUES ")
+	args := make([]interface{}, 0, len(events)*5)
+	for i, ev := range events {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, NULLIF($%d, ''), $%d)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, ev.EventType, ev.UserID, ev.TargetID, ev.Comment, ev.CreatedAt)
 	}
+	if _, err := dem.Db.ExecContext(ctx, query.String(), args...); err != nil {
+		return fmt.Errorf("error inserting %d events: %w", len(events), err)
+	}
+	return nil
+}
+
+// GetStats fetches the counts of every registered event type for a specific target. Counts
+// are served from Redis, which is filled from Postgres on a miss.
+func (dem *DatabaseEventManager) GetStats(ctx context.Context, targetID string) (*commons.EventStats, error) {
+	ctx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+
+	counts, err := cachedCounts(ctx, targetID)
+	if err != nil {
+		log.Printf("Redis unavailable for stats, using Postgres: %v", err)
+	} else if counts == nil {
+		if counts, err = dem.seedCachedCounts(ctx, targetID); err != nil {
+			log.Printf("Error seeding cached stats of %s: %v", targetID, err)
+		}
+	}
+	if counts == nil {
+		if counts, err = dem.countsFromPostgres(ctx, targetID); err != nil {
+			return nil, err
+		}
+	}
+	// Types registered after the counts were cached start at zero.
+	for _, d := range dem.Types.All() {
+		if _, ok := counts[d.Name]; !ok {
+			counts[d.Name] = 0
+		}
+	}
+
+	stats := commons.NewEventStats(counts)
+	if stats.UniqueViews, err = dem.countUniqueViewers(ctx, targetID); err != nil {
+		return nil, err
+	}
+	return stats, nil
 }
 
-// GetStats fetches aggregated event counts for a specific target.
-func (dem *DatabaseEventManager) GetStats(targetID string) (*commons.EventStats, error) {
+// countsFromPostgres counts the events of every registered type for a target.
+func (dem *DatabaseEventManager) countsFromPostgres(ctx context.Context, targetID string) (map[string]int, error) {
+	ctx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+	// Reactions are counted from their current state, not from their history, and comments
+	// from the comments that are neither deleted nor hidden. Counters include the events that
+	// retention archived.
+	counters := dem.Types.Names(func(d EventTypeDefinition) bool { return !d.Reaction && d.Name != EventTypeComment })
 	query := `
-		SELECT event_type, COUNT(*) 
-		FROM user_events
-		WHERE target_id = $1
+		SELECT event_type, SUM(count)
+		FROM (
+			SELECT event_type, COUNT(*) AS count
+			FROM user_events
+			WHERE target_id = $1 AND event_type = ANY($2)
+			GROUP BY event_type
+			UNION ALL
+			SELECT event_type, count
+			FROM archived_event_counts
+			WHERE target_id = $1 AND event_type = ANY($2)
+		) counters
 		GROUP BY event_type
+		UNION ALL
+		SELECT reaction, COUNT(*)
+		FROM user_reactions
+		WHERE target_id = $1
+		GROUP BY reaction
+		UNION ALL
+		SELECT $3, COUNT(*)
+		FROM comments
+		WHERE target_id = $1 AND deleted_at IS NULL AND state <> 'hidden'
 	`
-	rows, err := dem.Db.Query(query, targetID)
+	rows, err := dem.Db.QueryContext(ctx, query, targetID, pq.Array(counters), EventTypeComment)
 	if err != nil {
 		return nil, fmt.Errorf("error querying stats: %w", err)
 	}
 	defer rows.Close()
 
-	stats := &commons.EventStats{}
+	counts := map[string]int{}
+	for _, d := range dem.Types.All() {
+		counts[d.Name] = 0
+	}
 	for rows.Next() {
 		var eventType string
 		var count int
 		if err := rows.Scan(&eventType, &count); err != nil {
 			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
-		switch eventType {
-		case "user-like":
-			stats.Likes = count
-		case "user-dislike":
-			stats.Dislikes = count
-		case "user-view":
-			stats.Views = count
-		case "user-comment":
-			stats.Comments = count
+		// Reactions of types that are no longer registered are not reported.
+		if _, ok := counts[eventType]; ok {
+			counts[eventType] = count
 		}
 	}
-	return stats, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading stats: %w", err)
+	}
+	return counts, nil
 }
 
-// GetStatsHandler is an HTTP handler that returns event stats for a given target.
+// GetStatsHandler is an HTTP handler that returns event stats for a given target, together
+// with the caller's own reaction when the request is authenticated.
 func (dem *DatabaseEventManager) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	statsHandler(dem, w, r)
+}
+
+// statsHandler implements GetStatsHandler for any EventManager.
+func statsHandler(manager EventManager, w http.ResponseWriter, r *http.Request) {
 	// Expect the target id as a query parameter.
 	targetID := r.URL.Query().Get("target_id")
 	if targetID == "" {
@@ -299,91 +497,20 @@ func (dem *DatabaseEventManager) GetStatsHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	stats, err := dem.GetStats(targetID)
+	stats, err := manager.GetStats(r.Context(), targetID)
 	if err != nil {
 		log.Printf("Error fetching stats: %v", err)
 		http.Error(w, "Failed to fetch stats", http.StatusInternalServerError)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(stats); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-	}
-}
-
-// GetComments fetches all comment events for a specific target.
-func (dem *DatabaseEventManager) GetComments(targetID string) ([]commons.Event, error) {
-	query := `
-		SELECT id, event_type, user_id, target_id, comment, created_at
-		FROM user_events
-		WHERE target_id = $1 AND event_type = 'user-comment'
-		ORDER BY created_at ASC
-	`
-	rows, err := dem.Db.Query(query, targetID)
-	if err != nil {
-		return nil, fmt.Errorf("error querying comments: %w", err)
-	}
-	defer rows.Close()
-
-	var comments []commons.Event
-	for rows.Next() {
-		var ev commons.Event
-		if err := rows.Scan(&ev.ID, &ev.EventType, &ev.UserID, &ev.TargetID, &ev.Comment, &ev.CreatedAt); err != nil {
-			return nil, fmt.Errorf("error scanning comment row: %w", err)
+	if clientID, err := utils.GetClientIDFromContext(r.Context()); err == nil {
+		if stats.MyReactions, err = manager.GetReactions(r.Context(), clientID, targetID); err != nil {
+			log.Printf("Error fetching reactions: %v", err)
 		}
-		comments = append(comments, ev)
-	}
-	return comments, nil
-}
-
-// GetCommentsHandler is an HTTP handler that returns comments for a given target.
-func (dem *DatabaseEventManager) GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
-	targetID := r.URL.Query().Get("target_id")
-	if targetID == "" {
-		http.Error(w, "target_id is required", http.StatusBadRequest)
-		return
-	}
-
-	comments, err := dem.GetComments(targetID)
-	if err != nil {
-		log.Printf("Error fetching comments: %v", err)
-		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
-		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(comments); err != nil {
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
-
-// ListEvents retrieves all events from the database and writes them as JSON.
-func (dem *DatabaseEventManager) ListEvents(w http.ResponseWriter, r *http.Request) {
-	dem.mu.Lock()
-	defer dem.mu.Unlock()
-
-	rows, err := dem.Db.Query(`
-		SELECT id, event_type, user_id, target_id, comment, created_at
-		FROM user_events
-		ORDER BY created_at DESC
-	`)
-	if err != nil {
-		http.Error(w, "Failed to query events", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var events []commons.Event
-	for rows.Next() {
-		var ev commons.Event
-		if err := rows.Scan(&ev.ID, &ev.EventType, &ev.UserID, &ev.TargetID, &ev.Comment, &ev.CreatedAt); err != nil {
-			log.Printf("Error scanning event: %v", err)
-			continue
-		}
-		events = append(events, ev)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(events)
-}
@@ -0,0 +1,214 @@
+package UserEventManagers
+
+import (
+	"GOLA/commons"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Reactions are per-(user, target) state: within an exclusive group (like/dislike) a user has
+// at most one reaction to a target. The current state lives in user_reactions; every change is
+// also appended to user_events so the history of reactions is kept.
+const (
+	EventTypeLike      = "user-like"
+	EventTypeDislike   = "user-dislike"
+	EventTypeUnlike    = "user-unlike"
+	EventTypeUndislike = "user-undislike"
+)
+
+// createReactionsTable creates the user_reactions table and seeds every reaction group that
+// has no rows yet, such as all of them on a new table, from the events in user_events.
+func (dem *DatabaseEventManager) createReactionsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_reactions (
+		user_id VARCHAR(100) NOT NULL,
+		target_id VARCHAR(100) NOT NULL,
+		reaction VARCHAR(50) NOT NULL,
+		reaction_group VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (user_id, target_id, reaction_group)
+	);
+	CREATE INDEX IF NOT EXISTS user_reactions_target_idx ON user_reactions (target_id, reaction);
+	`
+	if _, err := dem.Db.Exec(query); err != nil {
+		log.Fatalf("Failed to create reactions table: %v", err)
+	}
+
+	groups := map[string][]string{}
+	removals := map[string][]string{}
+	for _, d := range dem.Types.All() {
+		if d.Reaction {
+			groups[d.reactionGroup()] = append(groups[d.reactionGroup()], d.Name)
+			removals[d.reactionGroup()] = append(removals[d.reactionGroup()], d.RemovalEvent)
+		}
+	}
+	for group, types := range groups {
+		var seeded bool
+		if err := dem.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM user_reactions WHERE reaction_group = $1)`,
+			group).Scan(&seeded); err != nil {
+			log.Fatalf("Failed to check reactions table: %v", err)
+		}
+		if seeded {
+			continue
+		}
+		// The latest reaction or removal of every user decides; removed reactions stay removed.
+		result, err := dem.Db.Exec(`
+			INSERT INTO user_reactions (user_id, target_id, reaction, reaction_group, created_at, updated_at)
+			SELECT user_id, target_id, event_type, $1, created_at, created_at
+			FROM (
+				SELECT DISTINCT ON (user_id, target_id) user_id, target_id, event_type, created_at
+				FROM user_events
+				WHERE event_type = ANY($2) OR event_type = ANY($3)
+				ORDER BY user_id, target_id, created_at DESC, id DESC
+			) latest
+			WHERE event_type = ANY($2)
+			ON CONFLICT (user_id, target_id, reaction_group) DO NOTHING
+		`, group, pq.Array(types), pq.Array(removals[group]))
+		if err != nil {
+			log.Fatalf("Failed to seed reactions table: %v", err)
+		}
+		if count, _ := result.RowsAffected(); count > 0 {
+			log.Printf("Seeded %d %s reactions from existing events", count, group)
+		}
+	}
+}
+
+// setReaction makes the reaction the user's current one in its group, replacing e.g. a
+// dislike with a like. Repeating the current reaction changes nothing and records no event.
+func (dem *DatabaseEventManager) setReaction(ctx context.Context, d EventTypeDefinition, event *commons.EventInputModel) (*commons.Event, error) {
+	queryCtx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+
+	ev := &commons.Event{
+		EventType: d.Name,
+		UserID:    event.UserID,
+		TargetID:  event.TargetID,
+		CreatedAt: time.Now(),
+	}
+	tx, err := dem.Db.BeginTx(queryCtx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The replaced reaction, if any, is needed to keep the cached counts right, so it is taken
+	// from the write itself: the update returns the reaction it locked and replaced. Without a
+	// current reaction the insert takes over; when a concurrent request inserted first, the
+	// update runs again and replaces that request's reaction.
+	var previous string
+	for {
+		err = tx.QueryRowContext(queryCtx, `
+			UPDATE user_reactions r SET reaction = $3, updated_at = $5
+			FROM (
+				SELECT reaction FROM user_reactions
+				WHERE user_id = $1 AND target_id = $2 AND reaction_group = $4
+				FOR UPDATE
+			) old
+			WHERE r.user_id = $1 AND r.target_id = $2 AND r.reaction_group = $4
+			RETURNING old.reaction
+		`, ev.UserID, ev.TargetID, d.Name, d.reactionGroup(), ev.CreatedAt).Scan(&previous)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error setting %s reaction: %w", d.Name, err)
+		}
+
+		err = tx.QueryRowContext(queryCtx, `
+			INSERT INTO user_reactions (user_id, target_id, reaction, reaction_group, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			ON CONFLICT (user_id, target_id, reaction_group) DO NOTHING
+			RETURNING updated_at
+		`, ev.UserID, ev.TargetID, d.Name, d.reactionGroup(), ev.CreatedAt).Scan(&ev.CreatedAt)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error setting %s reaction: %w", d.Name, err)
+		}
+	}
+	if previous == d.Name {
+		// Already the current reaction; the rollback keeps it unchanged.
+		return ev, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing %s reaction: %w", d.Name, err)
+	}
+
+	adjustCachedCount(ctx, ev.TargetID, d.Name, 1)
+	if previous != "" {
+		adjustCachedCount(ctx, ev.TargetID, previous, -1)
+	}
+	return dem.recordEvent(ctx, d.Name, event)
+}
+
+// RemoveEvent retracts the user's reaction of the given type to the target and records the
+// type's removal event. It reports whether there was such a reaction.
+func (dem *DatabaseEventManager) RemoveEvent(ctx context.Context, eventType string, event *commons.EventInputModel) (bool, error) {
+	d, err := dem.Types.Get(eventType)
+	if err != nil {
+		return false, err
+	}
+	if !d.Reaction {
+		return false, fmt.Errorf("%w: %s", ErrEventNotRemovable, eventType)
+	}
+
+	queryCtx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+	result, err := dem.Db.ExecContext(queryCtx, `
+		DELETE FROM user_reactions WHERE user_id = $1 AND target_id = $2 AND reaction = $3
+	`, event.UserID, event.TargetID, d.Name)
+	if err != nil {
+		return false, fmt.Errorf("error removing %s reaction: %w", d.Name, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+	adjustCachedCount(ctx, event.TargetID, d.Name, -1)
+	if _, err := dem.recordEvent(ctx, d.RemovalEvent, event); err != nil {
+		log.Printf("Error recording %s event: %v", d.RemovalEvent, err)
+	}
+	return true, nil
+}
+
+// RemoveLike retracts the user's like of the target, if any.
+func (dem *DatabaseEventManager) RemoveLike(ctx context.Context, event *commons.EventInputModel) (bool, error) {
+	return dem.RemoveEvent(ctx, EventTypeLike, event)
+}
+
+// RemoveDislike retracts the user's dislike of the target, if any.
+func (dem *DatabaseEventManager) RemoveDislike(ctx context.Context, event *commons.EventInputModel) (bool, error) {
+	return dem.RemoveEvent(ctx, EventTypeDislike, event)
+}
+
+// GetReactions returns the user's current reactions to the target.
+func (dem *DatabaseEventManager) GetReactions(ctx context.Context, userID, targetID string) ([]string, error) {
+	ctx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := dem.Db.QueryContext(ctx, `
+		SELECT reaction FROM user_reactions WHERE user_id = $1 AND target_id = $2 ORDER BY reaction
+	`, userID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reactions: %w", err)
+	}
+	defer rows.Close()
+
+	reactions := []string{}
+	for rows.Next() {
+		var reaction string
+		if err := rows.Scan(&reaction); err != nil {
+			return nil, fmt.Errorf("error scanning reaction: %w", err)
+		}
+		reactions = append(reactions, reaction)
+	}
+	return reactions, rows.Err()
+}
@@ -0,0 +1,206 @@
+package UserEventManagers
+
+import (
+	redisCache "GOLA/caches/Redis"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Per-target counts are cached in a Redis hash with one field per event type. The loaded field
+// marks a hash that was filled from Postgres; increments only apply to loaded hashes, so a lost
+// or expired hash is rebuilt from Postgres instead of counting up from zero. An increment of a
+// hash that is not loaded bumps its changed field instead, which aborts a fill in progress.
+const (
+	statsKeyPrefix    = "stats:"
+	statsLoadedField  = "_loaded"
+	statsChangedField = "_changed"
+)
+
+// statsChangedTTL is how long the changed field of a hash that is not loaded is kept; it only
+// has to outlive the fills in progress.
+const statsChangedTTL = time.Minute
+
+// statsCacheTTL is how long the counts of a target stay cached after they were last read.
+var statsCacheTTL = 24 * time.Hour
+
+// incrementIfLoaded adds to a field of a loaded stats hash. Other hashes only get their changed
+// field bumped, so a fill that counted before the change fails its WATCH.
+var incrementIfLoaded = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	return redis.call('HINCRBY', KEYS[1], ARGV[2], ARGV[3])
+end
+redis.call('HINCRBY', KEYS[1], ARGV[4], 1)
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return false
+`)
+
+// adjustCachedCount applies a change of the count of an event type to the cached stats of the
+// target. Failures are only logged: reconciliation repairs the cache.
+func adjustCachedCount(ctx context.Context, targetID, eventType string, delta int) {
+	client := redisCache.RedisClient
+	if client == nil || delta == 0 {
+		return
+	}
+	err := incrementIfLoaded.Run(ctx, client, []string{statsKeyPrefix + targetID},
+		statsLoadedField, eventType, delta, statsChangedField, int(statsChangedTTL.Seconds())).Err()
+	if err != nil && err != redis.Nil {
+		log.Printf("Error updating cached %s count of %s: %v", eventType, targetID, err)
+	}
+}
+
+// cachedCounts returns the cached counts of the target, or nil when they are not cached, and
+// keeps them cached for another statsCacheTTL.
+func cachedCounts(ctx context.Context, targetID string) (map[string]int, error) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return nil, nil
+	}
+	key := statsKeyPrefix + targetID
+	counts, err := readCachedCounts(ctx, client, key)
+	if err == nil && counts != nil {
+		client.Expire(ctx, key, statsCacheTTL)
+	}
+	return counts, err
+}
+
+func readCachedCounts(ctx context.Context, client redis.Cmdable, key string) (map[string]int, error) {
+	fields, err := client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if _, loaded := fields[statsLoadedField]; !loaded {
+		return nil, nil
+	}
+
+	counts := make(map[string]int, len(fields))
+	for field, value := range fields {
+		if field == statsLoadedField || field == statsChangedField {
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cached %s count: %w", field, err)
+		}
+		counts[field] = count
+	}
+	return counts, nil
+}
+
+// seedCachedCounts fills the cache with the counts of the target, see syncCachedCounts. It
+// returns nil when Redis is not configured or the counts could not be cached.
+func (dem *DatabaseEventManager) seedCachedCounts(ctx context.Context, targetID string) (map[string]int, error) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return nil, nil
+	}
+	counts, _, err := dem.syncCachedCounts(ctx, client, targetID, false)
+	return counts, err
+}
+
+// syncCachedCounts counts the events of the target in Postgres and caches the counts. With
+// replace unset, counts another request cached meanwhile are kept; with replace set, only a
+// cached hash is replaced. The hash is watched from before the buffered events are written and
+// the events counted, so the counts include every event whose increment came before, and an
+// increment after aborts the write instead of being overwritten. It returns the counts, nil
+// when they could not be cached, and whether the cached counts were changed.
+func (dem *DatabaseEventManager) syncCachedCounts(ctx context.Context, client *redis.Client, targetID string, replace bool) (map[string]int, bool, error) {
+	key := statsKeyPrefix + targetID
+	var counts map[string]int
+	var changed bool
+	var countErr error
+	err := client.Watch(ctx, func(tx *redis.Tx) error {
+		cached, err := readCachedCounts(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if cached != nil && !replace {
+			counts = cached
+			return nil
+		}
+		if cached == nil && replace {
+			return nil
+		}
+		if dem.buffer != nil {
+			if err := dem.buffer.flushNow(ctx); err != nil {
+				return fmt.Errorf("error flushing events: %w", err)
+			}
+		}
+		if counts, countErr = dem.countsFromPostgres(ctx, targetID); countErr != nil {
+			return countErr
+		}
+		if cached != nil && sameCounts(cached, counts) {
+			return nil
+		}
+
+		values := make([]interface{}, 0, 2*len(counts)+2)
+		values = append(values, statsLoadedField, 1)
+		for eventType, count := range counts {
+			values = append(values, eventType, count)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.HSet(ctx, key, values...)
+			pipe.Expire(ctx, key, statsCacheTTL)
+			return nil
+		})
+		changed = err == nil
+		return err
+	}, key)
+	switch {
+	case countErr != nil:
+		return nil, false, countErr
+	case errors.Is(err, redis.TxFailedErr):
+		// The counts changed meanwhile; the next read or reconciliation counts again.
+		return counts, false, nil
+	case err != nil:
+		return nil, false, fmt.Errorf("error caching stats of %s: %w", targetID, err)
+	}
+	return counts, changed, nil
+}
+
+// ReconcileStats recomputes the cached counts of every cached target from Postgres, so the
+// cache heals from missed increments.
+func (dem *DatabaseEventManager) ReconcileStats(ctx context.Context) error {
+	client := redisCache.RedisClient
+	if client == nil {
+		return nil
+	}
+
+	var reconciled, corrected int
+	iter := client.Scan(ctx, 0, statsKeyPrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		targetID := strings.TrimPrefix(iter.Val(), statsKeyPrefix)
+		_, changed, err := dem.syncCachedCounts(ctx, client, targetID, true)
+		if err != nil {
+			return err
+		}
+		reconciled++
+		if changed {
+			corrected++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("error scanning cached stats: %w", err)
+	}
+	log.Printf("Reconciled cached stats of %d targets, corrected %d", reconciled, corrected)
+	return nil
+}
+
+func sameCounts(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for eventType, count := range a {
+		if other, ok := b[eventType]; !ok || other != count {
+			return false
+		}
+	}
+	return true
+}
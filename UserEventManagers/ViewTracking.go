@@ -0,0 +1,150 @@
+package UserEventManagers
+
+import (
+	redisCache "GOLA/caches/Redis"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys used to track views. The seeded marker records that the HyperLogLog of a target
+// already holds the viewers stored in Postgres.
+const (
+	viewSeenKey         = "views:seen:%s:%s"
+	uniqueViewersKey    = "views:unique:%s"
+	uniqueSeededKey     = "views:unique-seeded:%s"
+	uniqueSeedBatchSize = 1000
+)
+
+// shouldRecordView reports whether a view is the first of the user for the target within the
+// de-duplication window. Redis is asked first; when it is unavailable, the views stored in
+// Postgres are checked, which misses views still waiting in the write-behind buffer.
+func (dem *DatabaseEventManager) shouldRecordView(ctx context.Context, userID, targetID string) bool {
+	if dem.viewDedupWindow <= 0 {
+		return true
+	}
+	if client := redisCache.RedisClient; client != nil {
+		first, err := client.SetNX(ctx, fmt.Sprintf(viewSeenKey, targetID, userID), 1, dem.viewDedupWindow).Result()
+		if err == nil {
+			return first
+		}
+		log.Printf("Redis unavailable for view de-duplication, using Postgres: %v", err)
+	}
+
+	queryCtx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+	var seen bool
+	err := dem.Db.QueryRowContext(queryCtx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_events
+			WHERE target_id = $1 AND user_id = $2 AND event_type = $3 AND created_at > $4
+		)
+	`, targetID, userID, EventTypeView, time.Now().Add(-dem.viewDedupWindow)).Scan(&seen)
+	if err != nil {
+		// Rather count a view twice than lose it.
+		log.Printf("Error checking recent views: %v", err)
+		return true
+	}
+	return !seen
+}
+
+// addUniqueViewer adds the user to the HyperLogLog of viewers of the target.
+func (dem *DatabaseEventManager) addUniqueViewer(ctx context.Context, userID, targetID string) {
+	client := redisCache.RedisClient
+	if client == nil {
+		return
+	}
+	if err := dem.seedUniqueViewers(ctx, client, targetID); err != nil {
+		log.Printf("Error seeding unique viewers of %s: %v", targetID, err)
+		return
+	}
+	if err := client.PFAdd(ctx, fmt.Sprintf(uniqueViewersKey, targetID), userID).Err(); err != nil {
+		log.Printf("Error adding unique viewer of %s: %v", targetID, err)
+	}
+}
+
+// countUniqueViewers estimates the number of distinct viewers of the target from its
+// HyperLogLog, or counts them exactly in Postgres when Redis is unavailable. Viewers whose
+// views retention archived are kept in archived_viewers and still count.
+func (dem *DatabaseEventManager) countUniqueViewers(ctx context.Context, targetID string) (int, error) {
+	if client := redisCache.RedisClient; client != nil {
+		err := dem.seedUniqueViewers(ctx, client, targetID)
+		if err == nil {
+			var count int64
+			if count, err = client.PFCount(ctx, fmt.Sprintf(uniqueViewersKey, targetID)).Result(); err == nil {
+				return int(count), nil
+			}
+		}
+		log.Printf("Redis unavailable for unique viewers, using Postgres: %v", err)
+	}
+
+	queryCtx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+	var count int
+	err := dem.Db.QueryRowContext(queryCtx, `
+		SELECT COUNT(*) FROM (
+			SELECT user_id FROM user_events WHERE target_id = $1 AND event_type = $2
+			UNION
+			SELECT user_id FROM archived_viewers WHERE target_id = $1
+		) viewers
+	`, targetID, EventTypeView).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting unique viewers: %w", err)
+	}
+	return count, nil
+}
+
+// seedUniqueViewers fills the HyperLogLog of a target with the viewers already stored in
+// Postgres, archived ones included, once per target.
+func (dem *DatabaseEventManager) seedUniqueViewers(ctx context.Context, client *redis.Client, targetID string) error {
+	marker := fmt.Sprintf(uniqueSeededKey, targetID)
+	first, err := client.SetNX(ctx, marker, 1, 0).Result()
+	if err != nil || !first {
+		return err
+	}
+	if err := dem.copyViewersToRedis(ctx, client, targetID); err != nil {
+		// Let the next call try again.
+		client.Del(ctx, marker)
+		return err
+	}
+	return nil
+}
+
+func (dem *DatabaseEventManager) copyViewersToRedis(ctx context.Context, client *redis.Client, targetID string) error {
+	queryCtx, cancel := dem.withTimeout(ctx)
+	defer cancel()
+	rows, err := dem.Db.QueryContext(queryCtx, `
+		SELECT user_id FROM user_events WHERE target_id = $1 AND event_type = $2
+		UNION
+		SELECT user_id FROM archived_viewers WHERE target_id = $1
+	`, targetID, EventTypeView)
+	if err != nil {
+		return fmt.Errorf("error querying viewers: %w", err)
+	}
+	defer rows.Close()
+
+	key := fmt.Sprintf(uniqueViewersKey, targetID)
+	batch := make([]interface{}, 0, uniqueSeedBatchSize)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return fmt.Errorf("error scanning viewer: %w", err)
+		}
+		if batch = append(batch, userID); len(batch) == uniqueSeedBatchSize {
+			if err := client.PFAdd(ctx, key, batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading viewers: %w", err)
+	}
+	if len(batch) > 0 {
+		return client.PFAdd(ctx, key, batch...).Err()
+	}
+	return nil
+}
@@ -0,0 +1,531 @@
+package Webhooks
+
+import (
+	"GOLA/DataSubjects/Erasure"
+	"GOLA/commons"
+	dbCommons "GOLA/commons/db"
+	"GOLA/commons/models"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Erasure deletes the subscriptions of the subject together with their delivery log. In the
+// deliveries to others the subject's ID gives way to the pseudonym and their comment is dropped.
+func init() {
+	Erasure.Register(
+		Erasure.Step{Name: "webhook_subscriptions", Table: "webhook_subscriptions",
+			Query: `DELETE FROM webhook_subscriptions WHERE client_id = $1`, Args: Erasure.BySubject},
+		Erasure.Step{Name: "webhook_deliveries", Table: "webhook_deliveries",
+			Query: `UPDATE webhook_deliveries
+			SET payload = jsonb_set(payload #- '{data,comment}', '{data,actor_id}', to_jsonb($2::text))
+			WHERE payload->'data'->>'actor_id' = $1`, Args: Erasure.ByPseudonym},
+	)
+}
+
+// Failed deliveries are retried after baseRetryDelay, doubling up to maxRetryDelay, until
+// maxDeliveryAttempts attempts failed.
+const (
+	maxDeliveryAttempts = 8
+	baseRetryDelay      = 30 * time.Second
+	maxRetryDelay       = time.Hour
+)
+
+// DeliverDue claims up to deliveryBatchSize deliveries per run and sends them with
+// deliveryWorkers workers. Claimed deliveries are leased for deliveryLease, so that another
+// instance only picks them up if this one died while sending.
+const (
+	deliveryBatchSize = 50
+	deliveryWorkers   = 8
+	deliveryLease     = 5 * time.Minute
+)
+
+// deliveryColumns lists the stored columns of a delivery in scan order.
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.response_status, d.last_error, d.next_attempt_at, d.delivered_at, d.replay_of, d.created_at`
+
+// PostgresWebhookManager keeps subscriptions and the delivery log in PostgreSQL. Deliveries are
+// recorded as pending and sent by DeliverDue, so that they survive restarts.
+type PostgresWebhookManager struct {
+	DB     *sql.DB
+	Sender *Sender
+}
+
+// envelope is the body POSTed to subscribers.
+type envelope struct {
+	EventID   string      `json:"event_id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Initialize connects to the database (if needed) and ensures the webhook tables exist.
+func (p *PostgresWebhookManager) Initialize() error {
+	if p.DB == nil {
+		config, err := dbCommons.LoadDBConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		db, err := dbCommons.InitializeDB(config)
+		if err != nil {
+			return err
+		}
+		p.DB = db
+	}
+	if p.Sender == nil {
+		p.Sender = NewSender()
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id UUID PRIMARY KEY,
+		client_id VARCHAR(100) NOT NULL,
+		url TEXT NOT NULL,
+		event_types TEXT[] NOT NULL,
+		secret TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS webhook_subscriptions_client_idx ON webhook_subscriptions (client_id);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id UUID PRIMARY KEY,
+		subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id VARCHAR(100) NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		response_status INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP,
+		delivered_at TIMESTAMP,
+		replay_of UUID,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (subscription_id, event_id) WHERE replay_of IS NULL;
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_log_idx ON webhook_deliveries (subscription_id, created_at, id);
+	`
+	if _, err := p.DB.Exec(query); err != nil {
+		return fmt.Errorf("error creating webhook tables: %w", err)
+	}
+	return nil
+}
+
+// CreateSubscription validates and stores a subscription, generating a secret if none is given.
+func (p *PostgresWebhookManager) CreateSubscription(ctx context.Context, clientID string, input *commons.WebhookInputModel) (*models.WebhookSubscription, string, error) {
+	if err := validateURL(input.URL); err != nil {
+		return nil, "", err
+	}
+	if len(input.EventTypes) == 0 {
+		return nil, "", fmt.Errorf("%w: event_types is required", ErrInvalidSubscription)
+	}
+	for _, eventType := range input.EventTypes {
+		if !isEventType(eventType) {
+			return nil, "", fmt.Errorf("%w: unknown event type %s", ErrInvalidSubscription, eventType)
+		}
+	}
+	secret := input.Secret
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, "", fmt.Errorf("error generating webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(raw)
+	}
+
+	sub := &models.WebhookSubscription{
+		ID:         uuid.New().String(),
+		ClientID:   clientID,
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		Secret:     secret,
+		CreatedAt:  time.Now(),
+	}
+	_, err := p.DB.ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (id, client_id, url, event_types, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, sub.ID, sub.ClientID, sub.URL, pq.Array(sub.EventTypes), sub.Secret, sub.CreatedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating webhook subscription: %w", err)
+	}
+	return sub, secret, nil
+}
+
+// ListSubscriptions returns the subscriptions of the client, oldest first.
+func (p *PostgresWebhookManager) ListSubscriptions(ctx context.Context, clientID string) ([]models.WebhookSubscription, error) {
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT id, client_id, url, event_types, secret, created_at
+		FROM webhook_subscriptions WHERE client_id = $1 ORDER BY created_at, id
+	`, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		var sub models.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.ClientID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Secret, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning webhook subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// DeleteSubscription removes a subscription of the client together with its delivery log.
+func (p *PostgresWebhookManager) DeleteSubscription(ctx context.Context, clientID, subscriptionID string) error {
+	if _, err := uuid.Parse(subscriptionID); err != nil {
+		return ErrSubscriptionNotFound
+	}
+	result, err := p.DB.ExecContext(ctx, `
+		DELETE FROM webhook_subscriptions WHERE id = $1 AND client_id = $2
+	`, subscriptionID, clientID)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook subscription: %w", err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// Enqueue records a pending delivery of the event for every matching subscription of the owner.
+func (p *PostgresWebhookManager) Enqueue(ctx context.Context, ownerID, eventID, eventType string, data interface{}) (int, error) {
+	payload, err := json.Marshal(envelope{EventID: eventID, Event: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return 0, fmt.Errorf("error encoding %s payload: %w", eventType, err)
+	}
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT id FROM webhook_subscriptions WHERE client_id = $1 AND $2 = ANY(event_types)
+	`, ownerID, eventType)
+	if err != nil {
+		return 0, fmt.Errorf("error querying webhook subscriptions: %w", err)
+	}
+	var subscriptionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		subscriptionIDs = append(subscriptionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	enqueued := 0
+	for _, subscriptionID := range subscriptionIDs {
+		// Kafka may deliver an event twice; the unique index keeps one delivery per event.
+		result, err := p.DB.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, status, next_attempt_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			ON CONFLICT (subscription_id, event_id) WHERE replay_of IS NULL DO NOTHING
+		`, uuid.New().String(), subscriptionID, eventID, eventType, payload, models.DeliveryPending)
+		if err != nil {
+			return enqueued, fmt.Errorf("error enqueueing webhook delivery: %w", err)
+		}
+		if inserted, _ := result.RowsAffected(); inserted > 0 {
+			enqueued++
+		}
+	}
+	return enqueued, nil
+}
+
+// claimedDelivery is a due delivery together with where to send it.
+type claimedDelivery struct {
+	delivery *models.WebhookDelivery
+	url      string
+	secret   string
+}
+
+// DeliverDue claims a batch of due deliveries and sends them.
+func (p *PostgresWebhookManager) DeliverDue(ctx context.Context) error {
+	rows, err := p.DB.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $3 * INTERVAL '1 second'
+			FROM due WHERE d.id = due.id
+			RETURNING `+deliveryColumns+`
+		)
+		SELECT d.*, s.url, s.secret FROM claimed d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+	`, models.DeliveryPending, deliveryBatchSize, int(deliveryLease.Seconds()))
+	if err != nil {
+		return fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	var batch []claimedDelivery
+	for rows.Next() {
+		var c claimedDelivery
+		delivery, err := scanDelivery(rows, &c.url, &c.secret)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		c.delivery = delivery
+		batch = append(batch, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	jobs := make(chan claimedDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < deliveryWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				if _, err := p.attempt(ctx, c.delivery, c.url, c.secret, true); err != nil {
+					log.Printf("Error recording webhook delivery %s: %v", c.delivery.ID, err)
+				}
+			}
+		}()
+	}
+	for _, c := range batch {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+	if len(batch) > 0 {
+		log.Printf("Attempted %d webhook deliveries", len(batch))
+	}
+	return nil
+}
+
+// attempt sends a delivery and records the outcome. Failures are scheduled for another attempt
+// when retry is set and attempts remain; otherwise the delivery fails for good.
+func (p *PostgresWebhookManager) attempt(ctx context.Context, d *models.WebhookDelivery, url, secret string, retry bool) (*models.WebhookDelivery, error) {
+	status, sendErr := p.Sender.Send(ctx, url, secret, d.ID, d.EventType, d.Payload)
+	attempts := d.Attempts + 1
+
+	var row *sql.Row
+	switch {
+	case sendErr == nil:
+		row = p.DB.QueryRowContext(ctx, `
+			UPDATE webhook_deliveries d SET status = $2, attempts = $3, response_status = $4, last_error = '',
+				next_attempt_at = NULL, delivered_at = NOW()
+			WHERE id = $1
+			RETURNING `+deliveryColumns, d.ID, models.DeliverySucceeded, attempts, status)
+	case retry && attempts < maxDeliveryAttempts:
+		row = p.DB.QueryRowContext(ctx, `
+			UPDATE webhook_deliveries d SET attempts = $2, response_status = $3, last_error = $4,
+				next_attempt_at = NOW() + $5 * INTERVAL '1 second'
+			WHERE id = $1
+			RETURNING `+deliveryColumns, d.ID, attempts, status, sendErr.Error(), int(retryDelay(attempts).Seconds()))
+	default:
+		row = p.DB.QueryRowContext(ctx, `
+			UPDATE webhook_deliveries d SET status = $2, attempts = $3, response_status = $4, last_error = $5,
+				next_attempt_at = NULL
+			WHERE id = $1
+			RETURNING `+deliveryColumns, d.ID, models.DeliveryFailed, attempts, status, sendErr.Error())
+	}
+	return scanDelivery(row)
+}
+
+// retryDelay is the wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// SendTest sends a webhook.test event to a subscription of the client. Test deliveries are
+// attempted once.
+func (p *PostgresWebhookManager) SendTest(ctx context.Context, clientID, subscriptionID string) (*models.WebhookDelivery, error) {
+	if _, err := uuid.Parse(subscriptionID); err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	var url, secret string
+	err := p.DB.QueryRowContext(ctx, `
+		SELECT url, secret FROM webhook_subscriptions WHERE id = $1 AND client_id = $2
+	`, subscriptionID, clientID).Scan(&url, &secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook subscription: %w", err)
+	}
+
+	eventID := uuid.New().String()
+	payload, _ := json.Marshal(envelope{EventID: eventID, Event: EventTest, CreatedAt: time.Now().UTC(),
+		Data: map[string]string{"subscription_id": subscriptionID}})
+	delivery, err := scanDelivery(p.DB.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries AS d (id, subscription_id, event_id, event_type, payload, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+deliveryColumns, uuid.New().String(), subscriptionID, eventID, EventTest, payload, models.DeliveryPending))
+	if err != nil {
+		return nil, err
+	}
+	return p.attempt(ctx, delivery, url, secret, false)
+}
+
+// Replay copies a delivery of the client and sends the copy right away; failures are retried
+// like any other delivery.
+func (p *PostgresWebhookManager) Replay(ctx context.Context, clientID, deliveryID string) (*models.WebhookDelivery, error) {
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return nil, ErrDeliveryNotFound
+	}
+	var url, secret string
+	original, err := scanDelivery(p.DB.QueryRowContext(ctx, `
+		SELECT `+deliveryColumns+`, s.url, s.secret
+		FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.id = $1 AND s.client_id = $2
+	`, deliveryID, clientID), &url, &secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Receivers recognise the replay by the unchanged event ID in the payload.
+	delivery, err := scanDelivery(p.DB.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries AS d (id, subscription_id, event_id, event_type, payload, status, replay_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+deliveryColumns, uuid.New().String(), original.SubscriptionID, original.EventID,
+		original.EventType, []byte(original.Payload), models.DeliveryPending, original.ID))
+	if err != nil {
+		return nil, err
+	}
+	return p.attempt(ctx, delivery, url, secret, true)
+}
+
+// ListDeliveries returns a page of the delivery log of the client, newest first.
+func (p *PostgresWebhookManager) ListDeliveries(ctx context.Context, clientID string, query DeliveryQuery) (*models.WebhookDeliveryPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidDeliveryQuery, MaxPageSize)
+	}
+	conditions := []string{"s.client_id = $1"}
+	args := []interface{}{clientID}
+	if query.SubscriptionID != "" {
+		if _, err := uuid.Parse(query.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("%w: malformed subscription_id", ErrInvalidDeliveryQuery)
+		}
+		args = append(args, query.SubscriptionID)
+		conditions = append(conditions, fmt.Sprintf("d.subscription_id = $%d", len(args)))
+	}
+	if query.Status != "" {
+		switch query.Status {
+		case models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+		default:
+			return nil, fmt.Errorf("%w: unknown status %s", ErrInvalidDeliveryQuery, query.Status)
+		}
+		args = append(args, query.Status)
+		conditions = append(conditions, fmt.Sprintf("d.status = $%d", len(args)))
+	}
+	if query.Cursor != "" {
+		createdAt, id, err := decodeDeliveryCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(d.created_at, d.id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit+1)
+
+	rows, err := p.DB.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.WebhookDeliveryPage{Deliveries: []models.WebhookDelivery{}}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Deliveries = append(page.Deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// One row more than the limit was fetched to know whether another page follows.
+	if len(page.Deliveries) > limit {
+		page.Deliveries = page.Deliveries[:limit]
+		last := page.Deliveries[limit-1]
+		page.NextCursor = encodeDeliveryCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// scanDelivery scans deliveryColumns, followed by any extra columns into extra.
+func scanDelivery(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	var replayOf sql.NullString
+	dest := []interface{}{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.DeliveredAt, &replayOf, &d.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+	}
+	d.Payload = json.RawMessage(payload)
+	if replayOf.Valid {
+		d.ReplayOf = &replayOf.String
+	}
+	return &d, nil
+}
+
+// encodeDeliveryCursor and decodeDeliveryCursor turn the position of the last delivery of a
+// page into an opaque cursor and back.
+func encodeDeliveryCursor(createdAt time.Time, id string) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeDeliveryCursor(cursor string) (time.Time, string, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidDeliveryQuery)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, "", invalid
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return time.Time{}, "", invalid
+	}
+	return time.Unix(0, unixNano).UTC(), id, nil
+}
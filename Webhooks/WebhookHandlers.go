@@ -0,0 +1,157 @@
+package Webhooks
+
+import (
+	"GOLA/Deserializers"
+	"GOLA/utils"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// WebhookHandlers exposes webhook subscriptions and their delivery log over HTTP. Every
+// handler expects the JWT middleware to have put the caller's client ID into the request
+// context; clients only ever see their own subscriptions and deliveries.
+type WebhookHandlers struct {
+	Webhooks WebhookManager
+}
+
+// subscriptionResponse is returned when a subscription is created; the secret is never shown
+// again.
+type subscriptionResponse struct {
+	Subscription interface{} `json:"subscription"`
+	Secret       string      `json:"secret"`
+}
+
+// HandleSubscriptions creates (POST), lists (GET) or deletes (DELETE ?id=) the caller's
+// webhook subscriptions.
+func (h *WebhookHandlers) HandleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		input, err := Deserializers.DeserializeWebhookInput(bodyBytes)
+		if err != nil {
+			http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+			return
+		}
+		sub, secret, err := h.Webhooks.CreateSubscription(r.Context(), clientID, input)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusCreated, subscriptionResponse{Subscription: sub, Secret: secret})
+	case http.MethodGet:
+		subs, err := h.Webhooks.ListSubscriptions(r.Context(), clientID)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		utils.WriteJSON(w, http.StatusOK, subs)
+	case http.MethodDelete:
+		if err := h.Webhooks.DeleteSubscription(r.Context(), clientID, r.URL.Query().Get("id")); err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleTest sends a test event to the subscription ?id= and returns its delivery, which
+// shows whether the endpoint accepted it.
+func (h *WebhookHandlers) HandleTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	delivery, err := h.Webhooks.SendTest(r.Context(), clientID, r.URL.Query().Get("id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, delivery)
+}
+
+// HandleDeliveries returns a page of the caller's delivery log, newest first.
+// ?subscription_id= and ?status= filter it, ?cursor= continues from a previous page and
+// ?limit= sets the page size.
+func (h *WebhookHandlers) HandleDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	query := DeliveryQuery{
+		SubscriptionID: params.Get("subscription_id"),
+		Status:         params.Get("status"),
+		Cursor:         params.Get("cursor"),
+	}
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	page, err := h.Webhooks.ListDeliveries(r.Context(), clientID, query)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// HandleReplay sends the delivery ?id= again as a new delivery and returns it.
+func (h *WebhookHandlers) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, err := utils.GetClientIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Client ID not found or invalid", http.StatusUnauthorized)
+		return
+	}
+	delivery, err := h.Webhooks.Replay(r.Context(), clientID, r.URL.Query().Get("id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, delivery)
+}
+
+// writeWebhookError maps webhook manager errors onto HTTP status codes.
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound):
+		http.Error(w, "Webhook subscription not found", http.StatusNotFound)
+	case errors.Is(err, ErrDeliveryNotFound):
+		http.Error(w, "Webhook delivery not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidSubscription), errors.Is(err, ErrInvalidDeliveryQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Webhook operation failed: %v", err)
+		http.Error(w, "Webhook operation failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,92 @@
+package Webhooks
+
+import (
+	"GOLA/commons"
+	"GOLA/commons/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Webhook event types.
+const (
+	EventImageUploaded  = "image.uploaded"
+	EventImageDeleted   = "image.deleted"
+	EventImageCommented = "image.commented"
+	// EventTest is only sent by the test endpoint.
+	EventTest = "webhook.test"
+)
+
+// EventTypes lists the event types clients can subscribe to.
+var EventTypes = []string{EventImageUploaded, EventImageDeleted, EventImageCommented}
+
+// Page sizes of the delivery log.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	// ErrSubscriptionNotFound is returned for unknown subscriptions and those of other clients.
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	// ErrDeliveryNotFound is returned for unknown deliveries and those of other clients.
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrInvalidSubscription is returned for unusable URLs and unknown event types.
+	ErrInvalidSubscription = errors.New("invalid webhook subscription")
+	// ErrInvalidDeliveryQuery is returned for malformed cursors and oversized pages.
+	ErrInvalidDeliveryQuery = errors.New("invalid webhook delivery query")
+)
+
+// DeliveryQuery selects a page of the delivery log of a client, newest first.
+type DeliveryQuery struct {
+	// SubscriptionID limits the log to one subscription.
+	SubscriptionID string
+	// Status limits the log to pending, succeeded or failed deliveries.
+	Status string
+	Cursor string
+	Limit  int
+}
+
+// WebhookManager stores webhook subscriptions and delivers events to them. Subscribers receive
+// the events of their own images only.
+type WebhookManager interface {
+	Initialize() error
+	// CreateSubscription subscribes the client and returns the subscription with its secret.
+	// The secret is only available at creation time.
+	CreateSubscription(ctx context.Context, clientID string, input *commons.WebhookInputModel) (*models.WebhookSubscription, string, error)
+	ListSubscriptions(ctx context.Context, clientID string) ([]models.WebhookSubscription, error)
+	// DeleteSubscription unsubscribes and drops the delivery log of the subscription.
+	DeleteSubscription(ctx context.Context, clientID, subscriptionID string) error
+
+	// Enqueue records a delivery of the event for every subscription of the owner to its type
+	// and returns how many it recorded. Enqueueing the same event again has no effect.
+	Enqueue(ctx context.Context, ownerID, eventID, eventType string, data interface{}) (int, error)
+	// DeliverDue sends the deliveries that are due, retrying failures with exponential
+	// backoff; run it periodically.
+	DeliverDue(ctx context.Context) error
+
+	// SendTest sends a test event to a subscription right away and returns its delivery.
+	SendTest(ctx context.Context, clientID, subscriptionID string) (*models.WebhookDelivery, error)
+	// Replay sends the payload of an earlier delivery again, right away, as a new delivery.
+	Replay(ctx context.Context, clientID, deliveryID string) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, clientID string, query DeliveryQuery) (*models.WebhookDeliveryPage, error)
+}
+
+// GetWebhookManager returns an instance of the requested webhook manager.
+func GetWebhookManager(storageType string) (WebhookManager, error) {
+	switch storageType {
+	case "postgres":
+		return &PostgresWebhookManager{Sender: NewSender()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported webhook storage type: %s", storageType)
+	}
+}
+
+func isEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package Webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of webhook requests. Receivers verify a request by computing Sign over the
+// timestamp and body with their secret and comparing it to the signature header.
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// sendTimeout bounds every delivery attempt.
+const sendTimeout = 10 * time.Second
+
+// errPrivateTarget is returned for URLs resolving to loopback, private or link-local addresses.
+var errPrivateTarget = errors.New("webhook URL resolves to a private address")
+
+// Sign returns the signature of a webhook request: "sha256=" and the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender POSTs signed payloads to subscribers.
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender returns a sender that refuses to connect to private addresses, so subscriptions
+// cannot reach internal services, unless WEBHOOK_ALLOW_PRIVATE_TARGETS is "true". Redirects are
+// not followed.
+func NewSender() *Sender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") != "true" {
+		dialer.Control = rejectPrivateAddress
+	}
+	return &Sender{Client: &http.Client{
+		Timeout:   sendTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// rejectPrivateAddress runs after name resolution, so it also catches public names of private
+// addresses.
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return errPrivateTarget
+	}
+	return nil
+}
+
+// Send POSTs the payload and returns the response status, or 0 when no response arrived. Only
+// 2xx responses count as delivered.
+func (s *Sender) Send(ctx context.Context, target, secret, deliveryID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GOLA-Webhooks/1.0")
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// validateURL accepts absolute http and https URLs.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	return nil
+}
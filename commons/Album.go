@@ -0,0 +1,26 @@
+package commons
+
+/* input for creating or updating an album; nil fields are left unchanged on update */
+type AlbumInputModel struct {
+	Name         *string `json:"name,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	CoverImageID *string `json:"cover_image_id,omitempty"`
+	IsPrivate    *bool   `json:"is_private,omitempty"`
+}
+
+/* input for adding, removing or reordering images of an album */
+type AlbumImagesInputModel struct {
+	AlbumID  string   `json:"album_id"`
+	ImageID  string   `json:"image_id,omitempty"`
+	ImageIDs []string `json:"image_ids,omitempty"`
+}
+
+// AlbumStats aggregates the engagement of every image in an album.
+type AlbumStats struct {
+	ImageCount  int `json:"image_count"`
+	Likes       int `json:"likes"`
+	Dislikes    int `json:"dislikes"`
+	Views       int `json:"views"`
+	Comments    int `json:"comments"`
+	UniqueUsers int `json:"unique_users"`
+}
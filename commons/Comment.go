@@ -0,0 +1,15 @@
+package commons
+
+/* input for posting a comment or a reply; "comment" matches the field of EventInputModel */
+type CommentInputModel struct {
+	TargetID string `json:"target_id"`
+	ParentID *int64 `json:"parent_id,omitempty"`
+	Content  string `json:"comment"`
+}
+
+/* input for editing a comment or changing its moderation state */
+type CommentUpdateInputModel struct {
+	ID      int64  `json:"id"`
+	Content string `json:"comment,omitempty"`
+	State   string `json:"state,omitempty"`
+}
@@ -0,0 +1,25 @@
+package commons
+
+import "time"
+
+// EngagementBucket holds the engagement of one hour or one day. Likes, dislikes, views and
+// comments count the events recorded in the bucket; unique users counts the distinct users
+// behind them.
+type EngagementBucket struct {
+	Start       time.Time `json:"start"`
+	Likes       int       `json:"likes"`
+	Dislikes    int       `json:"dislikes"`
+	Views       int       `json:"views"`
+	Comments    int       `json:"comments"`
+	UniqueUsers int       `json:"unique_users"`
+}
+
+// EngagementSeries is the engagement of an image, or of the portfolio of an owner, over time.
+type EngagementSeries struct {
+	Scope       string             `json:"scope"`
+	ID          string             `json:"id"`
+	Granularity string             `json:"granularity"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Buckets     []EngagementBucket `json:"buckets"`
+}
@@ -8,6 +8,8 @@ type EventInputModel struct {
 	TargetID string `json:"target_id"`
 	// Comment is optional and used only for comment events.
 	Comment string `json:"comment,omitempty"`
+	// EventType selects the registered event type on endpoints that accept any type.
+	EventType string `json:"event_type,omitempty"`
 }
 
 // Event represents an event record stored in the database.
@@ -28,4 +30,21 @@ type EventStats struct {
 	Dislikes int `json:"dislikes"`
 	Views    int `json:"views"`
 	Comments int `json:"comments"`
+	// UniqueViews is the number of distinct viewers; Views counts every de-duplicated view.
+	UniqueViews int `json:"unique_views"`
+	// Counts holds the count of every registered event type, keyed by type name.
+	Counts map[string]int `json:"counts"`
+	// MyReactions are the requesting user's current reactions, if any.
+	MyReactions []string `json:"my_reactions,omitempty"`
+}
+
+// NewEventStats builds stats from per-type counts, filling in the built-in fields.
+func NewEventStats(counts map[string]int) *EventStats {
+	return &EventStats{
+		Likes:    counts["user-like"],
+		Dislikes: counts["user-dislike"],
+		Views:    counts["user-view"],
+		Comments: counts["user-comment"],
+		Counts:   counts,
+	}
 }
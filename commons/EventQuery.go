@@ -0,0 +1,23 @@
+package commons
+
+import "time"
+
+// EventFilter selects user events. Empty fields match every event; From is inclusive and To
+// exclusive. Events are ordered newest first unless Ascending is set, and Cursor continues
+// after the last event of a previous page with the same filter.
+type EventFilter struct {
+	UserID     string
+	TargetID   string
+	EventTypes []string
+	From       time.Time
+	To         time.Time
+	Ascending  bool
+	Cursor     string
+	Limit      int
+}
+
+// EventPage is one page of events; NextCursor is empty on the last page.
+type EventPage struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
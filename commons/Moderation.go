@@ -0,0 +1,15 @@
+package commons
+
+/* input for reporting a comment, image title or image description */
+type ModerationReportInputModel struct {
+	Kind      string `json:"kind"`
+	ContentID string `json:"content_id"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+/* input for reviewing a moderation item; decision is "approve" or "reject" */
+type ModerationReviewInputModel struct {
+	ID       int64  `json:"id"`
+	Decision string `json:"decision"`
+	Note     string `json:"note,omitempty"`
+}
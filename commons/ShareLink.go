@@ -0,0 +1,12 @@
+package commons
+
+import "time"
+
+/* input for creating a share link; every restriction is optional */
+type ShareLinkInputModel struct {
+	ImageID          string     `json:"image_id"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	ExpiresInSeconds int        `json:"expires_in_seconds,omitempty"`
+	MaxViews         *int       `json:"max_views,omitempty"`
+	Password         string     `json:"password,omitempty"`
+}
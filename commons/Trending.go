@@ -0,0 +1,14 @@
+package commons
+
+// TrendingImage is an image of the trending feed with its score.
+type TrendingImage struct {
+	ImageID string  `json:"image_id"`
+	Score   float64 `json:"score"`
+}
+
+// TrendingPage is a page of the trending feed of a window, optionally limited to a tag.
+type TrendingPage struct {
+	Window string          `json:"window"`
+	Tag    string          `json:"tag,omitempty"`
+	Images []TrendingImage `json:"images"`
+}
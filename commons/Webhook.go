@@ -0,0 +1,8 @@
+package commons
+
+/* input for subscribing to webhooks; a secret is generated when none is given */
+type WebhookInputModel struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret,omitempty"`
+}
@@ -1,5 +1,12 @@
 package db
 
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
 type DBConfig struct {
 	Host     string
 	Port     int
@@ -7,4 +14,39 @@ type DBConfig struct {
 	Password string
 	DbName   string
 	SSLMode  string
+
+	// Connection pool sizing; zero values keep the database/sql defaults.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// LoadDBConfigFromEnv builds a DBConfig from the DB_* environment variables.
+func LoadDBConfigFromEnv() (DBConfig, error) {
+	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
+	if err != nil {
+		return DBConfig{}, fmt.Errorf("invalid DB_PORT value: %w", err)
+	}
+	config := DBConfig{
+		Host:     os.Getenv("DB_HOST"),
+		Port:     port,
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		DbName:   os.Getenv("DB_NAME"),
+		SSLMode:  os.Getenv("DB_SSLMODE"),
+
+		MaxOpenConns:    25,
+		MaxIdleConns:    25,
+		ConnMaxLifetime: 30 * time.Minute,
+	}
+	if value, err := strconv.Atoi(os.Getenv("DB_MAX_OPEN_CONNS")); err == nil {
+		config.MaxOpenConns = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNS")); err == nil {
+		config.MaxIdleConns = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("DB_CONN_MAX_LIFETIME")); err == nil {
+		config.ConnMaxLifetime = value
+	}
+	return config, nil
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Album struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	OwnerID      string    `gorm:"not null" json:"owner_id"`
+	Name         string    `gorm:"not null" json:"name"`
+	Description  string    `json:"description"`
+	CoverImageID string    `json:"cover_image_id,omitempty"`
+	IsPrivate    bool      `json:"is_private"`
+	ImageCount   int       `json:"image_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type AlbumImage struct {
+	AlbumID  uuid.UUID `gorm:"type:uuid;primary_key;" json:"album_id"`
+	ImageID  string    `gorm:"primary_key" json:"image_id"`
+	Position int       `json:"position"`
+	AddedAt  time.Time `json:"added_at"`
+}
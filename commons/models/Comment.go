@@ -1,16 +1,45 @@
 package models
 
-import (
-	"github.com/google/uuid"
-	"time"
+import "time"
+
+// Comment states. Hidden comments are only shown to their author and to moderators; flagged
+// comments stay visible until a moderator reviews them.
+const (
+	CommentVisible = "visible"
+	CommentHidden  = "hidden"
+	CommentFlagged = "flagged"
 )
 
 type Comment struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	ImageID   uuid.UUID `gorm:"type:uuid;not null" json:"image_id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Username  string    `json:"username"`
+	ID       int64  `gorm:"primary_key" json:"id"`
+	TargetID string `gorm:"not null" json:"target_id"`
+	// ParentID is the comment replied to; nil for top-level comments.
+	ParentID *int64 `json:"parent_id,omitempty"`
+	// RootID is the top-level comment of the thread; nil for top-level comments.
+	RootID   *int64 `json:"root_id,omitempty"`
+	AuthorID string `gorm:"not null" json:"author_id"`
+	Content  string `json:"content"`
+	State    string `json:"state"`
+	// Deleted comments are kept, without content, while they still have replies.
+	Deleted    bool       `gorm:"-" json:"deleted"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	ReplyCount int        `gorm:"-" json:"reply_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Replies    []Comment  `gorm:"-" json:"replies,omitempty"`
+}
+
+// CommentRevision is a previous version of an edited comment.
+type CommentRevision struct {
+	CommentID int64     `json:"comment_id"`
+	Revision  int       `json:"revision"`
 	Content   string    `json:"content"`
+	EditedBy  string    `json:"edited_by"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CommentPage is one page of comments; NextCursor is empty on the last page.
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
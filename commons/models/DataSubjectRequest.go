@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Kinds of data subject requests.
+const (
+	DataSubjectExport  = "export"
+	DataSubjectErasure = "erasure"
+)
+
+// States of a data subject request.
+const (
+	RequestPending   = "pending"
+	RequestRunning   = "running"
+	RequestCompleted = "completed"
+	RequestFailed    = "failed"
+)
+
+// DataSubjectRequest is a request to export or erase the data of a user.
+type DataSubjectRequest struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	SubjectID   string `json:"subject_id"`
+	RequestedBy string `json:"requested_by"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	// Summary counts what the request exported or erased, per kind of data.
+	Summary     map[string]int `json:"summary,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	// Audit is only filled in for admins.
+	Audit []DataSubjectAuditEntry `json:"audit,omitempty"`
+}
+
+// DataSubjectAuditEntry records a step of a data subject request.
+type DataSubjectAuditEntry struct {
+	Action    string         `json:"action"`
+	ActorID   string         `json:"actor_id"`
+	Detail    map[string]int `json:"detail,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
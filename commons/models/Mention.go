@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Mention is an @mention of a user in a comment or an image description.
+type Mention struct {
+	ID int64 `json:"id"`
+	// Kind is "comment" or "image_description".
+	Kind string `json:"kind"`
+	// SourceID is the comment ID for comments and the image ID for descriptions.
+	SourceID        string    `json:"source_id"`
+	ImageID         string    `json:"image_id"`
+	AuthorID        string    `json:"author_id"`
+	MentionedUserID string    `json:"mentioned_user_id"`
+	Text            string    `json:"text"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// MentionPage is one page of mentions, newest first; NextCursor is empty on the last page.
+type MentionPage struct {
+	Mentions   []Mention `json:"mentions"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// HashtagImage is an image carrying a hashtag, in its description, its tags or its comments.
+type HashtagImage struct {
+	ImageID string `json:"image_id"`
+	Title   string `json:"title,omitempty"`
+	// LastUsedAt is when the hashtag was last written on the image; nil for images that only
+	// carry it as a tag.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HashtagImagePage is one page of the images of a hashtag; NextCursor is empty on the last page.
+type HashtagImagePage struct {
+	Hashtag    string         `json:"hashtag"`
+	Images     []HashtagImage `json:"images"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Review states of moderation items.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// ModerationItem is a piece of user-written content awaiting, or past, review by an admin.
+type ModerationItem struct {
+	ID int64 `json:"id"`
+	// Kind is "comment", "image_title" or "image_description".
+	Kind string `json:"kind"`
+	// ContentID is the comment ID for comments and the image ID otherwise.
+	ContentID string `json:"content_id"`
+	ImageID   string `json:"image_id"`
+	AuthorID  string `json:"author_id"`
+	// Text is the content as it was when it was queued or last reported.
+	Text string `json:"text"`
+	// Action is what moderation did meanwhile: "queued" content is shown, "hidden" content is
+	// not (held back titles and descriptions are not applied) until it is approved.
+	Action      string     `json:"action"`
+	Reasons     []string   `json:"reasons"`
+	ReportCount int        `json:"report_count"`
+	Status      string     `json:"status"`
+	ReviewerID  string     `json:"reviewer_id,omitempty"`
+	ReviewNote  string     `json:"review_note,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ModerationQueuePage is one page of the review queue; NextCursor is empty on the last page.
+type ModerationQueuePage struct {
+	Items      []ModerationItem `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Notification tells the owner of an image about engagement on it. Engagement of the same
+// kind on the same image is merged into one notification until the recipient reads it.
+type Notification struct {
+	ID       int64  `json:"id"`
+	Kind     string `json:"kind"`
+	TargetID string `json:"target_id"`
+	// Actors are the most recent users behind the notification, newest first; ActorCount
+	// counts all of them.
+	Actors     []string `json:"actors"`
+	ActorCount int      `json:"actor_count"`
+	// Excerpt is the start of the latest comment, for comments and mentions.
+	Excerpt   string    `json:"excerpt,omitempty"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationPage is one page of notifications; NextCursor is empty on the last page.
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type ShareLink struct {
+	ID           string     `gorm:"primary_key" json:"id"`
+	ImageID      string     `gorm:"not null" json:"image_id"`
+	OwnerID      string     `gorm:"not null" json:"owner_id"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxViews     *int       `json:"max_views,omitempty"`
+	ViewCount    int        `json:"view_count"`
+	PasswordHash string     `json:"-"`
+	HasPassword  bool       `gorm:"-" json:"has_password"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription asks for events on the images of ClientID to be POSTed to URL.
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	ClientID   string    `json:"client_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to one subscription.
+type WebhookDelivery struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	// Payload is the body POSTed to the subscriber.
+	Payload  json.RawMessage `json:"payload"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	// ResponseStatus is the HTTP status of the last attempt; 0 when no response was received.
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	// ReplayOf is the delivery this one repeats.
+	ReplayOf  *string   `json:"replay_of,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDeliveryPage is one page of the delivery log; NextCursor is empty on the last page.
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
@@ -37,6 +37,10 @@ const (
 	IMAGE_UPDATE       = "ImageUpdate"
 	IMAGE_DELETE       = "ImageDelete"
 	IMAGE_STATS_UPDATE = "ImageStatsUpdate"
+	// IMAGE_METADATA_UPDATE is published after image metadata has been changed.
+	IMAGE_METADATA_UPDATE = "ImageMetadataUpdate"
+	// IMAGE_UPLOADED is published after an upload was stored; it names the image and its owner.
+	IMAGE_UPLOADED = "ImageUploaded"
 )
 
 // Error Messages for image events.
@@ -45,3 +49,11 @@ const (
 	ErrorImageUpdate      = "Error updating image"
 	ErrorImageStatsUpdate = "Error updating image statistics"
 )
+
+// User interaction event constants. Their payload is an EventInputModel naming the event type.
+const (
+	// USER_EVENT_ADD records a like, dislike, view, comment or other registered event.
+	USER_EVENT_ADD = "UserEventAdd"
+	// USER_EVENT_REMOVE retracts a reaction.
+	USER_EVENT_REMOVE = "UserEventRemove"
+)
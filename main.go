@@ -1,25 +1,44 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
+	albumManagers "GOLA/AlbumManagers"
+	dataSubjects "GOLA/DataSubjects"
 	"GOLA/Deserializers"
 	"GOLA/Handlers/auth"
 	metadataManager "GOLA/ImageManagers/Metadata"
 	rawStoreManager "GOLA/ImageManagers/RawStore"
+	"GOLA/Jobs"
+	liveUpdates "GOLA/LiveUpdates"
+	mentions "GOLA/Mentions"
 	"GOLA/Middleware/Authenticators/jwt"
 	"GOLA/Middleware/Messengers/KafkaOperations"
 	"GOLA/Middleware/MetricsCollectors/Prometheus"
 	"GOLA/Middleware/RateLimiters"
+	moderation "GOLA/Moderation"
+	notifications "GOLA/Notifications"
+	searchIndexers "GOLA/SearchIndexers"
+	shareLinkManagers "GOLA/ShareLinkManagers"
+	statsRollups "GOLA/StatsRollups"
+	trending "GOLA/Trending"
 	userEventsManager "GOLA/UserEventManagers"
+	webhooks "GOLA/Webhooks"
 	redisCache "GOLA/caches/Redis"
 	"GOLA/constants"
+	"GOLA/utils"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/time/rate"
@@ -53,11 +72,152 @@ func main() {
 	err = imageMetadataManager.Initialize()
 	errorHandler(err, "ERROR INITIALIZING IMAGE METADATA MANAGER")
 
-	// Initialize user events manager (e.g. PostgreSQL).
-	userEventsStore := os.Getenv("USER_EVENTS_STORE") // e.g. "postgres"
+	// Share the managers with the HTTP image handlers.
+	KafkaOperations.SetManagers(imageStoreManager, imageMetadataManager)
+
+	// Initialize Redis, which the user events manager uses for view tracking.
+	redisCache.InitRedis()
+
+	// Initialize user events manager (e.g. PostgreSQL, or in memory for local runs).
+	userEventsStore := os.Getenv("USER_EVENTS_STORE") // e.g. "postgresDb" or "memory"
 	eventsManager, err := userEventsManager.GetEventManager(userEventsStore)
 	errorHandler(err, "ERROR CREATING USER EVENTS STORE")
 	eventsManager.Initialize()
+	KafkaOperations.SetEventManager(eventsManager)
+
+	// Live updates of images over Server-Sent Events (e.g. "redis"); disabled when unset.
+	var liveFeed liveUpdates.LiveFeed
+	if liveFeedType := os.Getenv("LIVE_FEED"); liveFeedType != "" {
+		liveFeed, err = liveUpdates.GetLiveFeed(liveFeedType)
+		errorHandler(err, "ERROR CREATING LIVE FEED")
+	}
+
+	// Initialize comment manager (e.g. PostgreSQL).
+	commentStoreType := os.Getenv("COMMENT_STORE") // e.g. "postgres"
+	commentManager, err := userEventsManager.GetCommentManager(commentStoreType)
+	errorHandler(err, "ERROR CREATING COMMENT MANAGER")
+	err = commentManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING COMMENT MANAGER")
+	commentHandlers := &userEventsManager.CommentHandlers{
+		Comments:             commentManager,
+		ImageMetadataManager: imageMetadataManager,
+		EventManager:         eventsManager,
+		Publisher:            KafkaOperations.InteractionPublisher{},
+		Live:                 liveFeed,
+	}
+
+	// Initialize album manager (e.g. PostgreSQL).
+	albumStoreType := os.Getenv("ALBUM_STORE") // e.g. "postgres"
+	albumManager, err := albumManagers.GetAlbumManager(albumStoreType)
+	errorHandler(err, "ERROR CREATING ALBUM MANAGER")
+	err = albumManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING ALBUM MANAGER")
+	albumHandlers := &albumManagers.AlbumHandlers{
+		Albums:               albumManager,
+		ImageMetadataManager: imageMetadataManager,
+	}
+
+	// Initialize engagement rollup manager (e.g. PostgreSQL).
+	rollupStoreType := os.Getenv("ROLLUP_STORE") // e.g. "postgres"
+	rollupManager, err := statsRollups.GetRollupManager(rollupStoreType)
+	errorHandler(err, "ERROR CREATING ROLLUP MANAGER")
+	err = rollupManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING ROLLUP MANAGER")
+	rollupHandlers := &statsRollups.RollupHandlers{
+		Rollups:              rollupManager,
+		ImageMetadataManager: imageMetadataManager,
+	}
+
+	// Initialize trending manager (e.g. PostgreSQL, with rankings cached in Redis).
+	trendingStoreType := os.Getenv("TRENDING_STORE") // e.g. "postgres"
+	trendingManager, err := trending.GetTrendingManager(trendingStoreType)
+	errorHandler(err, "ERROR CREATING TRENDING MANAGER")
+	err = trendingManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING TRENDING MANAGER")
+	trendingHandlers := &trending.TrendingHandlers{Trending: trendingManager}
+
+	// Initialize data subject request manager (e.g. PostgreSQL) for user data exports and erasures.
+	dataSubjectStoreType := os.Getenv("DATA_SUBJECT_STORE") // e.g. "postgres"
+	dataSubjectManager, err := dataSubjects.GetDataSubjectManager(dataSubjectStoreType, dataSubjects.Stores{
+		Events:   eventsManager,
+		Images:   imageStoreManager,
+		Metadata: imageMetadataManager,
+		OnImageErased: func(imageID, actorID string) {
+			KafkaOperations.SendKafkaEvent(constants.IMAGE_DELETE, nil, nil,
+				map[string]string{"image_id": imageID}, "/api/data-requests", actorID)
+		},
+	})
+	errorHandler(err, "ERROR CREATING DATA SUBJECT MANAGER")
+	err = dataSubjectManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING DATA SUBJECT MANAGER")
+	dataSubjectHandlers := &dataSubjects.DataSubjectHandlers{Requests: dataSubjectManager}
+
+	// Initialize share link manager (e.g. PostgreSQL).
+	shareLinkStoreType := os.Getenv("SHARE_LINK_STORE") // e.g. "postgres"
+	shareLinkManager, err := shareLinkManagers.GetShareLinkManager(shareLinkStoreType)
+	errorHandler(err, "ERROR CREATING SHARE LINK MANAGER")
+	err = shareLinkManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING SHARE LINK MANAGER")
+	shareLinkHandlers := &shareLinkManagers.ShareLinkHandlers{
+		ShareLinks:           shareLinkManager,
+		ImageMetadataManager: imageMetadataManager,
+		ImageStoreManager:    imageStoreManager,
+		EventManager:         eventsManager,
+		Publisher:            KafkaOperations.InteractionPublisher{},
+	}
+
+	// Initialize notification manager (e.g. PostgreSQL).
+	notificationStoreType := os.Getenv("NOTIFICATION_STORE") // e.g. "postgres"
+	notificationManager, err := notifications.GetNotificationManager(notificationStoreType)
+	errorHandler(err, "ERROR CREATING NOTIFICATION MANAGER")
+	err = notificationManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING NOTIFICATION MANAGER")
+	notificationHandlers := &notifications.NotificationHandlers{Notifications: notificationManager}
+
+	// Initialize mention and hashtag manager (e.g. PostgreSQL).
+	mentionStoreType := os.Getenv("MENTION_STORE") // e.g. "postgres"
+	mentionManager, err := mentions.GetMentionManager(mentionStoreType)
+	errorHandler(err, "ERROR CREATING MENTION MANAGER")
+	err = mentionManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING MENTION MANAGER")
+	commentHandlers.Mentions = mentionManager
+	mentionHandlers := &mentions.MentionHandlers{Mentions: mentionManager}
+
+	// Initialize webhook manager (e.g. PostgreSQL).
+	webhookStoreType := os.Getenv("WEBHOOK_STORE") // e.g. "postgres"
+	webhookManager, err := webhooks.GetWebhookManager(webhookStoreType)
+	errorHandler(err, "ERROR CREATING WEBHOOK MANAGER")
+	err = webhookManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING WEBHOOK MANAGER")
+	webhookHandlers := &webhooks.WebhookHandlers{Webhooks: webhookManager}
+
+	// Initialize moderation (e.g. PostgreSQL) with the rules of MODERATION_CONFIG, a JSON file
+	// overriding the default rules.
+	moderationConfig, err := moderation.LoadConfig(os.Getenv("MODERATION_CONFIG"))
+	errorHandler(err, "ERROR LOADING MODERATION CONFIG")
+	moderationPipeline, err := moderation.NewPipeline(moderationConfig)
+	if err != nil {
+		log.Fatalf("Invalid moderation config: %v", err)
+	}
+	moderationStoreType := os.Getenv("MODERATION_STORE") // e.g. "postgres"
+	moderationManager, err := moderation.GetModerationManager(moderationStoreType)
+	errorHandler(err, "ERROR CREATING MODERATION MANAGER")
+	err = moderationManager.Initialize()
+	errorHandler(err, "ERROR INITIALIZING MODERATION MANAGER")
+	moderator := &moderation.Moderator{
+		Pipeline:             moderationPipeline,
+		Queue:                moderationManager,
+		Comments:             commentManager,
+		ImageMetadataManager: imageMetadataManager,
+		ReportThreshold:      moderationConfig.ReportThreshold,
+		OnMetadataChanged: func(imageID, actorID string) {
+			KafkaOperations.SendKafkaEvent(constants.IMAGE_METADATA_UPDATE, nil, nil,
+				map[string]string{"image_id": imageID}, "/api/moderation/review", actorID)
+		},
+	}
+	commentHandlers.Moderator = moderator
+	KafkaOperations.SetMetadataScreener(moderator)
+	moderationHandlers := &moderation.ModerationHandlers{Moderator: moderator}
 
 	// Kafka configuration.
 	kafkaBrokerAddress := os.Getenv("KAFKA_BROKER_ADDRESS")
@@ -77,17 +237,63 @@ func main() {
 		EventManager:         eventsManager,        // Task/event manager instance.
 		ImageMetadataManager: imageMetadataManager, // Image metadata manager.
 		ImageStoreManager:    imageStoreManager,    // Image store manager.
+		LiveFeed:             liveFeed,             // Receives stat changes for live updates.
+		RetryTopic:           os.Getenv("KAFKA_RETRY_TOPIC"),
+		DeadLetterTopic:      os.Getenv("KAFKA_DEAD_LETTER_TOPIC"),
 	}
 	go KafkaOperations.StartKafkaConsumer(kafkaConfig)
+	// Events whose handling failed are retried from their own topic, after a delay.
+	go KafkaOperations.StartRetryConsumer(kafkaConfig)
+
+	// Notifications are created from user interactions under their own consumer group.
+	go KafkaOperations.StartNotificationConsumer(KafkaOperations.NotificationConsumerConfig{
+		BrokerAddress:        kafkaBrokerAddress,
+		Topic:                kafkaTopic,
+		GroupID:              os.Getenv("KAFKA_NOTIFICATION_CONSUMER_GROUP_ID"),
+		Notifications:        notificationManager,
+		ImageMetadataManager: imageMetadataManager,
+	})
+
+	// Mentions and hashtags of image descriptions are indexed under their own consumer group.
+	go KafkaOperations.StartMentionConsumer(KafkaOperations.MentionConsumerConfig{
+		BrokerAddress:        kafkaBrokerAddress,
+		Topic:                kafkaTopic,
+		GroupID:              os.Getenv("KAFKA_MENTION_CONSUMER_GROUP_ID"),
+		Mentions:             mentionManager,
+		ImageMetadataManager: imageMetadataManager,
+	})
+
+	// Webhook deliveries are recorded from image and comment events under their own consumer group.
+	go KafkaOperations.StartWebhookConsumer(KafkaOperations.WebhookConsumerConfig{
+		BrokerAddress:        kafkaBrokerAddress,
+		Topic:                kafkaTopic,
+		GroupID:              os.Getenv("KAFKA_WEBHOOK_CONSUMER_GROUP_ID"),
+		Webhooks:             webhookManager,
+		ImageMetadataManager: imageMetadataManager,
+	})
+
+	// Search indexer (e.g. "elasticsearch" or "opensearch"); indexing is disabled when unset.
+	searchIndexerType := os.Getenv("SEARCH_INDEXER_TYPE")
+	if searchIndexerType != "" {
+		searchIndexer, err := searchIndexers.GetSearchIndexer(searchIndexerType)
+		errorHandler(err, "ERROR CREATING SEARCH INDEXER")
+		if err == nil {
+			errorHandler(searchIndexer.Initialize(), "ERROR INITIALIZING SEARCH INDEXER")
+			go KafkaOperations.StartSearchIndexConsumer(KafkaOperations.SearchIndexConsumerConfig{
+				BrokerAddress:        kafkaBrokerAddress,
+				Topic:                kafkaTopic,
+				GroupID:              os.Getenv("KAFKA_SEARCH_CONSUMER_GROUP_ID"),
+				Indexer:              searchIndexer,
+				ImageMetadataManager: imageMetadataManager,
+			})
+		}
+	}
 
 	// Rate limiting.
 	rateLimit, _ := strconv.Atoi(os.Getenv("RATE_LIMITER_LIMIT"))
 	rateBurst, _ := strconv.Atoi(os.Getenv("RATE_LIMITER_BURST"))
 	rateLimiter := RateLimiters.NewRateLimiter(rate.Limit(rateLimit), rateBurst)
 
-	// Initialize Redis cache (if needed elsewhere).
-	redisCache.InitRedis()
-
 	go Prometheus.ExposeMetrics()
 
 	// Authentication endpoint.
@@ -123,6 +329,11 @@ func main() {
 								return
 							}
 
+							// Private images are only served to their owner.
+							if _, ok := metadataManager.AuthorizeImageRequest(w, r, imageMetadataManager, imageID, false); !ok {
+								return
+							}
+
 							imageBytes, err := imageStoreManager.FetchImage(imageID)
 							if err != nil {
 								http.Error(w, "Image not found", http.StatusNotFound)
@@ -170,9 +381,8 @@ func main() {
 								http.Error(w, "Image ID is required", http.StatusBadRequest)
 								return
 							}
-							meta, err := imageMetadataManager.GetImageMetadata(imageID)
-							if err != nil {
-								http.Error(w, "Error fetching metadata", http.StatusInternalServerError)
+							meta, ok := metadataManager.AuthorizeImageRequest(w, r, imageMetadataManager, imageID, false)
+							if !ok {
 								return
 							}
 							w.Header().Set("Content-Type", "application/json")
@@ -190,10 +400,38 @@ func main() {
 								http.Error(w, "Image ID is required", http.StatusBadRequest)
 								return
 							}
-							if err := imageMetadataManager.SetImageMetadata(payload.ImageID, payload.Metadata); err != nil {
+							// Only the owner may change metadata, and ownership itself cannot be reassigned.
+							current, ok := metadataManager.AuthorizeImageRequest(w, r, imageMetadataManager, payload.ImageID, true)
+							if !ok {
+								return
+							}
+							if payload.Metadata == nil {
+								payload.Metadata = map[string]string{}
+							}
+							payload.Metadata[metadataManager.OwnerIDKey] = current[metadataManager.OwnerIDKey]
+							if _, ok := payload.Metadata[metadataManager.IsPrivateKey]; !ok {
+								payload.Metadata[metadataManager.IsPrivateKey] = current[metadataManager.IsPrivateKey]
+							}
+							clientID, _ := utils.GetClientIDFromContext(r.Context())
+							// Titles and descriptions the moderation rules object to are held back for review.
+							screened, err := moderator.ScreenMetadata(r.Context(), payload.ImageID, clientID, current, payload.Metadata)
+							if errors.Is(err, moderation.ErrRateLimited) {
+								http.Error(w, err.Error(), http.StatusTooManyRequests)
+								return
+							}
+							if err != nil {
+								http.Error(w, "Error screening metadata", http.StatusInternalServerError)
+								return
+							}
+							// Hashtags of the description become tags of the image.
+							mentions.MergeHashtagTags(screened)
+							change := metadataManager.MetadataChange{ActorID: clientID, Source: metadataManager.SourceHTTP}
+							if err := imageMetadataManager.SetImageMetadata(payload.ImageID, screened, change); err != nil {
 								http.Error(w, "Error updating metadata", http.StatusInternalServerError)
 								return
 							}
+							KafkaOperations.SendKafkaEvent(constants.IMAGE_METADATA_UPDATE, nil, nil,
+								map[string]string{"image_id": payload.ImageID}, r.URL.Path, clientID)
 							w.WriteHeader(http.StatusOK)
 							fmt.Fprintln(w, "Metadata updated successfully")
 						default:
@@ -205,38 +443,39 @@ func main() {
 		),
 	)
 
-	// Endpoint to add a like.
-	http.Handle("/images/like",
+	// IMAGE METADATA HISTORY endpoint: the audit trail of metadata changes (owner only).
+	http.Handle("/images/metadata/history",
 		Prometheus.CountRequests(
 			rateLimiter.Apply(
 				jwt.AuthenticateJWT(
 					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						if r.Method != http.MethodPost {
+						if r.Method != http.MethodGet {
 							http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 							return
 						}
-						// Read the request body and use the deserializer.
-						bodyBytes, err := io.ReadAll(r.Body)
-						if err != nil {
-							http.Error(w, "Unable to read request body", http.StatusBadRequest)
+						imageID := r.URL.Query().Get("id")
+						if imageID == "" {
+							http.Error(w, "Image ID is required", http.StatusBadRequest)
 							return
 						}
-						eventInput, err := Deserializers.DeserializeEventInput(bodyBytes)
+						if _, ok := metadataManager.AuthorizeImageRequest(w, r, imageMetadataManager, imageID, true); !ok {
+							return
+						}
+						history, err := imageMetadataManager.GetImageMetadataHistory(imageID)
 						if err != nil {
-							http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+							http.Error(w, "Error fetching metadata history", http.StatusInternalServerError)
 							return
 						}
-						addedEvent := eventsManager.AddLike(eventInput)
 						w.Header().Set("Content-Type", "application/json")
-						json.NewEncoder(w).Encode(addedEvent)
+						json.NewEncoder(w).Encode(history)
 					}),
 				),
 			),
 		),
 	)
 
-	// Endpoint to add a dislike.
-	http.Handle("/images/dislike",
+	// IMAGE METADATA REVERT endpoint: restore the metadata of a prior revision (owner only).
+	http.Handle("/images/metadata/revert",
 		Prometheus.CountRequests(
 			rateLimiter.Apply(
 				jwt.AuthenticateJWT(
@@ -245,28 +484,88 @@ func main() {
 							http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 							return
 						}
-						bodyBytes, err := io.ReadAll(r.Body)
+						var payload struct {
+							ImageID  string `json:"image_id"`
+							Revision int    `json:"revision"`
+						}
+						if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+							http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+							return
+						}
+						if payload.ImageID == "" || payload.Revision <= 0 {
+							http.Error(w, "Image ID and revision are required", http.StatusBadRequest)
+							return
+						}
+						if _, ok := metadataManager.AuthorizeImageRequest(w, r, imageMetadataManager, payload.ImageID, true); !ok {
+							return
+						}
+						clientID, _ := utils.GetClientIDFromContext(r.Context())
+						change := metadataManager.MetadataChange{ActorID: clientID, Source: metadataManager.SourceRevert}
+						meta, err := imageMetadataManager.RevertImageMetadata(payload.ImageID, payload.Revision, change)
+						switch {
+						case errors.Is(err, metadataManager.ErrRevisionNotFound):
+							http.Error(w, "Revision not found", http.StatusNotFound)
+							return
+						case errors.Is(err, metadataManager.ErrMetadataNotFound):
+							http.Error(w, "Image not found", http.StatusNotFound)
+							return
+						case errors.Is(err, metadataManager.ErrImageForbidden):
+							http.Error(w, err.Error(), http.StatusForbidden)
+							return
+						}
 						if err != nil {
-							http.Error(w, "Unable to read request body", http.StatusBadRequest)
+							http.Error(w, "Error reverting metadata", http.StatusInternalServerError)
 							return
 						}
-						eventInput, err := Deserializers.DeserializeEventInput(bodyBytes)
-						addedEvent := eventsManager.AddDislike(eventInput)
+						KafkaOperations.SendKafkaEvent(constants.IMAGE_METADATA_UPDATE, nil, nil,
+							map[string]string{"image_id": payload.ImageID}, r.URL.Path, clientID)
 						w.Header().Set("Content-Type", "application/json")
-						json.NewEncoder(w).Encode(addedEvent)
+						json.NewEncoder(w).Encode(meta)
 					}),
 				),
 			),
 		),
 	)
 
-	// Endpoint to add a view.
-	http.Handle("/images/view",
+	// Interaction endpoints publish the event to Kafka and answer 202 with its ID; the Kafka
+	// consumer records it with the events manager.
+	//
+	// Endpoints to add a like, a dislike or a view, and to retract a like or a dislike.
+	for path, interaction := range map[string]struct {
+		eventType string
+		removal   bool
+	}{
+		"/images/like":      {userEventsManager.EventTypeLike, false},
+		"/images/dislike":   {userEventsManager.EventTypeDislike, false},
+		"/images/view":      {userEventsManager.EventTypeView, false},
+		"/images/unlike":    {userEventsManager.EventTypeLike, true},
+		"/images/undislike": {userEventsManager.EventTypeDislike, true},
+	} {
+		http.Handle(path,
+			Prometheus.CountRequests(
+				rateLimiter.Apply(
+					jwt.AuthenticateJWT(
+						http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							if r.Method != http.MethodPost {
+								http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+								return
+							}
+							KafkaOperations.InteractionHandler(interaction.eventType, interaction.removal, w, r)
+						}),
+					),
+				),
+			),
+		)
+	}
+
+	// Endpoint to add (POST) or retract (DELETE) an event of any registered type; the type is
+	// given by "event_type" in the body.
+	http.Handle("/images/events",
 		Prometheus.CountRequests(
 			rateLimiter.Apply(
 				jwt.AuthenticateJWT(
 					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						if r.Method != http.MethodPost {
+						if r.Method != http.MethodPost && r.Method != http.MethodDelete {
 							http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 							return
 						}
@@ -276,53 +575,469 @@ func main() {
 							return
 						}
 						eventInput, err := Deserializers.DeserializeEventInput(bodyBytes)
-						if err != nil {
+						if err != nil || eventInput.EventType == "" {
 							http.Error(w, "Invalid JSON input", http.StatusBadRequest)
 							return
 						}
-						addedEvent := eventsManager.AddView(eventInput)
+						// Comments have their own endpoints, which keep the comment threads.
+						if eventInput.EventType == userEventsManager.EventTypeComment {
+							http.Error(w, "Use /images/comment to post comments", http.StatusBadRequest)
+							return
+						}
+						r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+						KafkaOperations.InteractionHandler("", r.Method == http.MethodDelete, w, r)
+					}),
+				),
+			),
+		),
+	)
+
+	// Endpoint to list the registered event types and their rules.
+	http.Handle("/api/event-types",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if r.Method != http.MethodGet {
+							http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+							return
+						}
 						w.Header().Set("Content-Type", "application/json")
-						json.NewEncoder(w).Encode(addedEvent)
+						json.NewEncoder(w).Encode(eventsManager.EventTypes())
 					}),
 				),
 			),
 		),
 	)
 
-	// Endpoint to add a comment.
-	http.Handle("/images/comment",
+	// Endpoint to fetch the stats of an image, including the caller's own reactions.
+	http.Handle("/images/stats",
 		Prometheus.CountRequests(
 			rateLimiter.Apply(
 				jwt.AuthenticateJWT(
 					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						if r.Method != http.MethodPost {
+						if r.Method != http.MethodGet {
 							http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 							return
 						}
-						bodyBytes, err := io.ReadAll(r.Body)
-						if err != nil {
-							http.Error(w, "Unable to read request body", http.StatusBadRequest)
+						targetID := r.URL.Query().Get("target_id")
+						if targetID == "" {
+							http.Error(w, "target_id is required", http.StatusBadRequest)
 							return
 						}
-						eventInput, err := Deserializers.DeserializeEventInput(bodyBytes)
-						if err != nil {
-							http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+						if _, ok := metadataManager.AuthorizeImageRequest(w, r, imageMetadataManager, targetID, false); !ok {
 							return
 						}
-						addedEvent := eventsManager.AddComment(eventInput)
-						w.Header().Set("Content-Type", "application/json")
-						json.NewEncoder(w).Encode(addedEvent)
+						eventsManager.GetStatsHandler(w, r)
 					}),
 				),
 			),
 		),
 	)
 
+	// COMMENT endpoints: post a comment or reply, list threads, fetch/edit/delete a comment,
+	// moderate it and read its edit history.
+	http.Handle("/images/comment",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(commentHandlers.HandleCreateComment),
+				),
+			),
+		),
+	)
+	http.Handle("/images/comments",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(commentHandlers.GetCommentsHandler),
+				),
+			),
+		),
+	)
+	http.Handle("/images/comments/comment",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(commentHandlers.HandleComment),
+				),
+			),
+		),
+	)
+	http.Handle("/images/comments/moderate",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(commentHandlers.HandleModerateComment),
+				),
+			),
+		),
+	)
+	http.Handle("/images/comments/history",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(commentHandlers.HandleCommentHistory),
+				),
+			),
+		),
+	)
+
+	// ALBUM endpoints: list/create albums, fetch/update/delete an album, manage album images and stats.
+	http.Handle("/api/albums",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(albumHandlers.HandleAlbums),
+				),
+			),
+		),
+	)
+	http.Handle("/api/albums/album",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(albumHandlers.HandleAlbum),
+				),
+			),
+		),
+	)
+	http.Handle("/api/albums/images",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(albumHandlers.HandleAlbumImages),
+				),
+			),
+		),
+	)
+	http.Handle("/api/albums/stats",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(albumHandlers.HandleAlbumStats),
+				),
+			),
+		),
+	)
+
+	// ADMIN event listing: filtered, cursor-paginated user events.
+	http.Handle("/api/admin/events",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(eventsManager.ListEventsHandler),
+				),
+			),
+		),
+	)
+
+	// ADMIN event export: events matching a filter streamed as NDJSON, CSV or Parquet.
+	http.Handle("/api/admin/events/export",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(eventsManager.ExportEventsHandler),
+				),
+			),
+		),
+	)
+
+	// STATS HISTORY endpoint: hourly or daily engagement of an image or of a portfolio.
+	http.Handle("/api/stats/history",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(rollupHandlers.HandleHistory),
+				),
+			),
+		),
+	)
+
+	// TRENDING endpoint: public images ranked by recent engagement, per window and tag.
+	http.Handle("/api/images/trending",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(trendingHandlers.HandleTrending),
+				),
+			),
+		),
+	)
+
+	// DATA SUBJECT REQUEST endpoint: queue an export or erasure of a user's data, or poll its status.
+	http.Handle("/api/data-requests",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(dataSubjectHandlers.HandleRequests),
+				),
+			),
+		),
+	)
+
+	// DATA SUBJECT EXPORT download endpoint (?id=), once the export has completed.
+	http.Handle("/api/data-requests/download",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(dataSubjectHandlers.HandleDownload),
+				),
+			),
+		),
+	)
+
+	// NOTIFICATIONS endpoint (?unread=true, ?cursor=, ?limit=); the caller's notifications, newest first.
+	http.Handle("/api/notifications",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(notificationHandlers.HandleListNotifications),
+				),
+			),
+		),
+	)
+
+	// NOTIFICATIONS read endpoint; marks {"ids": [...]} or, with an empty body, all as read.
+	http.Handle("/api/notifications/read",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(notificationHandlers.HandleMarkRead),
+				),
+			),
+		),
+	)
+
+	// NOTIFICATIONS unread count endpoint, for badges.
+	http.Handle("/api/notifications/unread-count",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(notificationHandlers.HandleUnreadCount),
+				),
+			),
+		),
+	)
+
+	// NOTIFICATION PREFERENCES endpoint (GET, PUT); turns kinds of notifications on or off.
+	http.Handle("/api/notifications/preferences",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(notificationHandlers.HandlePreferences),
+				),
+			),
+		),
+	)
+
+	// MENTIONS endpoint (?user_id=, defaults to the caller; ?cursor=, ?limit=).
+	http.Handle("/api/mentions",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(mentionHandlers.HandleListMentions),
+				),
+			),
+		),
+	)
+
+	// HASHTAG IMAGES endpoint (?tag=, ?cursor=, ?limit=).
+	http.Handle("/api/hashtags/images",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(mentionHandlers.HandleHashtagImages),
+				),
+			),
+		),
+	)
+
+	// MODERATION QUEUE endpoint (admins; ?status=, ?kind=, ?cursor=, ?limit=).
+	http.Handle("/api/moderation/queue",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(moderationHandlers.HandleQueue),
+				),
+			),
+		),
+	)
+
+	// MODERATION REVIEW endpoint (admins); approves or rejects a queued item.
+	http.Handle("/api/moderation/review",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(moderationHandlers.HandleReview),
+				),
+			),
+		),
+	)
+
+	// MODERATION REPORTS endpoint; reports a comment, image title or image description.
+	http.Handle("/api/moderation/reports",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(moderationHandlers.HandleReport),
+				),
+			),
+		),
+	)
+
+	// WEBHOOK SUBSCRIPTIONS endpoint (create, list and delete the caller's subscriptions).
+	http.Handle("/api/webhooks",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(webhookHandlers.HandleSubscriptions),
+				),
+			),
+		),
+	)
+
+	// WEBHOOK TEST endpoint (?id=); sends a test event to a subscription right away.
+	http.Handle("/api/webhooks/test",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(webhookHandlers.HandleTest),
+				),
+			),
+		),
+	)
+
+	// WEBHOOK DELIVERY LOG endpoint (?subscription_id=, ?status=, ?cursor=, ?limit=).
+	http.Handle("/api/webhooks/deliveries",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(webhookHandlers.HandleDeliveries),
+				),
+			),
+		),
+	)
+
+	// WEBHOOK REPLAY endpoint (?id=); sends a delivery again.
+	http.Handle("/api/webhooks/deliveries/replay",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(webhookHandlers.HandleReplay),
+				),
+			),
+		),
+	)
+
+	// LIVE UPDATES endpoint (?image_id=); streams new comments and stat changes as Server-Sent
+	// Events.
+	if liveFeed != nil {
+		liveHeartbeat := 15 * time.Second
+		if value := os.Getenv("LIVE_HEARTBEAT_INTERVAL"); value != "" {
+			liveHeartbeat, err = time.ParseDuration(value)
+			errorHandler(err, "INVALID LIVE_HEARTBEAT_INTERVAL")
+		}
+		liveMaxAge := 30 * time.Minute
+		if value := os.Getenv("LIVE_MAX_CONNECTION_AGE"); value != "" {
+			liveMaxAge, err = time.ParseDuration(value)
+			errorHandler(err, "INVALID LIVE_MAX_CONNECTION_AGE")
+		}
+		liveHandlers := &liveUpdates.LiveHandlers{
+			Feed:                 liveFeed,
+			ImageMetadataManager: imageMetadataManager,
+			Stats:                eventsManager,
+			Heartbeat:            liveHeartbeat,
+			MaxConnectionAge:     liveMaxAge,
+		}
+		http.Handle("/api/images/live",
+			Prometheus.CountRequests(
+				rateLimiter.Apply(
+					jwt.AuthenticateJWT(
+						http.HandlerFunc(liveHandlers.HandleStream),
+					),
+				),
+			),
+		)
+	}
+
+	// SHARE LINK management endpoint (create, list and revoke links for the caller's images).
+	http.Handle("/api/images/share",
+		Prometheus.CountRequests(
+			rateLimiter.Apply(
+				jwt.AuthenticateJWT(
+					http.HandlerFunc(shareLinkHandlers.HandleShareLinks),
+				),
+			),
+		),
+	)
+
+	// SHARE LINK endpoint; the signed token is the credential, so no client authentication.
+	// Rate limited by client address, which also bounds password guessing.
+	// Not wrapped in CountRequests, which labels by path and would create a series per token.
+	http.Handle(shareLinkManagers.ShareLinkPathPrefix, rateLimiter.ApplyByIP(http.HandlerFunc(shareLinkHandlers.HandleOpenShareLink)))
+
 	// Start the server.
 	apiPort := os.Getenv("API_PORT")
-	fmt.Printf("Server started on http://localhost:%s\n", apiPort)
-	if err := http.ListenAndServe(":"+apiPort, nil); err != nil {
-		fmt.Println("Error starting server:", err)
-		os.Exit(1)
+	server := &http.Server{Addr: ":" + apiPort}
+	go func() {
+		fmt.Printf("Server started on http://localhost:%s\n", apiPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println("Error starting server:", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Periodic background jobs.
+	reconcileInterval := 10 * time.Minute
+	if value := os.Getenv("STATS_RECONCILE_INTERVAL"); value != "" {
+		reconcileInterval, err = time.ParseDuration(value)
+		errorHandler(err, "INVALID STATS_RECONCILE_INTERVAL")
+	}
+	rollupInterval := 5 * time.Minute
+	if value := os.Getenv("ROLLUP_INTERVAL"); value != "" {
+		rollupInterval, err = time.ParseDuration(value)
+		errorHandler(err, "INVALID ROLLUP_INTERVAL")
 	}
+	trendingInterval := time.Minute
+	if value := os.Getenv("TRENDING_INTERVAL"); value != "" {
+		trendingInterval, err = time.ParseDuration(value)
+		errorHandler(err, "INVALID TRENDING_INTERVAL")
+	}
+	retentionInterval := 24 * time.Hour
+	if value := os.Getenv("EVENT_RETENTION_INTERVAL"); value != "" {
+		retentionInterval, err = time.ParseDuration(value)
+		errorHandler(err, "INVALID EVENT_RETENTION_INTERVAL")
+	}
+	dataSubjectInterval := time.Minute
+	if value := os.Getenv("DATA_SUBJECT_INTERVAL"); value != "" {
+		dataSubjectInterval, err = time.ParseDuration(value)
+		errorHandler(err, "INVALID DATA_SUBJECT_INTERVAL")
+	}
+	webhookInterval := 5 * time.Second
+	if value := os.Getenv("WEBHOOK_DELIVERY_INTERVAL"); value != "" {
+		webhookInterval, err = time.ParseDuration(value)
+		errorHandler(err, "INVALID WEBHOOK_DELIVERY_INTERVAL")
+	}
+	scheduler := Jobs.NewScheduler()
+	scheduler.Every("stats-reconcile", reconcileInterval, eventsManager.ReconcileStats)
+	scheduler.Every("engagement-rollup", rollupInterval, rollupManager.Rollup)
+	scheduler.Every("trending-refresh", trendingInterval, trendingManager.Refresh)
+	scheduler.Every("event-retention", retentionInterval, func(ctx context.Context) error {
+		return eventsManager.ApplyRetention(ctx, imageStoreManager)
+	})
+	scheduler.Every("data-subject-requests", dataSubjectInterval, dataSubjectManager.ProcessPending)
+	scheduler.Every("webhook-deliveries", webhookInterval, webhookManager.DeliverDue)
+	scheduler.Start()
+
+	// On SIGINT/SIGTERM stop accepting requests, then flush buffered user events before exiting.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	log.Println("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	errorHandler(server.Shutdown(ctx), "ERROR SHUTTING DOWN SERVER")
+	scheduler.Stop()
+	errorHandler(eventsManager.Close(), "ERROR FLUSHING USER EVENTS")
 }
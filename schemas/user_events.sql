@@ -1,8 +1,231 @@
+-- Partitioned by month; the service creates the monthly partitions (user_events_y2024m01, ...).
 CREATE TABLE IF NOT EXISTS user_events (
-                                           id SERIAL PRIMARY KEY,
-                                           event_type VARCHAR(50) NOT NULL,
+    id SERIAL,
+    event_type VARCHAR(50) NOT NULL,
     user_id VARCHAR(100) NOT NULL,
     target_id VARCHAR(100) NOT NULL,
     comment TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, created_at)
+    ) PARTITION BY RANGE (created_at);
+CREATE TABLE IF NOT EXISTS user_events_default PARTITION OF user_events DEFAULT;
+CREATE INDEX IF NOT EXISTS user_events_target_idx ON user_events (target_id, event_type, user_id, created_at);
+
+-- Events deleted by retention, still counted by the stats.
+CREATE TABLE IF NOT EXISTS archived_event_counts (
+    target_id VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (target_id, event_type)
+    );
+
+-- Distinct viewers of views deleted by retention, still counted as unique viewers.
+CREATE TABLE IF NOT EXISTS archived_viewers (
+    target_id VARCHAR(100) NOT NULL,
+    user_id VARCHAR(100) NOT NULL,
+    PRIMARY KEY (target_id, user_id)
+    );
+
+CREATE TABLE IF NOT EXISTS user_reactions (
+    user_id VARCHAR(100) NOT NULL,
+    target_id VARCHAR(100) NOT NULL,
+    reaction VARCHAR(50) NOT NULL,
+    reaction_group VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, target_id, reaction_group)
+    );
+CREATE INDEX IF NOT EXISTS user_reactions_target_idx ON user_reactions (target_id, reaction);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    target_id VARCHAR(100) NOT NULL,
+    parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    root_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    author_id VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'visible',
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+    );
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    content TEXT NOT NULL,
+    edited_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (comment_id, revision)
+    );
+
+CREATE INDEX IF NOT EXISTS user_events_user_idx ON user_events (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS user_events_created_idx ON user_events (created_at, id);
+
+CREATE TABLE IF NOT EXISTS engagement_rollups (
+    granularity VARCHAR(10) NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    scope VARCHAR(10) NOT NULL,
+    scope_id VARCHAR(100) NOT NULL,
+    likes INT NOT NULL DEFAULT 0,
+    dislikes INT NOT NULL DEFAULT 0,
+    views INT NOT NULL DEFAULT 0,
+    comments INT NOT NULL DEFAULT 0,
+    unique_users INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (granularity, scope, scope_id, bucket_start)
+    );
+
+CREATE TABLE IF NOT EXISTS rollup_state (
+    granularity VARCHAR(10) PRIMARY KEY,
+    rolled_until TIMESTAMP NOT NULL
+    );
+
+CREATE TABLE IF NOT EXISTS trending_points (
+    target_id VARCHAR(100) NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    points DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (target_id, bucket_start)
+    );
+CREATE INDEX IF NOT EXISTS trending_points_bucket_idx ON trending_points (bucket_start);
+
+CREATE TABLE IF NOT EXISTS trending_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    taken_until TIMESTAMP NOT NULL
+    );
+
+CREATE TABLE IF NOT EXISTS trending_taken_events (
+    event_id BIGINT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS trending_taken_events_created_idx ON trending_taken_events (created_at);
+
+CREATE TABLE IF NOT EXISTS data_subject_requests (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    subject_id VARCHAR(100) NOT NULL,
+    requested_by VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    summary JSONB,
+    archive_key TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS data_subject_requests_status_idx ON data_subject_requests (status, created_at);
+CREATE INDEX IF NOT EXISTS data_subject_requests_subject_idx ON data_subject_requests (subject_id, kind);
+
+CREATE TABLE IF NOT EXISTS data_subject_audit (
+    id BIGSERIAL PRIMARY KEY,
+    request_id UUID NOT NULL REFERENCES data_subject_requests(id),
+    action VARCHAR(50) NOT NULL,
+    actor_id VARCHAR(100) NOT NULL,
+    detail JSONB,
     created_at TIMESTAMP NOT NULL DEFAULT NOW()
     );
+CREATE INDEX IF NOT EXISTS data_subject_audit_request_idx ON data_subject_audit (request_id, id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    recipient_id VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    target_id VARCHAR(100) NOT NULL,
+    actor_ids TEXT[] NOT NULL,
+    actor_count INT NOT NULL,
+    excerpt TEXT NOT NULL DEFAULT '',
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+    );
+CREATE UNIQUE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (recipient_id, kind, target_id) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS notifications_recipient_idx ON notifications (recipient_id, updated_at, id);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, kind)
+    );
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id UUID PRIMARY KEY,
+    client_id VARCHAR(100) NOT NULL,
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+    );
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_client_idx ON webhook_subscriptions (client_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    replay_of UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+    );
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (subscription_id, event_id) WHERE replay_of IS NULL;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_log_idx ON webhook_deliveries (subscription_id, created_at, id);
+
+CREATE TABLE IF NOT EXISTS moderation_items (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(30) NOT NULL,
+    content_id TEXT NOT NULL,
+    image_id TEXT NOT NULL,
+    author_id VARCHAR(100) NOT NULL,
+    text TEXT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    reasons TEXT[] NOT NULL,
+    report_count INT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    reviewer_id VARCHAR(100) NOT NULL DEFAULT '',
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+    );
+CREATE UNIQUE INDEX IF NOT EXISTS moderation_items_pending_idx ON moderation_items (kind, content_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS moderation_items_queue_idx ON moderation_items (status, created_at, id);
+
+CREATE TABLE IF NOT EXISTS moderation_reports (
+    item_id BIGINT NOT NULL REFERENCES moderation_items(id) ON DELETE CASCADE,
+    reporter_id VARCHAR(100) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (item_id, reporter_id)
+    );
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGSERIAL PRIMARY KEY,
+    source_kind VARCHAR(30) NOT NULL,
+    source_id TEXT NOT NULL,
+    image_id TEXT NOT NULL,
+    author_id VARCHAR(100) NOT NULL,
+    mentioned_user_id VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (source_kind, source_id, mentioned_user_id)
+    );
+CREATE INDEX IF NOT EXISTS mentions_user_idx ON mentions (mentioned_user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS mentions_image_idx ON mentions (image_id);
+
+CREATE TABLE IF NOT EXISTS hashtags (
+    id BIGSERIAL PRIMARY KEY,
+    source_kind VARCHAR(30) NOT NULL,
+    source_id TEXT NOT NULL,
+    image_id TEXT NOT NULL,
+    tag VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (source_kind, source_id, tag)
+    );
+CREATE INDEX IF NOT EXISTS hashtags_tag_idx ON hashtags (tag, image_id);
+CREATE INDEX IF NOT EXISTS hashtags_image_idx ON hashtags (image_id);
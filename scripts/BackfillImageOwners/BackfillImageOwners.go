@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	metadataManager "GOLA/ImageManagers/Metadata"
+	rawStoreManager "GOLA/ImageManagers/RawStore"
+
+	"github.com/joho/godotenv"
+)
+
+// Records ownership for images uploaded before owners were tracked. Stored images without
+// metadata get public metadata; with -owner, they and ownerless metadata are given that owner.
+// Images left without an owner stay legacy-public: visible to everyone, modifiable by nobody.
+// Usage: go run ./scripts/BackfillImageOwners -owner <client id> [-dry-run]
+func main() {
+	owner := flag.String("owner", "", "client ID to record as the owner of ownerless images")
+	dryRun := flag.Bool("dry-run", false, "only report what would change")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, assuming environment variables are set")
+	}
+
+	store, err := rawStoreManager.GetImageStoreManager(os.Getenv("RAW_IMAGE_STORAGE_TYPE"))
+	if err != nil {
+		log.Fatalf("Failed to create image store manager: %v", err)
+	}
+	if err := store.Initialize(); err != nil {
+		log.Fatalf("Failed to initialize image store manager: %v", err)
+	}
+
+	metadata, err := metadataManager.GetImageMetadataManager(os.Getenv("IMAGE_METADATA_STORAGE_TYPE"))
+	if err != nil {
+		log.Fatalf("Failed to create image metadata manager: %v", err)
+	}
+	if err := metadata.Initialize(); err != nil {
+		log.Fatalf("Failed to initialize image metadata manager: %v", err)
+	}
+
+	imageIDs, err := store.ListImages()
+	if err != nil {
+		log.Fatalf("Failed to list stored images: %v", err)
+	}
+
+	change := metadataManager.MetadataChange{ActorID: *owner, Source: metadataManager.SourceMigration}
+	updated := 0
+	for _, imageID := range imageIDs {
+		meta, err := metadata.GetImageMetadata(imageID)
+		switch {
+		case errors.Is(err, metadataManager.ErrMetadataNotFound):
+			meta = map[string]string{metadataManager.IsPrivateKey: "false"}
+		case err != nil:
+			log.Fatalf("Failed to fetch metadata of image %s: %v", imageID, err)
+		case meta[metadataManager.OwnerIDKey] != "" || *owner == "":
+			continue
+		}
+		if *owner != "" {
+			meta[metadataManager.OwnerIDKey] = *owner
+		}
+		if _, ok := meta[metadataManager.IsPrivateKey]; !ok {
+			meta[metadataManager.IsPrivateKey] = "false"
+		}
+
+		if *dryRun {
+			log.Printf("Would update image %s: %v", imageID, meta)
+		} else if err := metadata.SetImageMetadata(imageID, meta, change); err != nil {
+			log.Fatalf("Failed to update metadata of image %s after %d images: %v", imageID, updated, err)
+		}
+		updated++
+	}
+	log.Printf("Backfill complete: %d of %d stored images updated", updated, len(imageIDs))
+}
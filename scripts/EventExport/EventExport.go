@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	userEventsManager "GOLA/UserEventManagers"
+	"GOLA/commons"
+
+	"github.com/joho/godotenv"
+)
+
+// Exports user events matching a filter from the event store as NDJSON, CSV or Parquet.
+// Usage: go run ./scripts/EventExport -format parquet -out events.parquet -types user-like,user-view -from 2024-01-01T00:00:00Z
+func main() {
+	format := flag.String("format", userEventsManager.ExportNDJSON, "ndjson, csv or parquet")
+	out := flag.String("out", "", "output file (default: standard output)")
+	user := flag.String("user", "", "only events of this user")
+	target := flag.String("target", "", "only events on this target")
+	types := flag.String("types", "", "comma separated event types")
+	from := flag.String("from", "", "only events at or after this RFC 3339 time")
+	to := flag.String("to", "", "only events before this RFC 3339 time")
+	newestFirst := flag.Bool("newest-first", false, "export the newest events first")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, assuming environment variables are set")
+	}
+
+	filter := commons.EventFilter{UserID: *user, TargetID: *target, Ascending: !*newestFirst}
+	for _, eventType := range strings.Split(*types, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			filter.EventTypes = append(filter.EventTypes, eventType)
+		}
+	}
+	var err error
+	if *from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			log.Fatalf("Invalid -from: %v", err)
+		}
+	}
+	if *to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+
+	output := os.Stdout
+	if *out != "" {
+		if output, err = os.Create(*out); err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+	}
+	writer, err := userEventsManager.NewEventWriter(*format, output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events, err := userEventsManager.GetEventManager(os.Getenv("USER_EVENTS_STORE"))
+	if err != nil {
+		log.Fatalf("Failed to create user events manager: %v", err)
+	}
+	events.Initialize()
+	defer events.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	exported, err := events.ExportEvents(ctx, filter, writer.Write)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = output.Close()
+	}
+	if err != nil {
+		log.Fatalf("Export failed after %d events: %v", exported, err)
+	}
+	log.Printf("Export complete: %d events", exported)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	userEventsManager "GOLA/UserEventManagers"
+	"GOLA/UserEventManagers/Conformance"
+	redisCache "GOLA/caches/Redis"
+
+	"github.com/joho/godotenv"
+)
+
+// Runs the EventManager conformance suite against one or more event stores and exits with
+// status 1 if any case fails. The database store needs the usual DB_* settings; point them at
+// a scratch database, since the suite writes events.
+// Usage: go run ./scripts/EventManagerConformance -stores memory,postgresDb -redis
+func main() {
+	stores := flag.String("stores", "memory", "comma separated USER_EVENTS_STORE values to check")
+	useRedis := flag.Bool("redis", false, "connect to Redis (REDIS_HOST, REDIS_PORT) for view tracking and cached stats")
+	timeout := flag.Duration("timeout", time.Minute, "time limit per store")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, assuming environment variables are set")
+	}
+	// The suite checks that repeated views count once.
+	os.Setenv("VIEW_DEDUP_WINDOW", "30m")
+	if *useRedis {
+		redisCache.InitRedis()
+	}
+
+	failed := false
+	for _, store := range strings.Split(*stores, ",") {
+		store = strings.TrimSpace(store)
+		manager, err := userEventsManager.GetEventManager(store)
+		if err != nil {
+			log.Fatalf("Error creating event manager: %v", err)
+		}
+		manager.Initialize()
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		for _, result := range Conformance.NewSuite(manager).Run(ctx) {
+			if result.Err != nil {
+				failed = true
+				fmt.Printf("FAIL  %s: %s: %v\n", store, result.Name, result.Err)
+			} else {
+				fmt.Printf("ok    %s: %s\n", store, result.Name)
+			}
+		}
+		cancel()
+		if err := manager.Close(); err != nil {
+			log.Printf("Error closing %s event manager: %v", store, err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
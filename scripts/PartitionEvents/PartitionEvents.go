@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+
+	userEventsManager "GOLA/UserEventManagers"
+	dbCommons "GOLA/commons/db"
+
+	"github.com/joho/godotenv"
+)
+
+// Partitions an unpartitioned user_events table by month. Every event is copied in one
+// transaction that holds an ACCESS EXCLUSIVE lock on user_events, so events cannot be written
+// or read until it is done; run it during a maintenance window. Running it again does nothing.
+// Usage: go run ./scripts/PartitionEvents
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, assuming environment variables are set")
+	}
+
+	config, err := dbCommons.LoadDBConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load database configuration: %v", err)
+	}
+	db, err := dbCommons.InitializeDB(config)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer db.Close()
+
+	if err := userEventsManager.PartitionEventsTable(db); err != nil {
+		log.Fatalf("Partitioning failed, user_events is unchanged: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/segmentio/kafka-go"
+)
+
+// Moves the events of the dead-letter topic back onto the main topic, once whatever made them
+// fail is fixed, and stops when the dead-letter topic has been idle for -idle. Each event is
+// committed only after it was written to the main topic.
+// Usage: go run ./scripts/ReplayDeadLetters -idle 10s
+func main() {
+	idle := flag.Duration("idle", 10*time.Second, "stop after the dead-letter topic was idle this long")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, assuming environment variables are set")
+	}
+	broker, topic := os.Getenv("KAFKA_BROKER_ADDRESS"), os.Getenv("KAFKA_TOPIC")
+	if broker == "" || topic == "" {
+		log.Fatal("KAFKA_BROKER_ADDRESS and KAFKA_TOPIC are required")
+	}
+	deadLetterTopic := os.Getenv("KAFKA_DEAD_LETTER_TOPIC")
+	if deadLetterTopic == "" {
+		deadLetterTopic = topic + "-dead-letter"
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  []string{broker},
+		Topic:    deadLetterTopic,
+		GroupID:  os.Getenv("KAFKA_CONSUMER_GROUP_ID") + "-dead-letter-replay",
+		MaxBytes: 10e6,
+	})
+	defer reader.Close()
+	writer := &kafka.Writer{Addr: kafka.TCP(broker), Topic: topic, Balancer: &kafka.Hash{}}
+	defer writer.Close()
+
+	replayed := 0
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), *idle)
+		msg, err := reader.FetchMessage(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Error reading dead-letter topic after %d events: %v", replayed, err)
+		}
+		// The event starts over with a fresh attempt count.
+		if err := writer.WriteMessages(context.Background(), kafka.Message{Key: msg.Key, Value: msg.Value}); err != nil {
+			log.Fatalf("Error replaying event at offset %d after %d events: %v", msg.Offset, replayed, err)
+		}
+		if err := reader.CommitMessages(context.Background(), msg); err != nil {
+			log.Fatalf("Error committing offset %d after %d events: %v", msg.Offset, replayed, err)
+		}
+		replayed++
+	}
+	log.Printf("Replay complete: %d events moved from %s to %s", replayed, deadLetterTopic, topic)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	metadataManager "GOLA/ImageManagers/Metadata"
+	searchIndexers "GOLA/SearchIndexers"
+
+	"github.com/joho/godotenv"
+)
+
+// Rebuilds the search index from the image metadata store.
+// Usage: go run ./scripts/SearchReindex -batch 500
+func main() {
+	batchSize := flag.Int("batch", 500, "number of documents per bulk request")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, assuming environment variables are set")
+	}
+
+	metadata, err := metadataManager.GetImageMetadataManager(os.Getenv("IMAGE_METADATA_STORAGE_TYPE"))
+	if err != nil {
+		log.Fatalf("Failed to create image metadata manager: %v", err)
+	}
+	if err := metadata.Initialize(); err != nil {
+		log.Fatalf("Failed to initialize image metadata manager: %v", err)
+	}
+
+	indexer, err := searchIndexers.GetSearchIndexer(os.Getenv("SEARCH_INDEXER_TYPE"))
+	if err != nil {
+		log.Fatalf("Failed to create search indexer: %v", err)
+	}
+	if err := indexer.Initialize(); err != nil {
+		log.Fatalf("Failed to initialize search indexer: %v", err)
+	}
+
+	indexed, err := searchIndexers.FullReindex(indexer, metadata, *batchSize)
+	if err != nil {
+		log.Fatalf("Reindex failed after %d images: %v", indexed, err)
+	}
+	log.Printf("Reindex complete: %d images indexed", indexed)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fires many parallel /images/view requests at a running server and reports throughput and
+// latency percentiles. BenchmarkAddEventViews in UserEventManagers measures the event store
+// alone and needs no running stack.
+// Usage: go run ./scripts/ViewLoadTest -token <jwt> -client-id <id> -client-secret <secret> -target <image> -c 64 -n 20000
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "server base URL")
+	token := flag.String("token", "", "JWT access token")
+	clientID := flag.String("client-id", "", "client_id header for the rate limiter")
+	clientSecret := flag.String("client-secret", "", "client_secret header for the rate limiter")
+	target := flag.String("target", "", "image ID to record views for")
+	concurrency := flag.Int("c", 64, "number of concurrent clients")
+	requests := flag.Int("n", 10000, "total number of requests")
+	flag.Parse()
+
+	if *token == "" || *target == "" {
+		log.Fatal("-token and -target are required")
+	}
+	if *concurrency <= 0 || *requests <= 0 {
+		log.Fatal("-c and -n must be positive")
+	}
+
+	body, err := json.Marshal(map[string]string{"target_id": *target})
+	if err != nil {
+		log.Fatalf("Error encoding request body: %v", err)
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+	}
+
+	var next, failures int64
+	latencies := make([][]time.Duration, *concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for worker := 0; worker < *concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for atomic.AddInt64(&next, 1) <= int64(*requests) {
+				req, err := http.NewRequest(http.MethodPost, *baseURL+"/images/view", bytes.NewReader(body))
+				if err != nil {
+					log.Fatalf("Error creating request: %v", err)
+				}
+				req.Header.Set("Authorization", "Bearer "+*token)
+				req.Header.Set("client_id", *clientID)
+				req.Header.Set("client_secret", *clientSecret)
+				req.Header.Set("Content-Type", "application/json")
+
+				sent := time.Now()
+				resp, err := client.Do(req)
+				if err != nil {
+					atomic.AddInt64(&failures, 1)
+					continue
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusAccepted {
+					atomic.AddInt64(&failures, 1)
+					continue
+				}
+				latencies[worker] = append(latencies[worker], time.Since(sent))
+			}
+		}(worker)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var all []time.Duration
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	log.Printf("%d requests, %d failed, concurrency %d, in %s", *requests, failures, *concurrency, elapsed)
+	log.Printf("throughput: %.0f req/s", float64(len(all))/elapsed.Seconds())
+	if len(all) > 0 {
+		log.Printf("latency p50=%s p90=%s p99=%s max=%s",
+			percentile(all, 50), percentile(all, 90), percentile(all, 99), all[len(all)-1])
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := len(sorted) * p / 100
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
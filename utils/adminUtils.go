@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	adminsOnce sync.Once
+	admins     map[string]bool
+)
+
+// IsAdmin reports whether the client is listed in ADMIN_CLIENT_IDS, a comma-separated list of
+// client IDs allowed to moderate and administer any content.
+func IsAdmin(clientID string) bool {
+	adminsOnce.Do(func() {
+		admins = map[string]bool{}
+		for _, id := range strings.Split(os.Getenv("ADMIN_CLIENT_IDS"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				admins[id] = true
+			}
+		}
+	})
+	return clientID != "" && admins[clientID]
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"GOLA/commons"
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 )
 
@@ -30,3 +32,12 @@ func GetClientCredentials(r *http.Request) (*commons.ClientCredentials, bool) {
 	creds, ok := r.Context().Value("creds").(*commons.ClientCredentials)
 	return creds, ok
 }
+
+// WriteJSON writes body as a JSON response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}